@@ -61,8 +61,8 @@ var _ = Describe("testing commands", func() {
 	BeforeEach(func() {
 		v = viper.New()
 		config.Init(v)
-		rootCmd = root.Create(v, nil)
-		serverCmd = server.Create(v, nil)
+		rootCmd = root.Create(v, &config.RootScheme{})
+		serverCmd = server.Create(v, &config.RootScheme{})
 		rootCmd.AddCommand(&serverCmd)
 	})
 
@@ -78,7 +78,20 @@ var _ = Describe("testing commands", func() {
 			Expect(conf.Server.Host).To(Equal("localhost"))
 			Expect(conf.Server.Port).To(Equal(9999))
 			Expect(conf.Server.Auth.TokenName).To(Equal("Bearer"))
-			Expect(conf.Server.Auth.TokenExpire).To(Equal(time.Hour * 24))
+			Expect(conf.Server.Auth.TokenExpire).To(Equal(time.Minute * 15))
+			Expect(conf.Server.Auth.RefreshTokenExpire).To(Equal(time.Hour * 24 * 30))
+		})
+	})
+	Context("when starting server", func() {
+		It("should fail without flow secret", func() {
+			conf := config.RootScheme{}
+
+			err := v.Unmarshal(&conf)
+			Expect(err).NotTo(HaveOccurred())
+
+			cmd := server.Create(v, &conf)
+			err = cmd.RunE(&cmd, nil)
+			Expect(err).To(MatchError("server.auth.flowsecret must be defined"))
 		})
 	})
 	Context("when reading from config", func() {
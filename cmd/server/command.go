@@ -11,9 +11,13 @@ import (
 	serverconf "git.zam.io/wallet-backend/web-api/config/server"
 	internalproviders "git.zam.io/wallet-backend/web-api/internal/providers"
 	_ "git.zam.io/wallet-backend/web-api/internal/server/handlers"
+	"git.zam.io/wallet-backend/web-api/internal/server/handlers/apikeys"
 	"git.zam.io/wallet-backend/web-api/internal/server/handlers/auth"
+	"git.zam.io/wallet-backend/web-api/internal/server/handlers/internalapi"
 	"git.zam.io/wallet-backend/web-api/internal/server/handlers/kyc"
+	"git.zam.io/wallet-backend/web-api/internal/server/handlers/twofactor"
 	"git.zam.io/wallet-backend/web-api/pkg/providers"
+	"git.zam.io/wallet-backend/web-api/pkg/server/handlers/jwks"
 	"git.zam.io/wallet-backend/web-api/pkg/server/handlers/static"
 	"github.com/gin-gonic/gin"
 	"github.com/spf13/cobra"
@@ -45,6 +49,11 @@ func Create(v *viper.Viper, cfg *config.RootScheme) cobra.Command {
 
 // serverMain
 func serverMain(cfg config.RootScheme) (err error) {
+	err = cfg.Server.Auth.Validate()
+	if err != nil {
+		return
+	}
+
 	// create DI container and populate it with providers
 	c := dig.New()
 
@@ -70,9 +79,21 @@ func serverMain(cfg config.RootScheme) (err error) {
 	// provide nosql storage
 	utils.MustProvide(c, providers.Storage)
 
+	// provide jwt keys
+	utils.MustProvide(c, providers.JWTKeys)
+
 	// provide sessions storage
 	utils.MustProvide(c, providers.SessionsStorage)
 
+	// provide access/refresh tokens pairs storage
+	utils.MustProvide(c, providers.RefreshStorage)
+
+	// provide sessions activity tracker
+	utils.MustProvide(c, providers.ActivityTracker)
+
+	// provide password policy
+	utils.MustProvide(c, providers.PasswordPolicy)
+
 	// provide static generator
 	utils.MustProvide(c, providers.Generator)
 
@@ -95,13 +116,22 @@ func serverMain(cfg config.RootScheme) (err error) {
 	// provide api router
 	utils.MustProvide(c, internalproviders.ApiRoutes, dig.Name("api_routes"))
 
-	// provide auth middleware
+	// provide auth middleware which accepts both session tokens and personal api keys
+	utils.MustProvide(c, internalproviders.APIKeyStorage)
 	utils.MustProvide(c, providers.AuthMiddleware, dig.Name("auth"))
 
+	// provide internal api router protected by service tokens
+	utils.MustProvide(c, providers.ServiceAuthMiddleware, dig.Name("service_auth"))
+	utils.MustProvide(c, internalproviders.InternalRoutes, dig.Name("internal_routes"))
+
 	// register handlers
 	utils.MustInvoke(c, static.Register)
+	utils.MustInvoke(c, jwks.Register)
 	utils.MustInvoke(c, auth.Register)
 	utils.MustInvoke(c, kyc.Register)
+	utils.MustInvoke(c, twofactor.Register)
+	utils.MustInvoke(c, apikeys.Register)
+	utils.MustInvoke(c, internalapi.Register)
 
 	// Run server!
 	utils.MustInvoke(c, func(engine *gin.Engine) error {
@@ -8,6 +8,7 @@ server:
         uri: '$STAGING_REDIS_URI'
     auth:
         tokenstorage: jwtpersistent
+        flowsecret: '$STAGING_FLOW_SECRET'
     jwt:
         secret: '$STAGING_SECRET'
         method: HS256
@@ -0,0 +1 @@
+drop table known_devices;
@@ -0,0 +1,8 @@
+create table known_devices (
+  id           bigserial primary key,
+  user_id      int references users(id) not null,
+  fingerprint  varchar(64) not null,
+  created_at   timestamp without time zone not null,
+  last_seen_at timestamp without time zone not null,
+  unique (user_id, fingerprint)
+);
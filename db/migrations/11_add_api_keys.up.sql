@@ -0,0 +1,13 @@
+create table api_keys (
+  id           bigserial primary key,
+  user_id      int references users(id) not null,
+  name         varchar(64) not null,
+  prefix       varchar(16) not null,
+  hash         varchar(64) not null unique,
+  scopes       text[] not null default '{}',
+  created_at   timestamp without time zone not null,
+  last_used_at timestamp without time zone,
+  revoked_at   timestamp without time zone
+);
+
+create index on api_keys (user_id);
@@ -0,0 +1 @@
+drop table user_totp;
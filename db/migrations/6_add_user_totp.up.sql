@@ -0,0 +1,12 @@
+create table user_totp (
+  id           serial primary key,
+  user_id      int references users(id) not null unique,
+  secret       varchar(64) not null,
+  enabled      boolean not null default false,
+  backup_codes text[] not null default '{}',
+  last_step    bigint not null default 0,
+  created_at   timestamp without time zone not null,
+  confirmed_at timestamp without time zone
+);
+
+create index on user_totp (user_id);
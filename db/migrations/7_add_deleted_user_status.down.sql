@@ -0,0 +1,7 @@
+update users set status_id = (
+  select id from user_statuses where name = 'pending'
+) where status_id = (
+  select id from user_statuses where name = 'deleted'
+);
+
+delete from user_statuses where name = 'deleted';
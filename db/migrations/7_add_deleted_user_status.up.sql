@@ -0,0 +1 @@
+insert into user_statuses (name) values ('deleted');
@@ -0,0 +1,12 @@
+create table roles (
+  id   serial primary key,
+  name varchar(63) not null unique
+);
+
+insert into roles (name) values ('admin'), ('support');
+
+create table user_roles (
+  user_id int references users(id) on delete cascade not null,
+  role_id int references roles(id) on delete cascade not null,
+  primary key (user_id, role_id)
+);
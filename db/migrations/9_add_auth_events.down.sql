@@ -0,0 +1,3 @@
+drop table auth_events;
+
+drop function auth_events_append_only();
@@ -0,0 +1,21 @@
+create table auth_events (
+  id         bigserial primary key,
+  user_id    int references users(id),
+  type       varchar(63) not null,
+  ip         varchar(45) not null default '',
+  user_agent text not null default '',
+  created_at timestamp without time zone not null
+);
+
+create index on auth_events (user_id, id);
+
+-- security log is append-only
+create function auth_events_append_only() returns trigger as $$
+begin
+  raise exception 'auth_events is append-only';
+end;
+$$ language plpgsql;
+
+create trigger auth_events_append_only
+  before update or delete on auth_events
+  for each row execute procedure auth_events_append_only();
@@ -0,0 +1,87 @@
+// Package apikeys holds personal api keys of the users, only key hashes are stored
+package apikeys
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+)
+
+// Scopes which may be granted to the api key, all of them are read-only
+const (
+	ScopeStatsRead          = "stats:read"
+	ScopeSecurityEventsRead = "security_events:read"
+)
+
+// Scopes all known scopes
+var Scopes = []string{ScopeStatsRead, ScopeSecurityEventsRead}
+
+const (
+	// keyPrefix distinguishes api keys from other secrets, e.g. when key is leaked into the public repository
+	keyPrefix = "zk_"
+
+	// keyRandomLen length of the key random part in bytes
+	keyRandomLen = 32
+
+	// displayPrefixLen length of the key beginning which is stored as is, so user can tell his keys apart
+	displayPrefixLen = 11
+)
+
+// Key personal api key of the user, it's valid until revoked
+type Key struct {
+	ID     int64
+	UserID int64
+
+	Name string
+
+	// Prefix beginning of the key shown to the user
+	Prefix string
+
+	// Hash of the whole key
+	Hash string
+
+	Scopes []string
+
+	CreatedAt  time.Time
+	LastUsedAt *time.Time
+	RevokedAt  *time.Time
+}
+
+// HasScope
+func (k Key) HasScope(scope string) bool {
+	for _, s := range k.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// IsKnownScope reports whether scope may be granted to the key
+func IsKnownScope(scope string) bool {
+	for _, s := range Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// Generate generates new random key, returns key itself and it's prefix which may be stored as is
+func Generate() (key string, prefix string, err error) {
+	raw := make([]byte, keyRandomLen)
+	_, err = rand.Read(raw)
+	if err != nil {
+		return
+	}
+	key = keyPrefix + hex.EncodeToString(raw)
+	prefix = key[:displayPrefixLen]
+	return
+}
+
+// Hash returns hash of the key under which it's stored, key has enough entropy, so plain hash is sufficient
+func Hash(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
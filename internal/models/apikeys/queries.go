@@ -0,0 +1,101 @@
+package apikeys
+
+import (
+	"database/sql"
+	"time"
+
+	"git.zam.io/wallet-backend/web-api/db"
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+)
+
+// ErrNotFound returned when key doesn't exist or revoked
+var ErrNotFound = errors.New("apikeys: key not found")
+
+const selectKeys = `select
+		id, user_id, name, prefix, hash, scopes, created_at, last_used_at, revoked_at
+	from api_keys`
+
+// Create stores new key, key id is filled on success
+func Create(tx db.ITx, key Key) (Key, error) {
+	if key.Scopes == nil {
+		key.Scopes = []string{}
+	}
+	err := tx.QueryRow(
+		`insert into api_keys (user_id, name, prefix, hash, scopes, created_at) values ($1, $2, $3, $4, $5, $6)
+		 returning id`,
+		key.UserID, key.Name, key.Prefix, key.Hash, pq.Array(key.Scopes), key.CreatedAt,
+	).Scan(&key.ID)
+	return key, err
+}
+
+// List returns not revoked keys of the user, oldest first
+func List(tx db.ITx, userID int64) (keys []Key, err error) {
+	rows, err := tx.Query(selectKeys+` where user_id = $1 and revoked_at is null order by id`, userID)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	keys = []Key{}
+	for rows.Next() {
+		var k Key
+		err = scanKey(rows, &k)
+		if err != nil {
+			return
+		}
+		keys = append(keys, k)
+	}
+	err = rows.Err()
+	return
+}
+
+// GetByHash returns not revoked key by it's hash
+func GetByHash(tx db.ITx, hash string) (key Key, err error) {
+	err = scanKey(tx.QueryRow(selectKeys+` where hash = $1 and revoked_at is null`, hash), &key)
+	if err == sql.ErrNoRows {
+		err = ErrNotFound
+	}
+	return
+}
+
+// Revoke revokes user key, revoked key is kept, so it's hash is never reused
+func Revoke(tx db.ITx, userID, id int64, now time.Time) error {
+	res, err := tx.Exec(
+		`update api_keys set revoked_at = $3 where id = $1 and user_id = $2 and revoked_at is null`,
+		id, userID, now,
+	)
+	if err != nil {
+		return err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// Touch marks key as used
+func Touch(tx db.ITx, id int64, now time.Time) error {
+	_, err := tx.Exec(`update api_keys set last_used_at = $2 where id = $1`, id, now)
+	return err
+}
+
+func scanKey(row interface {
+	Scan(dest ...interface{}) error
+}, k *Key) error {
+	return row.Scan(
+		&k.ID,
+		&k.UserID,
+		&k.Name,
+		&k.Prefix,
+		&k.Hash,
+		pq.Array(&k.Scopes),
+		&k.CreatedAt,
+		&k.LastUsedAt,
+		&k.RevokedAt,
+	)
+}
@@ -0,0 +1,93 @@
+package apikeys_test
+
+import (
+	"git.zam.io/wallet-backend/web-api/db"
+	. "git.zam.io/wallet-backend/web-api/fixtures"
+	"git.zam.io/wallet-backend/web-api/fixtures/database"
+	"git.zam.io/wallet-backend/web-api/fixtures/database/migrations"
+	"git.zam.io/wallet-backend/web-api/internal/models/apikeys"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestApiKeysModels(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Api Keys Models Suite")
+}
+
+const userPhone = "+79000000001"
+
+var _ = Describe("api keys queries", func() {
+	Init()
+	database.Init()
+	migrations.Init()
+
+	type createdKey struct {
+		key    string
+		stored apikeys.Key
+	}
+
+	BeforeEachCProvide(func(d *db.Db) (userID int64) {
+		err := d.QueryRow(`insert into users (phone) values ($1) returning id`, userPhone).Scan(&userID)
+		Expect(err).NotTo(HaveOccurred())
+		return
+	})
+
+	BeforeEachCProvide(func(d *db.Db, userID int64) createdKey {
+		key, prefix, err := apikeys.Generate()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(strings.HasPrefix(key, prefix)).To(BeTrue())
+
+		stored, err := apikeys.Create(d, apikeys.Key{
+			UserID:    userID,
+			Name:      "stats script",
+			Prefix:    prefix,
+			Hash:      apikeys.Hash(key),
+			Scopes:    []string{apikeys.ScopeStatsRead},
+			CreatedAt: time.Now().UTC(),
+		})
+		Expect(err).NotTo(HaveOccurred())
+		return createdKey{key, stored}
+	})
+
+	ItD("should find key by hash of the key", func(d *db.Db, created createdKey) {
+		key, err := apikeys.GetByHash(d, apikeys.Hash(created.key))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(key.ID).To(Equal(created.stored.ID))
+		Expect(key.Scopes).To(Equal([]string{apikeys.ScopeStatsRead}))
+		Expect(key.HasScope(apikeys.ScopeStatsRead)).To(BeTrue())
+		Expect(key.HasScope(apikeys.ScopeSecurityEventsRead)).To(BeFalse())
+		Expect(key.LastUsedAt).To(BeNil())
+
+		_, err = apikeys.GetByHash(d, apikeys.Hash(created.key+"0"))
+		Expect(err).To(Equal(apikeys.ErrNotFound))
+	})
+
+	ItD("should mark key as used", func(d *db.Db, created createdKey) {
+		Expect(apikeys.Touch(d, created.stored.ID, time.Now().UTC())).To(Succeed())
+
+		key, err := apikeys.GetByHash(d, created.stored.Hash)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(key.LastUsedAt).NotTo(BeNil())
+	})
+
+	ItD("should hide revoked key", func(d *db.Db, userID int64, created createdKey) {
+		keys, err := apikeys.List(d, userID)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(keys).To(HaveLen(1))
+
+		Expect(apikeys.Revoke(d, userID+1, created.stored.ID, time.Now().UTC())).To(Equal(apikeys.ErrNotFound))
+		Expect(apikeys.Revoke(d, userID, created.stored.ID, time.Now().UTC())).To(Succeed())
+		Expect(apikeys.Revoke(d, userID, created.stored.ID, time.Now().UTC())).To(Equal(apikeys.ErrNotFound))
+
+		keys, err = apikeys.List(d, userID)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(keys).To(BeEmpty())
+
+		_, err = apikeys.GetByHash(d, created.stored.Hash)
+		Expect(err).To(Equal(apikeys.ErrNotFound))
+	})
+})
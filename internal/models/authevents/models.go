@@ -0,0 +1,35 @@
+package authevents
+
+import "time"
+
+// Type authentication event type
+type Type string
+
+// Event types
+const (
+	TypeSignin           Type = "signin"
+	TypeSigninFailed     Type = "signin_failed"
+	TypeSignout          Type = "signout"
+	TypeTokenRefreshed   Type = "token_refreshed"
+	TypeSignupStarted    Type = "signup_started"
+	TypeSignupVerified   Type = "signup_verified"
+	TypeSignupFinished   Type = "signup_finished"
+	TypeRecoveryStarted  Type = "recovery_started"
+	TypeRecoveryVerified Type = "recovery_verified"
+	TypeRecoveryFinished Type = "recovery_finished"
+	TypePasswordChanged  Type = "password_changed"
+	TypeAPIKeyCreated    Type = "api_key_created"
+	TypeAPIKeyRevoked    Type = "api_key_revoked"
+)
+
+// Event authentication event of the user, user is unknown for failed signin attempts with unregistered phone
+type Event struct {
+	ID     int64
+	UserID *int64
+
+	Type      Type
+	IP        string
+	UserAgent string
+
+	CreatedAt time.Time
+}
@@ -0,0 +1,41 @@
+package authevents
+
+import (
+	"git.zam.io/wallet-backend/web-api/db"
+)
+
+// Create appends event to the log, events are never updated nor deleted
+func Create(tx db.ITx, event Event) (Event, error) {
+	err := tx.QueryRow(
+		`insert into auth_events (user_id, type, ip, user_agent, created_at) values ($1, $2, $3, $4, $5) returning id`,
+		event.UserID, event.Type, event.IP, event.UserAgent, event.CreatedAt,
+	).Scan(&event.ID)
+	return event, err
+}
+
+// List returns up to limit user events newest first, only events older then event with beforeID are returned if it's
+// non-zero
+func List(tx db.ITx, userID int64, beforeID int64, limit int) (events []Event, err error) {
+	rows, err := tx.Query(
+		`select id, user_id, type, ip, user_agent, created_at from auth_events
+		 where user_id = $1 and ($2 = 0 or id < $2)
+		 order by id desc limit $3`,
+		userID, beforeID, limit,
+	)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	events = []Event{}
+	for rows.Next() {
+		var e Event
+		err = rows.Scan(&e.ID, &e.UserID, &e.Type, &e.IP, &e.UserAgent, &e.CreatedAt)
+		if err != nil {
+			return
+		}
+		events = append(events, e)
+	}
+	err = rows.Err()
+	return
+}
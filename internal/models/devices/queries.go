@@ -0,0 +1,39 @@
+// Package devices stores fingerprints of the devices users have signed in from
+package devices
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"time"
+
+	"git.zam.io/wallet-backend/web-api/db"
+)
+
+// Fingerprint identifies device by it's name, user agent and ip, so known device used from the new address is treated
+// as the unknown one
+func Fingerprint(device, userAgent, ip string) string {
+	sum := sha256.Sum256([]byte(strings.Join([]string{device, userAgent, ip}, "\n")))
+	return hex.EncodeToString(sum[:])
+}
+
+// Remember marks device as known for the user. Known reports whether device has been used before, first reports
+// whether user had no known devices at all, e.g. signs in for the first time.
+func Remember(tx db.ITx, userID int64, fingerprint string, now time.Time) (known bool, first bool, err error) {
+	var count int64
+	err = tx.QueryRow(
+		`select count(*), coalesce(bool_or(fingerprint = $2), false) from known_devices where user_id = $1`,
+		userID, fingerprint,
+	).Scan(&count, &known)
+	if err != nil {
+		return
+	}
+	first = count == 0
+
+	_, err = tx.Exec(
+		`insert into known_devices (user_id, fingerprint, created_at, last_seen_at) values ($1, $2, $3, $3)
+		 on conflict (user_id, fingerprint) do update set last_seen_at = excluded.last_seen_at`,
+		userID, fingerprint, now,
+	)
+	return
+}
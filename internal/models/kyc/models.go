@@ -12,6 +12,9 @@ const (
 	StatusPending  StatusType = "pending"
 	StatusVerified            = "verified"
 	StatusDeclined            = "declined"
+
+	// StatusUnloaded isn't stored, it's reported when user hasn't uploaded kyc data yet
+	StatusUnloaded StatusType = "unloaded"
 )
 
 // PersonalData holds user personal information
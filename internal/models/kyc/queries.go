@@ -93,4 +93,22 @@ func GetStatus(tx db.ITx, userID int64) (status StatusType, err error) {
 		userID,
 	).Scan(&status)
 	return
-}
\ No newline at end of file
+}
+
+// Anonymize overwrites user kyc data with placeholders, row itself is kept to preserve the verification status.
+// Absence of kyc data isn't an error.
+func Anonymize(tx db.ITx, userID int64) (err error) {
+	_, err = tx.Exec(
+		`update personal_data set
+			email = '',
+			first_name = '',
+			last_name = '',
+			birth_date = '1970-01-01',
+			sex = 'undefined',
+			country = '',
+			address = '{}'
+		where user_id = $1`,
+		userID,
+	)
+	return
+}
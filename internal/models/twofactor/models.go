@@ -0,0 +1,56 @@
+package twofactor
+
+import (
+	"crypto/subtle"
+	"time"
+
+	"git.zam.io/wallet-backend/web-api/pkg/services/totp"
+)
+
+// Data holds user TOTP secret, it's enrolled disabled and enabled only after user confirms it by the valid code
+type Data struct {
+	ID     int64
+	UserID int64
+
+	Secret  string
+	Enabled bool
+
+	// BackupCodes hashes of the unused backup codes
+	BackupCodes []string
+
+	// LastStep time step of the last accepted code, used to prevent code replay
+	LastStep int64
+
+	CreatedAt   time.Time
+	ConfirmedAt *time.Time
+}
+
+// Verify checks either TOTP code or one of the backup codes. Accepted code can't be used again, so data must be saved
+// after successful verification.
+func (d *Data) Verify(code string, now time.Time) (ok bool, err error) {
+	step, ok, err := totp.Validate(d.Secret, code, now, d.LastStep)
+	if err != nil {
+		return
+	}
+	if ok {
+		d.LastStep = step
+		return
+	}
+
+	hash := totp.HashBackupCode(code)
+	for i, stored := range d.BackupCodes {
+		if subtle.ConstantTimeCompare([]byte(stored), []byte(hash)) == 1 {
+			d.BackupCodes = append(d.BackupCodes[:i:i], d.BackupCodes[i+1:]...)
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// SetBackupCodes replaces backup codes with hashes of the given ones
+func (d *Data) SetBackupCodes(codes []string) {
+	d.BackupCodes = make([]string, len(codes))
+	for i, code := range codes {
+		d.BackupCodes[i] = totp.HashBackupCode(code)
+	}
+}
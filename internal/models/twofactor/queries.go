@@ -0,0 +1,92 @@
+package twofactor
+
+import (
+	"database/sql"
+
+	"git.zam.io/wallet-backend/web-api/db"
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+)
+
+var (
+	// ErrNotFound returned when user hasn't enrolled TOTP
+	ErrNotFound = errors.New("twofactor: totp not enrolled")
+
+	// ErrNoSuchUser
+	ErrNoSuchUser = errors.New("twofactor: no such user")
+)
+
+// Get returns user TOTP data, if forUpdate specified row is locked until transaction ends
+func Get(tx db.ITx, userID int64, forUpdate ...bool) (data *Data, err error) {
+	query := `select
+			id, user_id, secret, enabled, backup_codes, last_step, created_at, confirmed_at
+		from user_totp where user_id = $1`
+	if len(forUpdate) > 0 && forUpdate[0] {
+		query += ` for update`
+	}
+
+	var d Data
+	err = tx.QueryRow(query, userID).Scan(
+		&d.ID,
+		&d.UserID,
+		&d.Secret,
+		&d.Enabled,
+		pq.Array(&d.BackupCodes),
+		&d.LastStep,
+		&d.CreatedAt,
+		&d.ConfirmedAt,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			err = ErrNotFound
+		}
+		return
+	}
+	data = &d
+	return
+}
+
+// Save creates user TOTP data or replaces existing one
+func Save(tx db.ITx, data *Data) (err error) {
+	if data.BackupCodes == nil {
+		data.BackupCodes = []string{}
+	}
+
+	err = tx.QueryRow(
+		`insert into user_totp
+			(user_id, secret, enabled, backup_codes, last_step, created_at, confirmed_at)
+		 values ($1, $2, $3, $4, $5, $6, $7)
+		 on conflict (user_id) do update set
+			secret = excluded.secret,
+			enabled = excluded.enabled,
+			backup_codes = excluded.backup_codes,
+			last_step = excluded.last_step,
+			created_at = excluded.created_at,
+			confirmed_at = excluded.confirmed_at
+		 returning id`,
+		data.UserID, data.Secret, data.Enabled, pq.Array(data.BackupCodes), data.LastStep,
+		data.CreatedAt, data.ConfirmedAt,
+	).Scan(&data.ID)
+	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Column == "user_id" {
+			err = ErrNoSuchUser
+		}
+	}
+	return
+}
+
+// Delete removes user TOTP data
+func Delete(tx db.ITx, userID int64) (err error) {
+	res, err := tx.Exec(`delete from user_totp where user_id = $1`, userID)
+	if err != nil {
+		return
+	}
+	rows, err := res.RowsAffected()
+	switch {
+	case err != nil:
+		return
+	case rows == 0:
+		err = ErrNotFound
+	}
+	return
+}
@@ -1,6 +1,7 @@
 package user
 
 import (
+	"fmt"
 	"git.zam.io/wallet-backend/common/pkg/types"
 	"time"
 )
@@ -57,6 +58,13 @@ func (user *User) SetStatus(newStatus UserStatusName) {
 	}
 }
 
+// Anonymize replaces user phone with placeholder which can't collide with real phones, so the phone becomes
+// available for the new registration
+func (user *User) Anonymize() {
+	user.Phone = types.Phone(fmt.Sprintf("deleted:%d", user.ID))
+	user.ReferrerPhone = nil
+}
+
 // UserStatusName represents UserStatuses table column type
 type UserStatusName string
 
@@ -66,6 +74,13 @@ const (
 	UserStatusPending  = UserStatusName("pending")
 	UserStatusVerified = UserStatusName("verified")
 	UserStatusActive   = UserStatusName("active")
+	UserStatusDeleted  = UserStatusName("deleted")
+)
+
+// Role names, roles grant access to the route groups protected by the role middleware
+const (
+	RoleAdmin   = "admin"
+	RoleSupport = "support"
 )
 
 // UserStatus represents user status
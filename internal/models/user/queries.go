@@ -24,6 +24,9 @@ var (
 
 	// ErrReferrerNotFound returned when user creation attempt failed because of wrong referrer phone
 	ErrReferrerNotFound = errors.New("referrer not found")
+
+	// ErrInvalidRole returned when role with such name doesn't exist
+	ErrInvalidRole = errors.New("invalid role")
 )
 
 // GetUserPhoneByID
@@ -38,8 +41,9 @@ func GetUserPhoneByID(tx db.ITx, id int64) (phone string, err error) {
 	return
 }
 
-// GetUserByID performs user search by id query by given id using current transaction
-func GetUserByID(tx db.ITx, id string) (user User, err error) {
+// GetUserByID performs user search by id query by given id using current transaction, if forUpdate specified
+// appropriate sql statement will be generated
+func GetUserByID(tx db.ITx, id string, forUpdate ...bool) (user User, err error) {
 	intID, err := parseUserID(id)
 	if err != nil {
 		return
@@ -47,7 +51,17 @@ func GetUserByID(tx db.ITx, id string) (user User, err error) {
 
 	// perform query
 	// TODO queries must be prepared
-	user, err = doUserQuery(tx, `u.id = $1`, false, intID)
+	forUpdateCoerced := len(forUpdate) > 0 && forUpdate[0]
+	user, err = doUserQuery(tx, `u.id = $1`, forUpdateCoerced, intID)
+	return
+}
+
+// GetUsersByIDs get users by ids ordered by id, missing users are skipped
+func GetUsersByIDs(tx db.ITx, ids []int64) (users []User, err error) {
+	if len(ids) == 0 {
+		return
+	}
+	users, err = doUsersQuery(tx, `u.id = ANY($1) ORDER BY u.id`, pq.Array(ids))
 	return
 }
 
@@ -162,6 +176,9 @@ func UpdateUser(tx db.ITx, user User) (err error) {
 		user.Phone, user.Password, user.RegisteredAt, user.UpdatedAt, statusID, user.ID,
 	)
 	if err != nil {
+		if pgErr, ok := err.(pq.PGError); ok && pgErr.Get('n') == "users_phone_idx" {
+			err = ErrUserAlreadyExists
+		}
 		return
 	}
 	rows, err := res.RowsAffected()
@@ -214,6 +231,83 @@ func UpdateUserStatus(tx db.ITx, user User, status UserStatusName) (newUser User
 	return
 }
 
+// DeleteUser anonymizes user phone and moves user into deleted status, you must pass user with valid ID field
+func DeleteUser(tx db.ITx, user User) (newUser User, err error) {
+	user.Anonymize()
+	user.Status = UserStatusDeleted
+
+	err = UpdateUser(tx, user)
+	if err != nil {
+		return
+	}
+	return user, nil
+}
+
+// GetUserRoles returns names of the user roles sorted by name, user without roles gets empty slice
+func GetUserRoles(tx db.ITx, userID int64) (roles []string, err error) {
+	rows, err := tx.Query(
+		`SELECT r.name FROM user_roles ur
+		 INNER JOIN roles r ON ur.role_id = r.id
+		 WHERE ur.user_id = $1
+		 ORDER BY r.name`,
+		userID,
+	)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	roles = []string{}
+	for rows.Next() {
+		var role string
+		err = rows.Scan(&role)
+		if err != nil {
+			return
+		}
+		roles = append(roles, role)
+	}
+	err = rows.Err()
+	return
+}
+
+// AddUserRole grants role to the user, granting already granted role isn't an error
+func AddUserRole(tx db.ITx, userID int64, role string) (err error) {
+	res, err := tx.Exec(
+		`INSERT INTO user_roles (user_id, role_id)
+		 SELECT $1, id FROM roles WHERE name = $2
+		 ON CONFLICT DO NOTHING`,
+		userID, role,
+	)
+	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Column == "user_id" {
+			err = ErrUserNotFound
+		}
+		return
+	}
+
+	// nothing inserted either because role is already granted or because there is no such role
+	rows, err := res.RowsAffected()
+	if err != nil || rows > 0 {
+		return
+	}
+	var exists bool
+	err = tx.QueryRow(`SELECT EXISTS(SELECT 1 FROM roles WHERE name = $1)`, role).Scan(&exists)
+	if err == nil && !exists {
+		err = ErrInvalidRole
+	}
+	return
+}
+
+// RemoveUserRole revokes role from the user, revoking not granted role isn't an error
+func RemoveUserRole(tx db.ITx, userID int64, role string) (err error) {
+	_, err = tx.Exec(
+		`DELETE FROM user_roles
+		 WHERE user_id = $1 AND role_id = (SELECT id FROM roles WHERE name = $2)`,
+		userID, role,
+	)
+	return
+}
+
 func getUserStatusID(tx db.ITx, status UserStatusName) (id int64, err error) {
 	// TODO may be locally cached
 	res := tx.QueryRow(`SELECT id FROM user_statuses WHERE name = $1`, status)
@@ -238,22 +332,59 @@ func getUserStatus(tx db.ITx, id int64) (status UserStatusName, err error) {
 	return
 }
 
+const userQuery = `SELECT
+				u.id, u.phone, u.password, u.registered_at, u.created_at,
+		     	u.referrer_id, u.status_id, us.name, ru.phone 
+         FROM users u 
+		 LEFT JOIN users ru ON u.referrer_id = ru.id
+		 INNER JOIN user_statuses us ON u.status_id = us.id
+		 WHERE `
+
 func doUserQuery(tx db.ITx, filter string, forUpdate bool, args ...interface{}) (user User, err error) {
 	var forUpdatePart string
 	if forUpdate {
 		forUpdatePart = "FOR UPDATE OF u"
 	}
 
-	query := `SELECT
-				u.id, u.phone, u.password, u.registered_at, u.created_at,
-		     	u.referrer_id, u.status_id, us.name, ru.phone 
-         FROM users u 
-		 LEFT JOIN users ru ON u.referrer_id = ru.id
-		 INNER JOIN user_statuses us ON u.status_id = us.id
-		 WHERE ` + filter + ` ` + forUpdatePart
-	row := tx.QueryRow(query, args...)
+	row := tx.QueryRow(userQuery+filter+` `+forUpdatePart, args...)
+
+	err = scanUser(row, &user)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			err = ErrUserNotFound
+		}
+		return
+	}
+
+	return
+}
 
-	err = row.Scan(
+func doUsersQuery(tx db.ITx, filter string, args ...interface{}) (users []User, err error) {
+	rows, err := tx.Query(userQuery+filter, args...)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var user User
+		err = scanUser(rows, &user)
+		if err != nil {
+			return
+		}
+		users = append(users, user)
+	}
+	err = rows.Err()
+	return
+}
+
+// scanner represents both sql.Row and sql.Rows
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanUser(row scanner, user *User) error {
+	return row.Scan(
 		&user.ID,
 		&user.Phone,
 		&user.Password,
@@ -264,14 +395,6 @@ func doUserQuery(tx db.ITx, filter string, forUpdate bool, args ...interface{})
 		&user.Status,
 		&user.ReferrerPhone,
 	)
-	if err != nil {
-		if err == sql.ErrNoRows {
-			err = ErrUserNotFound
-		}
-		return
-	}
-
-	return
 }
 
 // utils
@@ -0,0 +1,14 @@
+package providers
+
+import (
+	serverconf "git.zam.io/wallet-backend/web-api/config/server"
+	"git.zam.io/wallet-backend/web-api/db"
+	"git.zam.io/wallet-backend/web-api/internal/services/apikeys"
+	"git.zam.io/wallet-backend/web-api/pkg/server/middlewares"
+)
+
+// APIKeyStorage provides personal api keys storage used by the auth middleware, key usage is throttled the same way as
+// sessions activity
+func APIKeyStorage(d *db.Db, conf serverconf.Scheme) middlewares.IAPIKeyStorage {
+	return apikeys.New(d, conf.Auth.LastSeenThrottle)
+}
@@ -13,6 +13,6 @@ func Notificator(conf serverconf.Scheme, logger logrus.FieldLogger) (notificatio
 	if conf.Notificator.URL == "" {
 		return stub.New(logger), nil
 	} else {
-		return factory.New(conf.Notificator.URL)
+		return factory.New(conf.Notificator.URL, conf.Notificator.CallURL, conf.Notificator.EmailURL)
 	}
 }
@@ -2,9 +2,23 @@ package providers
 
 import (
 	"github.com/gin-gonic/gin"
+	"go.uber.org/dig"
 )
 
 // ApiRoutes
 func ApiRoutes(engine *gin.Engine) gin.IRouter {
 	return engine.Group("/api/v1")
 }
+
+// InternalRoutesDependencies
+type InternalRoutesDependencies struct {
+	dig.In
+
+	Routes      gin.IRouter     `name:"api_routes"`
+	ServiceAuth gin.HandlerFunc `name:"service_auth"`
+}
+
+// InternalRoutes routes of the internal api, all of them require service token
+func InternalRoutes(deps InternalRoutesDependencies) gin.IRouter {
+	return deps.Routes.Group("/internal", deps.ServiceAuth)
+}
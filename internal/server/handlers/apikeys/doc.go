@@ -0,0 +1,2 @@
+// Package apikeys holds handlers which manage personal api keys of the user
+package apikeys
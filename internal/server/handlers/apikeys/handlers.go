@@ -0,0 +1,178 @@
+package apikeys
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"git.zam.io/wallet-backend/web-api/db"
+	"git.zam.io/wallet-backend/web-api/internal/models/apikeys"
+	"git.zam.io/wallet-backend/web-api/internal/models/authevents"
+	models "git.zam.io/wallet-backend/web-api/internal/models/user"
+	"git.zam.io/wallet-backend/web-api/internal/server/handlers/auth/audit"
+	"git.zam.io/wallet-backend/web-api/pkg/server/handlers/base"
+	"git.zam.io/wallet-backend/web-api/pkg/server/middlewares"
+	"github.com/gin-gonic/gin"
+	"github.com/mitchellh/mapstructure"
+	"github.com/pkg/errors"
+)
+
+// maxKeys count of the active keys user may have
+const maxKeys = 10
+
+var (
+	errKeyNotFound  = base.ErrorView{Code: http.StatusNotFound, Message: "api key not found"}
+	errTooManyKeys  = base.ErrorView{Code: http.StatusBadRequest, Message: fmt.Sprintf("at most %d api keys allowed", maxKeys)}
+	errUnknownScope = base.NewFieldErr(
+		"body", "scopes", "scopes must be of "+strings.Join(apikeys.Scopes, ", "),
+	)
+)
+
+// ListFactory returns handler which lists active api keys of the user
+func ListFactory(d *db.Db) base.HandlerFunc {
+	return func(c *gin.Context) (resp interface{}, code int, err error) {
+		userID, err := getUserIDFromContext(c)
+		if err != nil {
+			return
+		}
+
+		keys, err := apikeys.List(d, userID)
+		if err != nil {
+			return
+		}
+
+		view := KeysResponse{Keys: make([]KeyView, 0, len(keys))}
+		for _, k := range keys {
+			view.Keys = append(view.Keys, NewKeyView(k))
+		}
+		resp = view
+		return
+	}
+}
+
+// CreateFactory returns handler which creates new api key with given read-only scopes, key is returned only once
+func CreateFactory(d *db.Db) base.HandlerFunc {
+	return func(c *gin.Context) (resp interface{}, code int, err error) {
+		userID, err := getUserIDFromContext(c)
+		if err != nil {
+			return
+		}
+
+		params := CreateRequest{}
+		err = base.ShouldBindJSON(c, &params)
+		if err != nil {
+			return
+		}
+		for _, scope := range params.Scopes {
+			if !apikeys.IsKnownScope(scope) {
+				err = errUnknownScope
+				return
+			}
+		}
+
+		key, prefix, err := apikeys.Generate()
+		if err != nil {
+			return
+		}
+
+		var created apikeys.Key
+		err = d.Tx(func(tx db.ITx) error {
+			// user row lock serializes concurrent key creations, so keys limit can't be exceeded
+			_, err := models.GetUserByID(tx, fmt.Sprint(userID), true)
+			if err != nil {
+				return err
+			}
+
+			keys, err := apikeys.List(tx, userID)
+			if err != nil {
+				return err
+			}
+			if len(keys) >= maxKeys {
+				return errTooManyKeys
+			}
+
+			created, err = apikeys.Create(tx, apikeys.Key{
+				UserID:    userID,
+				Name:      params.Name,
+				Prefix:    prefix,
+				Hash:      apikeys.Hash(key),
+				Scopes:    params.Scopes,
+				CreatedAt: time.Now().UTC(),
+			})
+			if err != nil {
+				return err
+			}
+
+			return audit.Record(c, tx, userID, authevents.TypeAPIKeyCreated)
+		})
+		if err != nil {
+			return
+		}
+		resp = CreateResponse{KeyView: NewKeyView(created), Key: key}
+		return
+	}
+}
+
+// RevokeFactory returns handler which revokes user api key, revoked key is rejected immediately
+func RevokeFactory(d *db.Db) base.HandlerFunc {
+	return func(c *gin.Context) (resp interface{}, code int, err error) {
+		userID, err := getUserIDFromContext(c)
+		if err != nil {
+			return
+		}
+
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			err = errKeyNotFound
+			return
+		}
+
+		err = d.Tx(func(tx db.ITx) error {
+			err := apikeys.Revoke(tx, userID, id, time.Now().UTC())
+			if err == apikeys.ErrNotFound {
+				return errKeyNotFound
+			}
+			if err != nil {
+				return err
+			}
+			return audit.Record(c, tx, userID, authevents.TypeAPIKeyRevoked)
+		})
+		return
+	}
+}
+
+// NewKeyView
+func NewKeyView(k apikeys.Key) KeyView {
+	view := KeyView{
+		ID:        strconv.FormatInt(k.ID, 10),
+		Name:      k.Name,
+		Prefix:    k.Prefix,
+		Scopes:    k.Scopes,
+		CreatedAt: k.CreatedAt.Unix(),
+	}
+	if k.LastUsedAt != nil {
+		lastUsedAt := k.LastUsedAt.Unix()
+		view.LastUsedAt = &lastUsedAt
+	}
+	return view
+}
+
+func getUserIDFromContext(c *gin.Context) (id int64, err error) {
+	var userID struct {
+		ID int64
+	}
+
+	data := middlewares.GetUserDataFromContext(c)
+	if data == nil {
+		err = errors.New("apikeys: user auth middleware is missing")
+		return
+	}
+	err = mapstructure.Decode(data, &userID)
+	if err != nil {
+		err = errors.Wrap(err, "apikeys")
+	}
+	id = userID.ID
+	return
+}
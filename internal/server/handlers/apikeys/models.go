@@ -0,0 +1,28 @@
+package apikeys
+
+// CreateRequest
+type CreateRequest struct {
+	Name   string   `json:"name" validate:"required,max=64"`
+	Scopes []string `json:"scopes" validate:"required,min=1"`
+}
+
+// KeyView describes api key, key itself is never shown except creation response
+type KeyView struct {
+	ID         string   `json:"id"`
+	Name       string   `json:"name"`
+	Prefix     string   `json:"prefix"`
+	Scopes     []string `json:"scopes"`
+	CreatedAt  int64    `json:"created_at"`
+	LastUsedAt *int64   `json:"last_used_at"`
+}
+
+// KeysResponse
+type KeysResponse struct {
+	Keys []KeyView `json:"keys"`
+}
+
+// CreateResponse holds created key, it's shown only once
+type CreateResponse struct {
+	KeyView
+	Key string `json:"key"`
+}
@@ -0,0 +1,25 @@
+package apikeys
+
+import (
+	"git.zam.io/wallet-backend/web-api/db"
+	"git.zam.io/wallet-backend/web-api/pkg/server/handlers/base"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/dig"
+)
+
+// Dependencies dependencies used by api keys endpoints
+type Dependencies struct {
+	dig.In
+
+	Db             *db.Db
+	Routes         gin.IRouter     `name:"api_routes"`
+	AuthMiddleware gin.HandlerFunc `name:"auth"`
+}
+
+// Register registers api keys management endpoints, they have no api key scope, so keys can't manage themselves
+func Register(deps Dependencies) {
+	group := deps.Routes.Group("/user/me/api_keys", deps.AuthMiddleware)
+	group.GET("", base.WrapHandler(ListFactory(deps.Db)))
+	group.POST("", base.WrapHandler(CreateFactory(deps.Db)))
+	group.DELETE("/:id", base.WrapHandler(RevokeFactory(deps.Db)))
+}
@@ -0,0 +1,40 @@
+// Package audit records authentication events of the users into the security log and keeps track of the devices users
+// sign in from
+package audit
+
+import (
+	"time"
+
+	"git.zam.io/wallet-backend/web-api/db"
+	"git.zam.io/wallet-backend/web-api/internal/models/authevents"
+	"git.zam.io/wallet-backend/web-api/internal/models/devices"
+	"git.zam.io/wallet-backend/web-api/pkg/server/middlewares"
+	"github.com/gin-gonic/gin"
+)
+
+// Record appends event of the user performing request
+func Record(c *gin.Context, tx db.ITx, userID int64, eventType authevents.Type) error {
+	return create(c, tx, &userID, eventType)
+}
+
+// RecordUnknown appends event of the unknown user performing request, e.g. signin attempt with unregistered phone
+func RecordUnknown(c *gin.Context, tx db.ITx, eventType authevents.Type) error {
+	return create(c, tx, nil, eventType)
+}
+
+// RememberDevice marks device performing request as known for the user, see devices.Remember for the results meaning
+func RememberDevice(c *gin.Context, tx db.ITx, userID int64, device string) (known bool, first bool, err error) {
+	fingerprint := devices.Fingerprint(device, c.Request.UserAgent(), middlewares.ClientIP(c))
+	return devices.Remember(tx, userID, fingerprint, time.Now().UTC())
+}
+
+func create(c *gin.Context, tx db.ITx, userID *int64, eventType authevents.Type) error {
+	_, err := authevents.Create(tx, authevents.Event{
+		UserID:    userID,
+		Type:      eventType,
+		IP:        middlewares.ClientIP(c),
+		UserAgent: c.Request.UserAgent(),
+		CreatedAt: time.Now().UTC(),
+	})
+	return err
+}
@@ -3,11 +3,22 @@ package auth
 import (
 	"git.zam.io/wallet-backend/web-api/db"
 	_ "git.zam.io/wallet-backend/web-api/internal/server/handlers"
+	"git.zam.io/wallet-backend/web-api/internal/services/isc"
+	iscmocks "git.zam.io/wallet-backend/web-api/internal/services/isc/mocks"
 	"git.zam.io/wallet-backend/web-api/internal/services/notifications"
 	notifmocks "git.zam.io/wallet-backend/web-api/internal/services/notifications/mocks"
 	"git.zam.io/wallet-backend/web-api/pkg/server/handlers/base"
+	"git.zam.io/wallet-backend/web-api/pkg/server/middlewares"
+	"git.zam.io/wallet-backend/web-api/pkg/services/nosql/mem"
+	"git.zam.io/wallet-backend/web-api/pkg/services/passpolicy"
 	"git.zam.io/wallet-backend/web-api/pkg/services/sessions"
+	activitymocks "git.zam.io/wallet-backend/web-api/pkg/services/sessions/activity/mocks"
+	sessmem "git.zam.io/wallet-backend/web-api/pkg/services/sessions/mem"
 	sessmocks "git.zam.io/wallet-backend/web-api/pkg/services/sessions/mocks"
+	"git.zam.io/wallet-backend/web-api/pkg/services/sessions/refresh"
+	refreshmocks "git.zam.io/wallet-backend/web-api/pkg/services/sessions/refresh/mocks"
+	"git.zam.io/wallet-backend/web-api/pkg/services/throttle"
+	"git.zam.io/wallet-backend/web-api/pkg/services/totp"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 
@@ -17,10 +28,14 @@ import (
 
 	"bytes"
 	"encoding/json"
+	"fmt"
+	"git.zam.io/wallet-backend/web-api/internal/models/authevents"
+	"git.zam.io/wallet-backend/web-api/internal/models/twofactor"
 	models "git.zam.io/wallet-backend/web-api/internal/models/user"
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/mock"
 	"net/http"
+	"net/http/httptest"
 	"time"
 )
 
@@ -34,6 +49,10 @@ const (
 	pass2         = "54321"
 	pass3         = "lkjhafnion2rmpu1-w0m9d12h3[f912u3nr0ym92p[,iod-0]\\/\\]"
 	shortPass     = "123"
+
+	maxSigninFailures = 3
+	maxVerifyAttempts = 5
+	backupCode        = "abcde-fghjk"
 )
 
 const tokenName = "TestBearer"
@@ -41,8 +60,21 @@ const tokenName = "TestBearer"
 var mockedToken = sessions.Token("TOKENTOKENTOKEN")
 var mockedToken2 = sessions.Token("ToKToKToKToKToKToKToKToK")
 
+var mockedPair = refresh.Pair{Access: mockedToken, Refresh: mockedToken2}
+
+// signinDeps dependencies shared by signin handlers
+type signinDeps struct {
+	phoneLimiter *throttle.Limiter
+	ipLimiter    *throttle.Limiter
+	tickets      *TwoFactorTickets
+}
+
+// twoFactorHandler distinguishes second signin step handler from the first one
+type twoFactorHandler base.HandlerFunc
+
 type tokenResp struct {
-	Token string
+	Token        string
+	RefreshToken string
 }
 
 func CreateContext(method, url string, body interface{}) *gin.Context {
@@ -60,7 +92,9 @@ func CreateContext(method, url string, body interface{}) *gin.Context {
 		panic(err)
 	}
 
-	return &gin.Context{Request: req}
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = req
+	return c
 }
 
 func CreateContextWA(method, url string, body interface{}, tokenName, token string) *gin.Context {
@@ -100,11 +134,33 @@ var _ = Describe("Given the auth api", func() {
 	BeforeEachCProvide(func(store *sessmocks.IStorage, sender *notifmocks.ISender) (sessions.IStorage, notifications.ISender) {
 		return store, sender
 	})
+	BeforeEachCProvide(func() (*refreshmocks.IStorage, refresh.IStorage) {
+		tokens := &refreshmocks.IStorage{}
+		return tokens, tokens
+	})
 
 	Context("when querying singin requests", func() {
+		BeforeEachCProvide(func() (*iscmocks.IEventNotificator, isc.IEventNotificator) {
+			notifier := &iscmocks.IEventNotificator{}
+			return notifier, notifier
+		})
+		BeforeEachCProvide(func() signinDeps {
+			storage := mem.New()
+			params := throttle.Params{
+				Window:      time.Minute,
+				MaxFailures: maxSigninFailures,
+				Lockout:     time.Minute,
+				MaxLockout:  time.Hour,
+			}
+			return signinDeps{
+				phoneLimiter: throttle.New(storage, "signin:phone", params, time.Now),
+				ipLimiter:    throttle.New(storage, "signin:ip", params, time.Now),
+				tickets:      NewTwoFactorTickets(storage, time.Minute),
+			}
+		})
 		BeforeEachCProvide(
-			func(d *db.Db, sessStore sessions.IStorage, notificator notifications.ISender) base.HandlerFunc {
-				return SigninHandlerFactory(d, sessStore, authExpire)
+			func(d *db.Db, tokens refresh.IStorage, notifier isc.IEventNotificator, deps signinDeps) base.HandlerFunc {
+				return SigninHandlerFactory(d, tokens, notifier, deps.phoneLimiter, deps.ipLimiter, deps.tickets)
 			},
 		)
 
@@ -117,23 +173,90 @@ var _ = Describe("Given the auth api", func() {
 			})
 
 			Context("IStorage occurs get", func() {
-				BeforeEachCInvoke(func(sessStore *sessmocks.IStorage) {
-					sessStore.On("New", mock.Anything, authExpire).Return(mockedToken, nil)
+				BeforeEachCInvoke(func(tokens *refreshmocks.IStorage) {
+					tokens.On("New", mock.Anything).Return(mockedPair, nil)
 				})
 
-				ItD("should return token", func(handler base.HandlerFunc, sessStore *sessmocks.IStorage) {
+				ItD("should return tokens pair", func(handler base.HandlerFunc, tokens *refreshmocks.IStorage) {
 					data, _, err := handler(CreateSIContext(validPhone1, pass1))
 					Expect(err).NotTo(HaveOccurred())
 					Expect(data).To(BeEquivalentTo(tokenResp{
-						Token: string(mockedToken),
+						Token:        string(mockedToken),
+						RefreshToken: string(mockedToken2),
 					}))
 
 					// check user phone must be stored in session
-					Expect(len(sessStore.Calls)).To(Equal(1))
-					Expect(len(sessStore.Calls[0].Arguments)).To(Equal(2))
+					Expect(len(tokens.Calls)).To(Equal(1))
+					Expect(len(tokens.Calls[0].Arguments)).To(Equal(1))
 
-					sessPayload := sessStore.Calls[0].Arguments[0]
+					sessPayload := tokens.Calls[0].Arguments[0]
 					Expect(sessPayload).To(HaveKeyWithValue("phone", validPhone1))
+
+					// check client metadata also stored in session
+					Expect(sessPayload).To(HaveKey(sessions.IPKey))
+					Expect(sessPayload).To(HaveKey(sessions.UserAgentKey))
+					Expect(sessPayload).To(HaveKey(sessions.CreatedAtKey))
+
+					// user without roles gets empty roles list
+					Expect(sessPayload).To(HaveKeyWithValue(middlewares.RolesKey, []string{}))
+				})
+
+				ItD("should record signin attempts", func(d *db.Db, handler base.HandlerFunc) {
+					_, _, err := handler(CreateSIContext(validPhone1, pass2))
+					Expect(err).To(HaveOccurred())
+					_, _, err = handler(CreateSIContext(validPhone1, pass1))
+					Expect(err).NotTo(HaveOccurred())
+
+					user, err := models.GetUserByPhone(d, validPhone1)
+					Expect(err).NotTo(HaveOccurred())
+					events, err := authevents.List(d, user.ID, 0, 10)
+					Expect(err).NotTo(HaveOccurred())
+					Expect(events).To(HaveLen(2))
+					Expect(events[0].Type).To(Equal(authevents.TypeSignin))
+					Expect(events[1].Type).To(Equal(authevents.TypeSigninFailed))
+				})
+
+				ItD("should notify user about signin from the new device", func(
+					handler base.HandlerFunc, notifier *iscmocks.IEventNotificator,
+				) {
+					notifier.On("NewDeviceSignin", mock.Anything, validPhone1, "", "10.0.0.2").Return(nil)
+					signin := func(ip string) {
+						c := CreateSIContext(validPhone1, pass1)
+						c.Request.RemoteAddr = ip + ":40000"
+						_, _, err := handler(c)
+						Expect(err).NotTo(HaveOccurred())
+					}
+
+					By("first signin isn't reported")
+					signin("10.0.0.1")
+					notifier.AssertNumberOfCalls(GinkgoT(), "NewDeviceSignin", 0)
+
+					By("known device isn't reported")
+					signin("10.0.0.1")
+					notifier.AssertNumberOfCalls(GinkgoT(), "NewDeviceSignin", 0)
+
+					By("signin from the new address is reported once")
+					signin("10.0.0.2")
+					signin("10.0.0.2")
+					notifier.AssertNumberOfCalls(GinkgoT(), "NewDeviceSignin", 1)
+				})
+
+				ItD("should store user roles in session", func(
+					d *db.Db, handler base.HandlerFunc, tokens *refreshmocks.IStorage,
+				) {
+					user, err := models.GetUserByPhone(d, validPhone1)
+					Expect(err).NotTo(HaveOccurred())
+					Expect(models.AddUserRole(d, user.ID, models.RoleSupport)).To(Succeed())
+					Expect(models.AddUserRole(d, user.ID, models.RoleAdmin)).To(Succeed())
+					Expect(models.AddUserRole(d, user.ID, "unknown")).To(Equal(models.ErrInvalidRole))
+
+					_, _, err = handler(CreateSIContext(validPhone1, pass1))
+					Expect(err).NotTo(HaveOccurred())
+
+					sessPayload := tokens.Calls[0].Arguments[0]
+					Expect(sessPayload).To(HaveKeyWithValue(
+						middlewares.RolesKey, []string{models.RoleAdmin, models.RoleSupport},
+					))
 				})
 			})
 
@@ -157,6 +280,145 @@ var _ = Describe("Given the auth api", func() {
 				Expect(data).To(BeNil())
 				Expect(err).To(Equal(base.NewFieldErr("body", "phone", "either phone or password are invalid")))
 			})
+
+			Context("when user has enabled 2fa", func() {
+				BeforeEachCProvide(func(d *db.Db) *twofactor.Data {
+					user, err := models.GetUserByPhone(d, validPhone1)
+					Expect(err).NotTo(HaveOccurred())
+					secret, err := totp.GenerateSecret()
+					Expect(err).NotTo(HaveOccurred())
+
+					data := &twofactor.Data{UserID: user.ID, Secret: secret, Enabled: true, CreatedAt: time.Now().UTC()}
+					data.SetBackupCodes([]string{backupCode})
+					Expect(twofactor.Save(d, data)).To(Succeed())
+					return data
+				})
+				BeforeEachCProvide(
+					func(d *db.Db, tokens refresh.IStorage, notifier isc.IEventNotificator, deps signinDeps) twoFactorHandler {
+						return twoFactorHandler(SigninTwoFactorHandlerFactory(
+							d, tokens, notifier, deps.phoneLimiter, deps.ipLimiter, deps.tickets,
+						))
+					},
+				)
+				BeforeEachCInvoke(func(tokens *refreshmocks.IStorage) {
+					tokens.On("New", mock.Anything).Return(mockedPair, nil)
+				})
+
+				signin := func(handler base.HandlerFunc) string {
+					data, _, err := handler(CreateSIContext(validPhone1, pass1))
+					Expect(err).NotTo(HaveOccurred())
+					Expect(data).To(BeAssignableToTypeOf(TwoFactorTicketResponse{}))
+					Expect(data.(TwoFactorTicketResponse).TwoFactorRequired).To(BeTrue())
+					return data.(TwoFactorTicketResponse).Ticket
+				}
+				createTwoFactorContext := func(ticket, code string) *gin.Context {
+					return CreateContext("POST", "signin/2fa", map[string]interface{}{
+						"ticket": ticket,
+						"code":   code,
+					})
+				}
+
+				ItD("should return ticket instead of tokens", func(handler base.HandlerFunc, tokens *refreshmocks.IStorage) {
+					Expect(signin(handler)).NotTo(BeEmpty())
+					tokens.AssertNotCalled(GinkgoT(), "New", mock.Anything)
+				})
+
+				ItD("should exchange ticket for tokens using totp code", func(
+					handler base.HandlerFunc, twoFactor twoFactorHandler, data *twofactor.Data,
+				) {
+					ticket := signin(handler)
+					code, err := totp.Code(data.Secret, totp.Step(time.Now()))
+					Expect(err).NotTo(HaveOccurred())
+
+					resp, _, err := twoFactor(createTwoFactorContext(ticket, code))
+					Expect(err).NotTo(HaveOccurred())
+					Expect(resp).To(BeEquivalentTo(tokenResp{
+						Token:        string(mockedToken),
+						RefreshToken: string(mockedToken2),
+					}))
+
+					// ticket is single-use
+					_, _, err = twoFactor(createTwoFactorContext(ticket, code))
+					Expect(err).To(Equal(errInvalidTicket))
+				})
+
+				ItD("should accept backup code only once", func(handler base.HandlerFunc, twoFactor twoFactorHandler) {
+					_, _, err := twoFactor(createTwoFactorContext(signin(handler), backupCode))
+					Expect(err).NotTo(HaveOccurred())
+
+					_, _, err = twoFactor(createTwoFactorContext(signin(handler), backupCode))
+					Expect(err).To(Equal(errInvalidCode))
+				})
+
+				ItD("should fail due to invalid code", func(handler base.HandlerFunc, twoFactor twoFactorHandler) {
+					resp, _, err := twoFactor(createTwoFactorContext(signin(handler), "000000"))
+					Expect(resp).To(BeNil())
+					Expect(err).To(Equal(errInvalidCode))
+				})
+
+				ItD("should keep ticket after invalid code", func(
+					handler base.HandlerFunc, twoFactor twoFactorHandler, data *twofactor.Data,
+				) {
+					ticket := signin(handler)
+					_, _, err := twoFactor(createTwoFactorContext(ticket, "000000"))
+					Expect(err).To(Equal(errInvalidCode))
+
+					code, err := totp.Code(data.Secret, totp.Step(time.Now()))
+					Expect(err).NotTo(HaveOccurred())
+					_, _, err = twoFactor(createTwoFactorContext(ticket, code))
+					Expect(err).NotTo(HaveOccurred())
+				})
+
+				ItD("should not accept claimed ticket", func(
+					handler base.HandlerFunc, twoFactor twoFactorHandler, deps signinDeps,
+				) {
+					ticket := signin(handler)
+					_, err := deps.tickets.Claim(ticket)
+					Expect(err).NotTo(HaveOccurred())
+
+					_, _, err = twoFactor(createTwoFactorContext(ticket, backupCode))
+					Expect(err).To(Equal(errInvalidTicket))
+				})
+
+				ItD("should fail due to unknown ticket", func(twoFactor twoFactorHandler) {
+					resp, _, err := twoFactor(createTwoFactorContext("f47ac10b-58cc-4372-a567-0e02b2c3d479", backupCode))
+					Expect(resp).To(BeNil())
+					Expect(err).To(Equal(errInvalidTicket))
+				})
+			})
+
+			Context("when failed attempts exceed the limit", func() {
+				BeforeEachCInvoke(func(handler base.HandlerFunc, notifier *iscmocks.IEventNotificator) {
+					notifier.On(
+						"SigninLocked", mock.Anything, validPhone1, mock.Anything, mock.Anything,
+					).Return(nil)
+
+					for i := 1; i < maxSigninFailures; i++ {
+						_, _, err := handler(CreateSIContext(validPhone1, pass2))
+						Expect(err).To(Equal(base.NewFieldErr("body", "phone", "either phone or password are invalid")))
+					}
+				})
+
+				ItD("should lock phone and notify user", func(handler base.HandlerFunc, notifier *iscmocks.IEventNotificator) {
+					c := CreateSIContext(validPhone1, pass2)
+					data, _, err := handler(c)
+					Expect(data).To(BeNil())
+					Expect(err).To(Equal(errTooManyAttempts))
+					Expect(c.Writer.Header().Get("Retry-After")).To(Equal("60"))
+					notifier.AssertNumberOfCalls(GinkgoT(), "SigninLocked", 1)
+				})
+
+				ItD("should reject even valid password while locked", func(handler base.HandlerFunc) {
+					_, _, err := handler(CreateSIContext(validPhone1, pass2))
+					Expect(err).To(Equal(errTooManyAttempts))
+
+					c := CreateSIContext(validPhone1, pass1)
+					data, _, err := handler(c)
+					Expect(data).To(BeNil())
+					Expect(err).To(Equal(errTooManyAttempts))
+					Expect(c.Writer.Header().Get("Retry-After")).NotTo(BeEmpty())
+				})
+			})
 		})
 
 		Context("when user doesn't have active status", func() {
@@ -176,22 +438,141 @@ var _ = Describe("Given the auth api", func() {
 		})
 	})
 
+	Context("when querying signin code requests", func() {
+		const signinCode = "123456"
+
+		// signinCodeHandlers holds both signin code steps
+		type signinCodeHandlers struct {
+			start, verify base.HandlerFunc
+		}
+		createVerifyContext := func(code string) *gin.Context {
+			return CreateContext("POST", "signin/code/verify", map[string]interface{}{
+				"phone":             validPhone1,
+				"verification_code": code,
+				"device":            "phone",
+			})
+		}
+
+		BeforeEachCProvide(func() (*iscmocks.IEventNotificator, isc.IEventNotificator) {
+			notifier := &iscmocks.IEventNotificator{}
+			return notifier, notifier
+		})
+		BeforeEachCProvide(func() (*notifmocks.IGenerator, notifications.IGenerator) {
+			generator := &notifmocks.IGenerator{}
+			generator.On("RandomCode").Return(signinCode)
+			return generator, generator
+		})
+		BeforeEachCProvide(func(
+			d *db.Db, tokens refresh.IStorage, notifier isc.IEventNotificator, generator notifications.IGenerator,
+		) signinCodeHandlers {
+			storage := mem.New()
+			params := throttle.Params{
+				Window:      time.Minute,
+				MaxFailures: maxSigninFailures,
+				Lockout:     time.Minute,
+				MaxLockout:  time.Hour,
+			}
+			phoneLimiter := throttle.New(storage, "signin:phone", params, time.Now)
+			ipLimiter := throttle.New(storage, "signin:ip", params, time.Now)
+			flow := SigninCodeFlow(
+				d, notifier, generator, storage, tokens, []byte("secret"), maxVerifyAttempts, time.Minute, time.Minute,
+				phoneLimiter, ipLimiter,
+				NewTwoFactorTickets(storage, time.Minute),
+			)
+			return signinCodeHandlers{
+				start:  flow.StartHandler(),
+				verify: flow.VerifyHandler(),
+			}
+		})
+		BeforeEachCInvoke(func(d *db.Db, h signinCodeHandlers, notifier *iscmocks.IEventNotificator) {
+			user, err := models.NewUser(validPhone1, pass1, models.UserStatusActive, nil)
+			Expect(err).NotTo(HaveOccurred())
+			_, err = models.CreateUser(d, user)
+			Expect(err).NotTo(HaveOccurred())
+
+			notifier.On("SigninVerificationRequested", mock.Anything, validPhone1, signinCode, isc.Delivery{}).Return(nil)
+			_, _, err = h.start(CreateContext("POST", "signin/code/start", map[string]interface{}{
+				"phone": validPhone1,
+			}))
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		ItD("should signin with valid code", func(h signinCodeHandlers, tokens *refreshmocks.IStorage) {
+			tokens.On("New", mock.MatchedBy(func(data map[string]interface{}) bool {
+				return data["phone"] == validPhone1 && data[sessions.DeviceKey] == "phone"
+			})).Return(mockedPair, nil)
+
+			data, _, err := h.verify(createVerifyContext(signinCode))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(data).To(BeEquivalentTo(tokenResp{
+				Token:        string(mockedToken),
+				RefreshToken: string(mockedToken2),
+			}))
+
+			// code is single-use
+			data, _, err = h.verify(createVerifyContext(signinCode))
+			Expect(data).To(BeNil())
+			Expect(err).To(HaveOccurred())
+		})
+
+		ItD("should not send code again too frequently", func(h signinCodeHandlers) {
+			data, _, err := h.start(CreateContext("POST", "signin/code/start", map[string]interface{}{
+				"phone": validPhone1,
+			}))
+			Expect(data).To(BeNil())
+			Expect(err).To(HaveOccurred())
+		})
+
+		ItD("should lock phone after too many wrong codes", func(
+			h signinCodeHandlers, notifier *iscmocks.IEventNotificator,
+		) {
+			notifier.On("SigninLocked", mock.Anything, validPhone1, mock.Anything, mock.Anything).Return(nil)
+
+			for i := 0; i < maxSigninFailures; i++ {
+				_, _, err := h.verify(createVerifyContext("654321"))
+				Expect(err).To(HaveOccurred())
+			}
+
+			data, _, err := h.verify(createVerifyContext(signinCode))
+			Expect(data).To(BeNil())
+			Expect(err).To(Equal(errTooManyAttempts))
+			notifier.AssertNumberOfCalls(GinkgoT(), "SigninLocked", 1)
+		})
+	})
+
 	Context("when querying signout request", func() {
 		BeforeEachCProvide(
-			func(sessStore sessions.IStorage) base.HandlerFunc {
-				return SignoutHandlerFactory(sessStore, tokenName)
+			func(d *db.Db, sessStore sessions.IStorage, tokens refresh.IStorage) base.HandlerFunc {
+				return SignoutHandlerFactory(d, sessStore, tokens, tokenName)
 			},
 		)
+		BeforeEachCProvide(func(d *db.Db) *gin.Context {
+			user, err := models.NewUser(validPhone1, pass1, models.UserStatusActive, nil)
+			Expect(err).NotTo(HaveOccurred())
+			user, err = models.CreateUser(d, user)
+			Expect(err).NotTo(HaveOccurred())
+
+			c := CreateLOContext(mockedToken)
+			c.Set("user_data", map[string]interface{}{"id": user.ID, "phone": validPhone1})
+			return c
+		})
 
 		Context("when token is stored", func() {
 			BeforeEachCInvoke(func(sessStore *sessmocks.IStorage) {
 				sessStore.On("Delete", sessions.Token(mockedToken)).Return(nil)
 			})
 
-			ItD("should logout", func(handler base.HandlerFunc) {
-				data, _, err := handler(CreateLOContext(mockedToken))
+			ItD("should logout and record event", func(d *db.Db, handler base.HandlerFunc, c *gin.Context) {
+				data, _, err := handler(c)
 				Expect(err).NotTo(HaveOccurred())
 				Expect(data).To(BeNil())
+
+				userID, err := getUserID(middlewares.GetUserDataFromContext(c))
+				Expect(err).NotTo(HaveOccurred())
+				events, err := authevents.List(d, userID, 0, 10)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(events).To(HaveLen(1))
+				Expect(events[0].Type).To(Equal(authevents.TypeSignout))
 			})
 		})
 
@@ -200,31 +581,419 @@ var _ = Describe("Given the auth api", func() {
 				sessStore.On("Delete", sessions.Token(mockedToken)).Return(sessions.ErrExpired)
 			})
 
-			ItD("should not return error when token expired", func(handler base.HandlerFunc) {
-				data, _, err := handler(CreateLOContext(mockedToken))
+			ItD("should not return error when token expired", func(handler base.HandlerFunc, c *gin.Context) {
+				data, _, err := handler(c)
 				Expect(err).NotTo(HaveOccurred())
 				Expect(data).To(BeNil())
 			})
 		})
+
+		Context("when session is issued as tokens pair", func() {
+			ItD("should revoke refresh token of the pair", func(d *db.Db, c *gin.Context) {
+				sessStore := sessmem.New(func(data map[string]interface{}) string {
+					return fmt.Sprintf("user:%v:sessions", data["phone"])
+				})
+				tokens := refresh.New(sessStore, mem.New(), time.Minute, time.Hour)
+
+				pair, err := tokens.New(middlewares.GetUserDataFromContext(c))
+				Expect(err).NotTo(HaveOccurred())
+				userData, err := sessStore.Get(pair.Access)
+				Expect(err).NotTo(HaveOccurred())
+
+				signoutCtx := CreateLOContext(pair.Access)
+				signoutCtx.Set("user_data", userData)
+				_, _, err = SignoutHandlerFactory(d, sessStore, tokens, tokenName)(signoutCtx)
+				Expect(err).NotTo(HaveOccurred())
+
+				data, _, err := RefreshTokenHandlerFactory(d, tokens, sessStore, tokenName)(CreateLOContext(pair.Refresh))
+				Expect(data).To(BeNil())
+				Expect(err).To(Equal(base.ErrorView{Code: http.StatusUnauthorized, Message: sessions.ErrNotFound.Error()}))
+			})
+		})
 	})
 
 	Context("when querying refresh token request", func() {
-		BeforeEachCProvide(func(sessStore sessions.IStorage) base.HandlerFunc {
-			return RefreshTokenHandlerFactory(sessStore, tokenName, authExpire)
-		})
-		BeforeEachCInvoke(func(sessStore *sessmocks.IStorage) {
-			sessStore.On("RefreshToken", sessions.Token(mockedToken), authExpire).Return(mockedToken2, nil)
+		BeforeEachCProvide(func(d *db.Db, tokens refresh.IStorage, sessStore sessions.IStorage) base.HandlerFunc {
+			return RefreshTokenHandlerFactory(d, tokens, sessStore, tokenName)
 		})
 
 		Context("when token is stored", func() {
-			ItD("should not return error when token expired", func(handler base.HandlerFunc) {
+			BeforeEachCInvoke(func(d *db.Db, tokens *refreshmocks.IStorage, sessStore *sessmocks.IStorage) {
+				user, err := models.NewUser(validPhone1, pass1, models.UserStatusActive, nil)
+				Expect(err).NotTo(HaveOccurred())
+				user, err = models.CreateUser(d, user)
+				Expect(err).NotTo(HaveOccurred())
+
+				tokens.On("Refresh", sessions.Token(mockedToken)).Return(mockedPair, nil)
+				sessStore.On("Get", mockedPair.Access).Return(map[string]interface{}{"id": user.ID}, nil)
+			})
+
+			ItD("should return new tokens pair", func(handler base.HandlerFunc) {
 				data, _, err := handler(CreateLOContext(mockedToken))
 				Expect(err).NotTo(HaveOccurred())
 				Expect(data).To(BeEquivalentTo(tokenResp{
-					Token: string(mockedToken2),
+					Token:        string(mockedToken),
+					RefreshToken: string(mockedToken2),
 				}))
 			})
 		})
+
+		Context("when token already used", func() {
+			BeforeEachCInvoke(func(tokens *refreshmocks.IStorage) {
+				tokens.On("Refresh", sessions.Token(mockedToken)).Return(refresh.Pair{}, refresh.ErrReused)
+			})
+
+			ItD("should fail with unauthorized", func(handler base.HandlerFunc) {
+				data, _, err := handler(CreateLOContext(mockedToken))
+				Expect(data).To(BeNil())
+				Expect(err).To(Equal(errRefreshTokenReused))
+			})
+		})
+	})
+
+	Context("when querying change password request", func() {
+		userData := map[string]interface{}{
+			"phone":            validPhone1,
+			sessions.DeviceKey: "phone",
+		}
+		createCPContext := func(oldPass, newPass, newPassConf string) *gin.Context {
+			c := CreateContext("POST", "password", map[string]interface{}{
+				"old_password":              oldPass,
+				"new_password":              newPass,
+				"new_password_confirmation": newPassConf,
+			})
+			c.Set("user_data", userData)
+			return c
+		}
+
+		BeforeEachCProvide(func() (*iscmocks.IEventNotificator, isc.IEventNotificator) {
+			notifier := &iscmocks.IEventNotificator{}
+			return notifier, notifier
+		})
+		BeforeEachCProvide(func() *throttle.Limiter {
+			return throttle.New(mem.New(), "signin:phone", throttle.Params{
+				Window:      time.Minute,
+				MaxFailures: maxSigninFailures,
+				Lockout:     time.Minute,
+				MaxLockout:  time.Hour,
+			}, time.Now)
+		})
+		BeforeEachCProvide(func(
+			d *db.Db,
+			sessStore sessions.IStorage,
+			tokens refresh.IStorage,
+			notifier isc.IEventNotificator,
+			phoneLimiter *throttle.Limiter,
+		) base.HandlerFunc {
+			policy := passpolicy.New(passpolicy.Params{MinLength: 6, ForbidPhone: true})
+			return ChangePasswordHandlerFactory(d, sessStore, tokens, notifier, policy, phoneLimiter)
+		})
+		BeforeEachCInvoke(func(d *db.Db) {
+			user, err := models.NewUser(validPhone1, pass1, models.UserStatusActive, nil)
+			Expect(err).NotTo(HaveOccurred())
+			_, err = models.CreateUser(d, user)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		ItD("should change password and revoke sessions", func(
+			d *db.Db,
+			handler base.HandlerFunc,
+			sessStore *sessmocks.IStorage,
+			tokens *refreshmocks.IStorage,
+			notifier *iscmocks.IEventNotificator,
+		) {
+			notifier.On("PasswordChanged", mock.Anything, validPhone1).Return(nil)
+			sessStore.On("DeleteAll", userData).Return(nil)
+			tokens.On("New", mock.MatchedBy(func(data map[string]interface{}) bool {
+				return data["phone"] == validPhone1 && data[sessions.DeviceKey] == "phone"
+			})).Return(mockedPair, nil)
+
+			data, _, err := handler(createCPContext(pass1, "654321", "654321"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(data).To(BeEquivalentTo(tokenResp{
+				Token:        string(mockedToken),
+				RefreshToken: string(mockedToken2),
+			}))
+			notifier.AssertExpectations(GinkgoT())
+			sessStore.AssertExpectations(GinkgoT())
+
+			user, err := models.GetUserByPhone(d, validPhone1)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(user.Password.Compare("654321")).To(BeTrue())
+		})
+
+		ItD("should fail due to wrong old password", func(
+			d *db.Db, handler base.HandlerFunc, notifier *iscmocks.IEventNotificator,
+		) {
+			data, _, err := handler(createCPContext(pass2, "654321", "654321"))
+			Expect(data).To(BeNil())
+			Expect(err).To(Equal(errWrongPassword))
+			notifier.AssertNotCalled(GinkgoT(), "PasswordChanged", mock.Anything, mock.Anything)
+
+			user, err := models.GetUserByPhone(d, validPhone1)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(user.Password.Compare(pass1)).To(BeTrue())
+		})
+
+		ItD("should lock phone after too many wrong old passwords", func(
+			d *db.Db, handler base.HandlerFunc, phoneLimiter *throttle.Limiter,
+		) {
+			for i := 0; i < maxSigninFailures-1; i++ {
+				_, _, err := handler(createCPContext(pass2, "654321", "654321"))
+				Expect(err).To(Equal(errWrongPassword))
+			}
+			_, _, err := handler(createCPContext(pass2, "654321", "654321"))
+			Expect(err).To(Equal(errTooManyAttempts))
+
+			By("even correct password is rejected while phone is locked")
+			_, _, err = handler(createCPContext(pass1, "654321", "654321"))
+			Expect(err).To(Equal(errTooManyAttempts))
+			_, locked, err := phoneLimiter.LockedUntil(validPhone1)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(locked).To(BeTrue())
+
+			user, err := models.GetUserByPhone(d, validPhone1)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(user.Password.Compare(pass1)).To(BeTrue())
+		})
+
+		ItD("should fail due to password policy violation", func(
+			d *db.Db, handler base.HandlerFunc, notifier *iscmocks.IEventNotificator,
+		) {
+			data, _, err := handler(createCPContext(pass1, "a1111111", "a1111111"))
+			Expect(data).To(BeNil())
+			Expect(err).To(Equal(base.NewFieldErrs("body", "new_password", "password mustn't contain phone number")))
+			notifier.AssertNotCalled(GinkgoT(), "PasswordChanged", mock.Anything, mock.Anything)
+
+			user, err := models.GetUserByPhone(d, validPhone1)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(user.Password.Compare(pass1)).To(BeTrue())
+		})
+
+		ItD("should accept password with symbols", func(
+			d *db.Db,
+			handler base.HandlerFunc,
+			sessStore *sessmocks.IStorage,
+			tokens *refreshmocks.IStorage,
+			notifier *iscmocks.IEventNotificator,
+		) {
+			notifier.On("PasswordChanged", mock.Anything, validPhone1).Return(nil)
+			sessStore.On("DeleteAll", userData).Return(nil)
+			tokens.On("New", mock.Anything).Return(mockedPair, nil)
+
+			_, _, err := handler(createCPContext(pass1, "c0rrect-h0rse!", "c0rrect-h0rse!"))
+			Expect(err).NotTo(HaveOccurred())
+
+			user, err := models.GetUserByPhone(d, validPhone1)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(user.Password.Compare("c0rrect-h0rse!")).To(BeTrue())
+		})
+
+		ItD("should check password length using policy", func(handler base.HandlerFunc) {
+			data, _, err := handler(createCPContext(pass1, "a1b2c", "a1b2c"))
+			Expect(data).To(BeNil())
+			Expect(err).To(Equal(base.NewFieldErrs("body", "new_password", "password must be at least 6 characters long")))
+		})
+
+		ItD("should fail due to confirmation mismatch", func(handler base.HandlerFunc) {
+			data, _, err := handler(createCPContext(pass1, "654321", "123456"))
+			Expect(data).To(BeNil())
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Context("when querying sessions requests", func() {
+		sessData := map[string]interface{}{
+			"phone":               validPhone1,
+			sessions.SessionIDKey: "id1",
+		}
+		createSessContext := func(method string) *gin.Context {
+			c := CreateContext(method, "sessions", nil)
+			c.Set("user_data", sessData)
+			return c
+		}
+
+		Context("when listing sessions", func() {
+			BeforeEachCProvide(func() *activitymocks.ITracker {
+				return &activitymocks.ITracker{}
+			})
+			BeforeEachCProvide(func(sessStore sessions.IStorage, tracker *activitymocks.ITracker) base.HandlerFunc {
+				return SessionsListHandlerFactory(sessStore, tracker)
+			})
+
+			ItD("should mark current session", func(
+				handler base.HandlerFunc, sessStore *sessmocks.IStorage, tracker *activitymocks.ITracker,
+			) {
+				sessStore.On("List", sessData).Return([]sessions.Session{{ID: "id1"}, {ID: "id2"}}, nil)
+				tracker.On("LastSeen", mock.Anything).Return(time.Time{}, nil)
+
+				data, _, err := handler(createSessContext("GET"))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(data).To(Equal(SessionsResponse{Sessions: []SessionView{
+					{ID: "id1", Current: true},
+					{ID: "id2", Current: false},
+				}}))
+			})
+
+			ItD("should return sessions metadata", func(
+				handler base.HandlerFunc, sessStore *sessmocks.IStorage, tracker *activitymocks.ITracker,
+			) {
+				createdAt := time.Unix(1500000000, 0)
+				lastSeen := time.Unix(1500000600, 0)
+				sessStore.On("List", sessData).Return([]sessions.Session{{ID: "id1", Data: map[string]interface{}{
+					sessions.IPKey:        "127.0.0.1",
+					sessions.UserAgentKey: "agent",
+					sessions.DeviceKey:    "phone",
+					sessions.CreatedAtKey: float64(createdAt.Unix()),
+				}}}, nil)
+				tracker.On("LastSeen", "id1").Return(lastSeen, nil)
+
+				data, _, err := handler(createSessContext("GET"))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(data).To(Equal(SessionsResponse{Sessions: []SessionView{{
+					ID:        "id1",
+					Current:   true,
+					IP:        "127.0.0.1",
+					UserAgent: "agent",
+					Device:    "phone",
+					CreatedAt: createdAt.Unix(),
+					LastSeen:  lastSeen.Unix(),
+				}}}))
+			})
+
+			ItD("should list tokens pair as single session", func(
+				handler base.HandlerFunc, sessStore *sessmocks.IStorage, tracker *activitymocks.ITracker,
+			) {
+				lastSeen := time.Unix(1500000600, 0)
+				sessStore.On("List", sessData).Return([]sessions.Session{
+					{ID: "id1"},
+					{ID: "id4", Data: map[string]interface{}{
+						sessions.TokenFamilyKey: "family1",
+						sessions.TokenTypeKey:   "access",
+						sessions.IPKey:          "127.0.0.1",
+					}},
+					{ID: "id5", Data: map[string]interface{}{
+						sessions.TokenFamilyKey: "family1",
+						sessions.TokenTypeKey:   "refresh",
+						sessions.IPKey:          "127.0.0.1",
+					}},
+				}, nil)
+				tracker.On("LastSeen", "id4").Return(lastSeen, nil)
+				tracker.On("LastSeen", mock.Anything).Return(time.Time{}, nil)
+
+				data, _, err := handler(createSessContext("GET"))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(data).To(Equal(SessionsResponse{Sessions: []SessionView{
+					{ID: "id1", Current: true},
+					{ID: "family1", Current: false, IP: "127.0.0.1", LastSeen: lastSeen.Unix()},
+				}}))
+			})
+
+			ItD("should mark current tokens pair", func(
+				handler base.HandlerFunc, sessStore *sessmocks.IStorage, tracker *activitymocks.ITracker,
+			) {
+				pairData := map[string]interface{}{
+					"phone":                 validPhone1,
+					sessions.SessionIDKey:   "id4",
+					sessions.TokenFamilyKey: "family1",
+				}
+				sessStore.On("List", pairData).Return([]sessions.Session{
+					{ID: "id4", Data: map[string]interface{}{sessions.TokenFamilyKey: "family1"}},
+					{ID: "id5", Data: map[string]interface{}{sessions.TokenFamilyKey: "family1"}},
+				}, nil)
+				tracker.On("LastSeen", mock.Anything).Return(time.Time{}, nil)
+
+				c := CreateContext("GET", "sessions", nil)
+				c.Set("user_data", pairData)
+				data, _, err := handler(c)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(data).To(Equal(SessionsResponse{Sessions: []SessionView{{ID: "family1", Current: true}}}))
+			})
+
+			ItD("should fail when storage doesn't support listing", func(handler base.HandlerFunc, sessStore *sessmocks.IStorage) {
+				sessStore.On("List", sessData).Return(nil, sessions.ErrNotSupported)
+
+				_, _, err := handler(createSessContext("GET"))
+				Expect(err).To(Equal(errNotSupported))
+			})
+		})
+
+		Context("when deleting session by id", func() {
+			BeforeEachCProvide(func(sessStore sessions.IStorage, tokens refresh.IStorage) base.HandlerFunc {
+				return SessionDeleteHandlerFactory(sessStore, tokens)
+			})
+			BeforeEachCInvoke(func(sessStore *sessmocks.IStorage) {
+				sessStore.On("List", sessData).Return([]sessions.Session{
+					{ID: "id1"},
+					{ID: "id2", Data: map[string]interface{}{sessions.DeviceKey: "phone"}},
+					{ID: "id3"},
+					{ID: "id4", Data: map[string]interface{}{sessions.TokenFamilyKey: "family1"}},
+					{ID: "id5", Data: map[string]interface{}{sessions.TokenFamilyKey: "family1"}},
+				}, nil)
+			})
+
+			ItD("should delete session", func(handler base.HandlerFunc, sessStore *sessmocks.IStorage) {
+				sessStore.On("DeleteByID", sessData, "id2").Return(nil)
+
+				c := createSessContext("DELETE")
+				c.Params = gin.Params{{Key: "id", Value: "id2"}}
+				data, _, err := handler(c)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(data).To(BeNil())
+			})
+
+			ItD("should return not found", func(handler base.HandlerFunc, sessStore *sessmocks.IStorage) {
+				sessStore.On("DeleteByID", sessData, "id6").Return(sessions.ErrNotFound)
+
+				c := createSessContext("DELETE")
+				c.Params = gin.Params{{Key: "id", Value: "id6"}}
+				_, _, err := handler(c)
+				Expect(err).To(Equal(errSessionNotFound))
+			})
+
+			ItD("should fail if session family is unknown", func(
+				handler base.HandlerFunc, sessStore *sessmocks.IStorage, tokens *refreshmocks.IStorage,
+			) {
+				c := createSessContext("DELETE")
+				c.Params = gin.Params{{Key: "id", Value: "id3"}}
+				_, _, err := handler(c)
+				Expect(err).To(Equal(errSessionFamilyUnknown))
+				sessStore.AssertNotCalled(GinkgoT(), "DeleteByID", mock.Anything, mock.Anything)
+				tokens.AssertNotCalled(GinkgoT(), "Revoke", mock.Anything)
+			})
+
+			for _, id := range []string{"id4", "family1"} {
+				id := id
+				ItD(fmt.Sprintf("should revoke whole tokens family by %s", id), func(
+					handler base.HandlerFunc, sessStore *sessmocks.IStorage, tokens *refreshmocks.IStorage,
+				) {
+					tokens.On("Revoke", "family1").Return(nil)
+
+					c := createSessContext("DELETE")
+					c.Params = gin.Params{{Key: "id", Value: id}}
+					data, _, err := handler(c)
+					Expect(err).NotTo(HaveOccurred())
+					Expect(data).To(BeNil())
+					tokens.AssertExpectations(GinkgoT())
+					sessStore.AssertNotCalled(GinkgoT(), "DeleteByID", mock.Anything, mock.Anything)
+				})
+			}
+		})
+
+		Context("when deleting all sessions", func() {
+			BeforeEachCProvide(func(sessStore sessions.IStorage) base.HandlerFunc {
+				return SessionsDeleteAllHandlerFactory(sessStore)
+			})
+
+			ItD("should delete all sessions", func(handler base.HandlerFunc, sessStore *sessmocks.IStorage) {
+				sessStore.On("DeleteAll", sessData).Return(nil)
+
+				data, _, err := handler(createSessContext("DELETE"))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(data).To(BeNil())
+				sessStore.AssertExpectations(GinkgoT())
+			})
+		})
 	})
 
 	Context("when querying check request", func() {
@@ -248,4 +1017,64 @@ var _ = Describe("Given the auth api", func() {
 			Expect(err.Error()).To(Equal("auth passed but no user data attached"))
 		})
 	})
+
+	Context("when querying security events", func() {
+		BeforeEachCProvide(func(d *db.Db) models.User {
+			user, err := models.NewUser(validPhone1, pass1, models.UserStatusActive, nil)
+			Expect(err).NotTo(HaveOccurred())
+			user, err = models.CreateUser(d, user)
+			Expect(err).NotTo(HaveOccurred())
+
+			for _, eventType := range []authevents.Type{
+				authevents.TypeSignupFinished, authevents.TypeSigninFailed, authevents.TypeSignin,
+			} {
+				_, err = authevents.Create(d, authevents.Event{
+					UserID:    &user.ID,
+					Type:      eventType,
+					IP:        "127.0.0.1",
+					UserAgent: "test",
+					CreatedAt: time.Now().UTC(),
+				})
+				Expect(err).NotTo(HaveOccurred())
+			}
+			return user
+		})
+		BeforeEachCProvide(func(d *db.Db) base.HandlerFunc {
+			return SecurityEventsHandlerFactory(d)
+		})
+
+		createSEContext := func(user models.User, query string) *gin.Context {
+			c := CreateContext("GET", "security-events?"+query, nil)
+			c.Set("user_data", map[string]interface{}{"id": user.ID, "phone": validPhone1})
+			return c
+		}
+
+		ItD("should return events page by page newest first", func(handler base.HandlerFunc, user models.User) {
+			data, _, err := handler(createSEContext(user, "limit=2"))
+			Expect(err).NotTo(HaveOccurred())
+
+			page := data.(SecurityEventsResponse)
+			Expect(page.Events).To(HaveLen(2))
+			Expect(page.Events[0].Type).To(Equal(string(authevents.TypeSignin)))
+			Expect(page.Events[0].IP).To(Equal("127.0.0.1"))
+			Expect(page.Events[1].Type).To(Equal(string(authevents.TypeSigninFailed)))
+			Expect(page.Next).NotTo(BeNil())
+
+			data, _, err = handler(createSEContext(user, "limit=2&before="+*page.Next))
+			Expect(err).NotTo(HaveOccurred())
+
+			page = data.(SecurityEventsResponse)
+			Expect(page.Events).To(HaveLen(1))
+			Expect(page.Events[0].Type).To(Equal(string(authevents.TypeSignupFinished)))
+			Expect(page.Next).To(BeNil())
+		})
+
+		ItD("should fail due to invalid paging", func(handler base.HandlerFunc, user models.User) {
+			_, _, err := handler(createSEContext(user, "limit=1000"))
+			Expect(err).To(Equal(errInvalidLimit))
+
+			_, _, err = handler(createSEContext(user, "before=abc"))
+			Expect(err).To(Equal(errInvalidBefore))
+		})
+	})
 })
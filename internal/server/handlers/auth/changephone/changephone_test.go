@@ -0,0 +1,301 @@
+package changephone
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"git.zam.io/wallet-backend/web-api/db"
+	. "git.zam.io/wallet-backend/web-api/fixtures"
+	"git.zam.io/wallet-backend/web-api/fixtures/database"
+	"git.zam.io/wallet-backend/web-api/fixtures/database/migrations"
+	models "git.zam.io/wallet-backend/web-api/internal/models/user"
+	"git.zam.io/wallet-backend/web-api/internal/server/handlers/auth/recovery"
+	"git.zam.io/wallet-backend/web-api/internal/services/isc"
+	iscmock "git.zam.io/wallet-backend/web-api/internal/services/isc/mocks"
+	"git.zam.io/wallet-backend/web-api/internal/services/notifications"
+	notifmock "git.zam.io/wallet-backend/web-api/internal/services/notifications/mocks"
+	"git.zam.io/wallet-backend/web-api/pkg/server/handlers/base"
+	"git.zam.io/wallet-backend/web-api/pkg/services/nosql"
+	"git.zam.io/wallet-backend/web-api/pkg/services/nosql/mem"
+	"git.zam.io/wallet-backend/web-api/pkg/services/sessions"
+	sessmock "git.zam.io/wallet-backend/web-api/pkg/services/sessions/mocks"
+	"git.zam.io/wallet-backend/web-api/pkg/services/sessions/refresh"
+	refreshmock "git.zam.io/wallet-backend/web-api/pkg/services/sessions/refresh/mocks"
+	"git.zam.io/wallet-backend/web-api/pkg/services/throttle"
+	"github.com/gin-gonic/gin"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/stretchr/testify/mock"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+const (
+	validPhone1  = "+79871111111"
+	validPhone2  = "+79871111112"
+	validPhone3  = "+79871111113"
+	pass1        = "123451"
+	oldCode      = "111111"
+	newCode      = "222222"
+	changeToken  = "CHANGEPHONETOKEN"
+	authToken    = "AUTH TOKEN"
+	refreshToken = "REFRESH TOKEN"
+
+	maxVerifyAttempts = 5
+)
+
+func TestChangePhoneHandlers(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "ChangePhone Handlers Suite")
+}
+
+func createContext(phone string, body interface{}) *gin.Context {
+	bodyCont, err := json.Marshal(body)
+	if err != nil {
+		panic(err)
+	}
+
+	req, err := http.NewRequest("POST", "NOT DEFINED", bytes.NewBuffer(bodyCont))
+	if err != nil {
+		panic(err)
+	}
+
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = req
+	c.Set("user_data", map[string]interface{}{
+		"phone":            phone,
+		sessions.DeviceKey: "phone",
+	})
+	return c
+}
+
+// handlers holds all flow steps
+type handlers struct {
+	start, verify, finish base.HandlerFunc
+}
+
+var _ = Describe("Given user change phone flow", func() {
+	Init()
+	database.Init()
+	migrations.Init()
+
+	BeforeEachCProvide(func() nosql.IStorage {
+		return mem.New()
+	})
+	BeforeEachCProvide(func() (*iscmock.IEventNotificator, isc.IEventNotificator) {
+		s := &iscmock.IEventNotificator{}
+		return s, s
+	})
+	BeforeEachCProvide(func() (*notifmock.IGenerator, notifications.IGenerator) {
+		g := &notifmock.IGenerator{}
+		g.On("RandomCode").Return(oldCode).Once()
+		g.On("RandomCode").Return(newCode).Once()
+		g.On("RandomToken").Return(changeToken)
+		return g, g
+	})
+	BeforeEachCProvide(func() (*sessmock.IStorage, sessions.IStorage) {
+		s := &sessmock.IStorage{}
+		return s, s
+	})
+	BeforeEachCProvide(func() (*refreshmock.IStorage, refresh.IStorage) {
+		s := &refreshmock.IStorage{}
+		return s, s
+	})
+	BeforeEachCProvide(func(storage nosql.IStorage) *throttle.Limiter {
+		return throttle.New(storage, "signin:phone", throttle.Params{
+			Window:      time.Minute,
+			MaxFailures: 3,
+			Lockout:     time.Minute,
+			MaxLockout:  time.Hour,
+		}, time.Now)
+	})
+	BeforeEachCProvide(func(
+		d *db.Db,
+		storage nosql.IStorage,
+		notifier isc.IEventNotificator,
+		generator notifications.IGenerator,
+		sessStorage sessions.IStorage,
+		tokens refresh.IStorage,
+		phoneLimiter *throttle.Limiter,
+	) handlers {
+		flow := NewFlow(
+			d, notifier, generator, storage, sessStorage, tokens, phoneLimiter, []string{recovery.FlowKeyPattern},
+			[]byte("secret"), maxVerifyAttempts, time.Minute, time.Minute,
+		)
+		return handlers{
+			start:  flow.StartHandler(),
+			verify: flow.VerifyHandler(),
+			finish: flow.FinishHandler(),
+		}
+	})
+	BeforeEachCInvoke(func(d *db.Db) {
+		for _, phone := range []string{validPhone1, validPhone3} {
+			user, err := models.NewUser(phone, pass1, models.UserStatusActive, nil)
+			Expect(err).NotTo(HaveOccurred())
+			_, err = models.CreateUser(d, user)
+			Expect(err).NotTo(HaveOccurred())
+		}
+	})
+
+	ItD("should change phone confirmed by both phones", func(
+		d *db.Db,
+		h handlers,
+		storage nosql.IStorage,
+		notifier *iscmock.IEventNotificator,
+		sessStorage *sessmock.IStorage,
+		tokens *refreshmock.IStorage,
+		phoneLimiter *throttle.Limiter,
+	) {
+		notifier.On("PhoneChangeVerificationRequested", mock.Anything, validPhone1, oldCode, isc.Delivery{}).Return(nil)
+		notifier.On("PhoneChangeVerificationRequested", mock.Anything, validPhone2, newCode, isc.Delivery{}).Return(nil)
+		notifier.On("PhoneChanged", mock.Anything, validPhone1, validPhone2).Return(nil)
+		sessStorage.On("DeleteAll", map[string]interface{}{"phone": validPhone1}).Return(nil)
+		tokens.On("New", mock.MatchedBy(func(data map[string]interface{}) bool {
+			return data["phone"] == validPhone2 && data[sessions.DeviceKey] == "phone"
+		})).Return(refresh.Pair{Access: sessions.Token(authToken), Refresh: sessions.Token(refreshToken)}, nil)
+
+		Expect(storage.Set(fmt.Sprintf(recovery.FlowKeyPattern, validPhone1), "record")).To(Succeed())
+		for i := 0; i < 3; i++ {
+			_, _, err := phoneLimiter.Fail(validPhone1)
+			Expect(err).NotTo(HaveOccurred())
+		}
+
+		_, _, err := h.start(createContext(validPhone1, map[string]interface{}{"new_phone": validPhone2}))
+		Expect(err).NotTo(HaveOccurred())
+
+		resp, _, err := h.verify(createContext(validPhone1, map[string]interface{}{
+			"verification_code":           oldCode,
+			"new_phone_verification_code": newCode,
+		}))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(resp).To(Equal(TokenView{Token: changeToken}))
+
+		resp, _, err = h.finish(createContext(validPhone1, map[string]interface{}{"change_phone_token": changeToken}))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(resp).To(Equal(FinishResponse{Token: authToken, RefreshToken: refreshToken}))
+		notifier.AssertExpectations(GinkgoT())
+		sessStorage.AssertExpectations(GinkgoT())
+
+		_, err = models.GetUserByPhone(d, validPhone2)
+		Expect(err).NotTo(HaveOccurred())
+		_, err = models.GetUserByPhone(d, validPhone1)
+		Expect(err).To(Equal(models.ErrUserNotFound))
+
+		// flows and signin lock follow the user
+		_, err = storage.Get(fmt.Sprintf(recovery.FlowKeyPattern, validPhone1))
+		Expect(err).To(Equal(nosql.ErrNoSuchKeyFound))
+		Expect(storage.Get(fmt.Sprintf(recovery.FlowKeyPattern, validPhone2))).To(Equal("record"))
+		_, err = storage.Get(fmt.Sprintf(FlowKeyPattern, validPhone2))
+		Expect(err).NotTo(HaveOccurred())
+		_, locked, err := phoneLimiter.LockedUntil(validPhone1)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(locked).To(BeFalse())
+		_, locked, err = phoneLimiter.LockedUntil(validPhone2)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(locked).To(BeTrue())
+	})
+
+	ItD("should not emit event until phone change is committed", func(
+		d *db.Db,
+		h handlers,
+		storage nosql.IStorage,
+		notifier *iscmock.IEventNotificator,
+		sessStorage *sessmock.IStorage,
+		tokens *refreshmock.IStorage,
+	) {
+		notifier.On("PhoneChangeVerificationRequested", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+		tokens.On("New", mock.Anything).Return(refresh.Pair{}, errors.New("tokens storage is unavailable"))
+		Expect(storage.Set(fmt.Sprintf(recovery.FlowKeyPattern, validPhone1), "record")).To(Succeed())
+
+		_, _, err := h.start(createContext(validPhone1, map[string]interface{}{"new_phone": validPhone2}))
+		Expect(err).NotTo(HaveOccurred())
+		_, _, err = h.verify(createContext(validPhone1, map[string]interface{}{
+			"verification_code":           oldCode,
+			"new_phone_verification_code": newCode,
+		}))
+		Expect(err).NotTo(HaveOccurred())
+
+		_, _, err = h.finish(createContext(validPhone1, map[string]interface{}{"change_phone_token": changeToken}))
+		Expect(err).To(HaveOccurred())
+		notifier.AssertNotCalled(GinkgoT(), "PhoneChanged", mock.Anything, mock.Anything, mock.Anything)
+		sessStorage.AssertNotCalled(GinkgoT(), "DeleteAll", mock.Anything)
+
+		_, err = models.GetUserByPhone(d, validPhone1)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(storage.Get(fmt.Sprintf(recovery.FlowKeyPattern, validPhone1))).To(Equal("record"))
+
+		By("finish token and new phone remain valid after failure")
+		notifier.On("PhoneChanged", mock.Anything, validPhone1, validPhone2).Return(nil)
+		sessStorage.On("DeleteAll", map[string]interface{}{"phone": validPhone1}).Return(nil)
+		tokens.ExpectedCalls = nil
+		tokens.On("New", mock.Anything).Return(
+			refresh.Pair{Access: sessions.Token(authToken), Refresh: sessions.Token(refreshToken)}, nil,
+		)
+
+		resp, _, err := h.finish(createContext(validPhone1, map[string]interface{}{"change_phone_token": changeToken}))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(resp).To(Equal(FinishResponse{Token: authToken, RefreshToken: refreshToken}))
+		notifier.AssertExpectations(GinkgoT())
+
+		_, err = models.GetUserByPhone(d, validPhone2)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	ItD("should fail when new phone code is wrong", func(h handlers, notifier *iscmock.IEventNotificator) {
+		notifier.On("PhoneChangeVerificationRequested", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+
+		_, _, err := h.start(createContext(validPhone1, map[string]interface{}{"new_phone": validPhone2}))
+		Expect(err).NotTo(HaveOccurred())
+
+		resp, _, err := h.verify(createContext(validPhone1, map[string]interface{}{
+			"verification_code":           oldCode,
+			"new_phone_verification_code": oldCode,
+		}))
+		Expect(resp).To(BeNil())
+		Expect(err).To(Equal(errFieldWrongNewCode))
+
+		// flow code remains valid
+		resp, _, err = h.verify(createContext(validPhone1, map[string]interface{}{
+			"verification_code":           oldCode,
+			"new_phone_verification_code": newCode,
+		}))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(resp).To(Equal(TokenView{Token: changeToken}))
+	})
+
+	ItD("should burn new phone code after too many wrong attempts", func(h handlers, notifier *iscmock.IEventNotificator) {
+		notifier.On("PhoneChangeVerificationRequested", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+
+		_, _, err := h.start(createContext(validPhone1, map[string]interface{}{"new_phone": validPhone2}))
+		Expect(err).NotTo(HaveOccurred())
+
+		verify := func(code string) error {
+			_, _, err := h.verify(createContext(validPhone1, map[string]interface{}{
+				"verification_code":           oldCode,
+				"new_phone_verification_code": code,
+			}))
+			return err
+		}
+		for i := 1; i < maxVerifyAttempts; i++ {
+			Expect(verify(oldCode)).To(Equal(errFieldWrongNewCode))
+		}
+		Expect(verify(oldCode)).To(Equal(errFieldNewCodeBurned))
+		Expect(verify(newCode)).To(Equal(errFieldWrongNewCode))
+	})
+
+	ItD("should fail when new phone is taken", func(h handlers, notifier *iscmock.IEventNotificator) {
+		resp, _, err := h.start(createContext(validPhone1, map[string]interface{}{"new_phone": validPhone3}))
+		Expect(resp).To(BeNil())
+		Expect(err).To(HaveOccurred())
+		notifier.AssertNotCalled(GinkgoT(), "PhoneChangeVerificationRequested", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	ItD("should fail when new phone is the same", func(h handlers) {
+		resp, _, err := h.start(createContext(validPhone1, map[string]interface{}{"new_phone": validPhone1}))
+		Expect(resp).To(BeNil())
+		Expect(err).To(HaveOccurred())
+	})
+})
@@ -0,0 +1,310 @@
+package changephone
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"git.zam.io/wallet-backend/common/pkg/types"
+	"git.zam.io/wallet-backend/web-api/db"
+	models "git.zam.io/wallet-backend/web-api/internal/models/user"
+	confflow "git.zam.io/wallet-backend/web-api/internal/server/handlers/flows/confirmation"
+	"git.zam.io/wallet-backend/web-api/internal/services/isc"
+	"git.zam.io/wallet-backend/web-api/internal/services/notifications"
+	"git.zam.io/wallet-backend/web-api/pkg/server/handlers/base"
+	"git.zam.io/wallet-backend/web-api/pkg/server/middlewares"
+	"git.zam.io/wallet-backend/web-api/pkg/services/nosql"
+	"git.zam.io/wallet-backend/web-api/pkg/services/sessions"
+	"git.zam.io/wallet-backend/web-api/pkg/services/sessions/refresh"
+	"git.zam.io/wallet-backend/web-api/pkg/services/throttle"
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+)
+
+var (
+	errUserNotFound       = base.ErrorView{Code: http.StatusNotFound, Message: "user not found"}
+	errPhoneTaken         = base.ErrorView{Code: http.StatusBadRequest, Message: "phone already in use"}
+	errExpired            = base.ErrorView{Code: http.StatusBadRequest, Message: "phone change expired"}
+	errFieldSamePhone     = base.NewFieldErr("body", "new_phone", "new phone must differ from the current one")
+	errFieldPhoneTaken    = base.NewFieldErr("body", "new_phone", "phone already in use")
+	errFieldWrongNewCode  = base.NewFieldErr("body", "new_phone_verification_code", "code is wrong")
+	errFieldNewCodeBurned = base.NewFieldErr(
+		"body", "new_phone_verification_code", "too many wrong attempts, new code must be requested",
+	)
+	errUserPhoneIsMissing = errors.New("changephone: user phone is missing in the session data")
+)
+
+// Flow keys are bound to the current user phone, since it's changed only on finish. New phone record holds the new
+// phone and it's own verification code.
+const (
+	FlowKeyPattern     = "user:%s:change_phone:flow"
+	NewPhoneKeyPattern = "user:%s:change_phone:new_phone"
+)
+
+func userKey(pattern string, phone types.Phone) string {
+	return fmt.Sprintf(pattern, phone)
+}
+
+// NewFlow creates phone change confirmation flow, user is identified by the session. Verification codes are sent to
+// both current and new user phones. Once phone is changed, nosql keys of the user flows (this flow keys and keys
+// matching given patterns) and signin failures are moved to the new phone. Session tokens hold the phone itself, so
+// the old phone sessions are revoked instead of being moved, otherwise they would identify the next owner of the
+// number, new tokens pair is issued for the current client.
+func NewFlow(
+	d *db.Db,
+	notifier isc.IEventNotificator,
+	generator notifications.IGenerator,
+	storage nosql.IStorage,
+	sessStorage sessions.IStorage,
+	tokens refresh.IStorage,
+	phoneLimiter *throttle.Limiter,
+	userKeyPatterns []string,
+	secret []byte,
+	maxVerifyAttempts int,
+	storageExpire time.Duration,
+	notifSendTO time.Duration,
+) *confflow.Flow {
+	transitionNewPhone := func(user models.User, apply func(r *confflow.Record) (*confflow.Record, error)) error {
+		return confflow.Transition(storage, userKey(NewPhoneKeyPattern, user.Phone), storageExpire, apply)
+	}
+	movedKeyPatterns := append([]string{FlowKeyPattern, NewPhoneKeyPattern}, userKeyPatterns...)
+
+	return &confflow.Flow{
+		Resources: confflow.ExternalResources{
+			Database:  d,
+			Storage:   storage,
+			Generator: generator,
+		},
+		StateKey:          FlowKeyPattern,
+		Secret:            secret,
+		Expire:            storageExpire,
+		NotifSendTO:       notifSendTO,
+		MaxVerifyAttempts: maxVerifyAttempts,
+		GetUser: func(c *gin.Context, tx db.ITx, request interface{}) (user models.User, err error) {
+			phone, err := getUserPhone(c)
+			if err != nil {
+				return
+			}
+			user, err = getUser(tx, phone)
+			if err != nil {
+				return
+			}
+
+			switch params := request.(type) {
+			case *StartRequest:
+				err = checkNewPhone(tx, user, params.NewPhone)
+			case *VerifyRequest:
+				// new phone code is checked before the flow code, so the flow code remains valid if this one is wrong,
+				// valid code is consumed once the flow code is verified
+				err = transitionNewPhone(user, func(r *confflow.Record) (*confflow.Record, error) {
+					return confflow.CheckCode(r, secret, params.NewPhoneCode, maxVerifyAttempts)
+				})
+				switch err {
+				case confflow.ErrFieldWrongCode:
+					err = errFieldWrongNewCode
+				case confflow.ErrFieldCodeBurned:
+					err = errFieldNewCodeBurned
+				}
+			}
+			return
+		},
+		Start: confflow.StartStep{
+			Request: func() interface{} {
+				return &StartRequest{}
+			},
+			SendCode: func(c *gin.Context, user models.User, code string, delivery isc.Delivery) error {
+				return notifier.PhoneChangeVerificationRequested(fmt.Sprint(user.ID), string(user.Phone), code, delivery)
+			},
+			OnStarted: func(c *gin.Context, tx db.ITx, user models.User, request interface{}) error {
+				// current phone is verified by the flow itself, so new phone requires it's own code
+				newPhone, err := types.NewPhone(request.(*StartRequest).NewPhone)
+				if err != nil {
+					return err
+				}
+
+				// new phone has no verified email, so only phone channels are available
+				newPhoneCode := generator.RandomCode()
+				delivery := isc.Delivery{Channel: request.(*StartRequest).DeliveryChannel()}
+				err = notifier.PhoneChangeVerificationRequested(
+					fmt.Sprint(user.ID), string(newPhone), newPhoneCode, delivery,
+				)
+				if err != nil {
+					return err
+				}
+
+				// new code replaces previous one along with it's attempts, start frequency is limited by the flow
+				now := time.Now()
+				next := &confflow.Record{
+					State:    confflow.StatePending,
+					CodeHash: confflow.HashSecret(secret, newPhoneCode),
+					ExpireAt: now.Add(storageExpire),
+					SentAt:   now,
+					Subject:  string(newPhone),
+				}
+				return transitionNewPhone(user, func(*confflow.Record) (*confflow.Record, error) {
+					return next, nil
+				})
+			},
+		},
+		Verify: confflow.VerifyStep{
+			Request: func() confflow.CodeRequest {
+				return &VerifyRequest{}
+			},
+			OnVerified: func(c *gin.Context, tx db.ITx, user models.User, request interface{}) error {
+				return transitionNewPhone(user, func(r *confflow.Record) (*confflow.Record, error) {
+					if r == nil || r.State != confflow.StatePending || r.Expired() {
+						return nil, errExpired
+					}
+					next := *r
+					next.State = confflow.StateVerified
+					next.CodeHash = ""
+					next.Attempts = 0
+					next.ExpireAt = time.Now().Add(storageExpire)
+					return &next, nil
+				})
+			},
+			TokenView: func(token string) interface{} {
+				return TokenView{Token: token}
+			},
+		},
+		Finish: &confflow.FinishStep{
+			Request: func() confflow.TokenRequest {
+				return &FinishRequest{}
+			},
+			TokenField: "change_phone_token",
+			OnFinished: func(c *gin.Context, tx db.ITx, user models.User, request interface{}) error {
+				// new phone record is only read here, since it must remain valid if finish fails, concurrent finish is
+				// prevented by the flow token. It's consumed once phone change is committed.
+				var newPhone string
+				err := transitionNewPhone(user, func(r *confflow.Record) (*confflow.Record, error) {
+					if r == nil || r.State != confflow.StateVerified || r.Expired() {
+						return nil, errExpired
+					}
+					newPhone = r.Subject
+					return nil, nil
+				})
+				if err != nil {
+					return err
+				}
+
+				user.Phone = types.Phone(newPhone)
+				err = models.UpdateUser(tx, user)
+				if err == models.ErrUserAlreadyExists {
+					// phone has been taken since start
+					err = errPhoneTaken
+				}
+				if err != nil {
+					return err
+				}
+
+				request.(*FinishRequest).newPhone = string(user.Phone)
+				return nil
+			},
+			Response: func(c *gin.Context, tx db.ITx, user models.User, request interface{}) (resp interface{}, err error) {
+				newPhone := request.(*FinishRequest).newPhone
+
+				// issue tokens for the current client
+				userData := middlewares.GetUserDataFromContext(c)
+				device, _ := userData[sessions.DeviceKey].(string)
+
+				roles, err := models.GetUserRoles(tx, user.ID)
+				if err != nil {
+					return
+				}
+
+				data := middlewares.SessionMetadata(c, device)
+				data["id"] = user.ID
+				data["phone"] = newPhone
+				data[middlewares.RolesKey] = roles
+
+				pair, err := tokens.New(data)
+				if err != nil {
+					return
+				}
+				resp = FinishResponse{Token: string(pair.Access), RefreshToken: string(pair.Refresh)}
+				return
+			},
+			OnCommitted: func(c *gin.Context, user models.User, request interface{}) error {
+				oldPhone, newPhone := string(user.Phone), request.(*FinishRequest).newPhone
+
+				err := transitionNewPhone(user, func(r *confflow.Record) (*confflow.Record, error) {
+					if r == nil {
+						return nil, nil
+					}
+					next := *r
+					next.State = confflow.StateFinished
+					return &next, nil
+				})
+				if err != nil {
+					return err
+				}
+
+				err = phoneLimiter.Move(oldPhone, newPhone)
+				if err != nil {
+					return err
+				}
+				err = moveUserKeys(storage, movedKeyPatterns, user.Phone, types.Phone(newPhone))
+				if err != nil {
+					return err
+				}
+
+				// sessions are revoked using the old phone, since their data holds it
+				err = sessStorage.DeleteAll(map[string]interface{}{"phone": oldPhone})
+				if err != nil && err != sessions.ErrNotSupported {
+					return err
+				}
+
+				return notifier.PhoneChanged(fmt.Sprint(user.ID), oldPhone, newPhone)
+			},
+		},
+	}
+}
+
+// utils
+func getUser(tx db.ITx, phone string) (user models.User, err error) {
+	user, err = models.GetUserByPhoneAndStatus(tx, phone, models.UserStatusActive, true)
+	if err == models.ErrUserNotFound {
+		err = errUserNotFound
+	}
+	return
+}
+
+func checkNewPhone(tx db.ITx, user models.User, rawPhone string) error {
+	newPhone, err := types.NewPhone(rawPhone)
+	if err != nil {
+		return err
+	}
+	if newPhone == user.Phone {
+		return errFieldSamePhone
+	}
+
+	_, err = models.GetUserByPhone(tx, string(newPhone))
+	switch err {
+	case nil:
+		return errFieldPhoneTaken
+	case models.ErrUserNotFound:
+		return nil
+	default:
+		return err
+	}
+}
+
+// moveUserKeys moves keys bound to the old phone under the new one keeping their expiration, so the next owner of the
+// number can't be affected by the flows started by the user
+func moveUserKeys(storage nosql.IStorage, patterns []string, oldPhone, newPhone types.Phone) error {
+	for _, pattern := range patterns {
+		err := storage.Rename(userKey(pattern, oldPhone), userKey(pattern, newPhone))
+		if err != nil && err != nosql.ErrNoSuchKeyFound {
+			return err
+		}
+	}
+	return nil
+}
+
+func getUserPhone(c *gin.Context) (string, error) {
+	phone, ok := middlewares.GetUserDataFromContext(c)["phone"].(string)
+	if !ok {
+		return "", errUserPhoneIsMissing
+	}
+	return phone, nil
+}
@@ -0,0 +1,34 @@
+package changephone
+
+import confflow "git.zam.io/wallet-backend/web-api/internal/server/handlers/flows/confirmation"
+
+// StartRequest
+type StartRequest struct {
+	NewPhone string `json:"new_phone" validate:"required,phone"`
+
+	confflow.ChannelParam
+}
+
+// VerifyRequest holds codes sent to both old and new phones
+type VerifyRequest struct {
+	Code         string `json:"verification_code" validate:"required,min=6"`
+	NewPhoneCode string `json:"new_phone_verification_code" validate:"required,min=6"`
+}
+
+// VerificationCode implements confirmation.CodeRequest
+func (r *VerifyRequest) VerificationCode() string {
+	return r.Code
+}
+
+// FinishRequest
+type FinishRequest struct {
+	Token string `json:"change_phone_token" validate:"required"`
+
+	// newPhone confirmed phone, filled during finish
+	newPhone string
+}
+
+// FinishToken implements confirmation.TokenRequest
+func (r *FinishRequest) FinishToken() string {
+	return r.Token
+}
@@ -0,0 +1,12 @@
+package changephone
+
+// TokenView
+type TokenView struct {
+	Token string `json:"change_phone_token"`
+}
+
+// FinishResponse holds tokens of the new session, since all previous sessions are revoked
+type FinishResponse struct {
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+}
@@ -0,0 +1,311 @@
+package deletion
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"git.zam.io/wallet-backend/web-api/db"
+	. "git.zam.io/wallet-backend/web-api/fixtures"
+	"git.zam.io/wallet-backend/web-api/fixtures/database"
+	"git.zam.io/wallet-backend/web-api/fixtures/database/migrations"
+	"git.zam.io/wallet-backend/web-api/internal/models/kyc"
+	models "git.zam.io/wallet-backend/web-api/internal/models/user"
+	confflow "git.zam.io/wallet-backend/web-api/internal/server/handlers/flows/confirmation"
+	"git.zam.io/wallet-backend/web-api/internal/services/isc"
+	iscmock "git.zam.io/wallet-backend/web-api/internal/services/isc/mocks"
+	"git.zam.io/wallet-backend/web-api/internal/services/notifications"
+	notifmock "git.zam.io/wallet-backend/web-api/internal/services/notifications/mocks"
+	"git.zam.io/wallet-backend/web-api/pkg/server/handlers/base"
+	"git.zam.io/wallet-backend/web-api/pkg/services/nosql"
+	"git.zam.io/wallet-backend/web-api/pkg/services/nosql/mem"
+	"git.zam.io/wallet-backend/web-api/pkg/services/sessions"
+	sessmock "git.zam.io/wallet-backend/web-api/pkg/services/sessions/mocks"
+	"git.zam.io/wallet-backend/web-api/pkg/services/throttle"
+	"github.com/gin-gonic/gin"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/stretchr/testify/mock"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+const (
+	validPhone1   = "+79871111111"
+	pass1         = "123451"
+	pass2         = "543211"
+	code          = "111111"
+	deletionToken = "DELETIONTOKEN"
+
+	maxVerifyAttempts = 5
+	maxFailures       = 3
+)
+
+func TestDeletionHandlers(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Deletion Handlers Suite")
+}
+
+func createContext(body interface{}) *gin.Context {
+	bodyCont, err := json.Marshal(body)
+	if err != nil {
+		panic(err)
+	}
+
+	req, err := http.NewRequest("POST", "NOT DEFINED", bytes.NewBuffer(bodyCont))
+	if err != nil {
+		panic(err)
+	}
+
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = req
+	c.Set("user_data", map[string]interface{}{"phone": validPhone1})
+	return c
+}
+
+// handlers holds all flow steps
+type handlers struct {
+	start, verify, finish base.HandlerFunc
+}
+
+var _ = Describe("Given user account deletion flow", func() {
+	Init()
+	database.Init()
+	migrations.Init()
+
+	BeforeEachCProvide(func() nosql.IStorage {
+		return mem.New()
+	})
+	BeforeEachCProvide(func() (*iscmock.IEventNotificator, isc.IEventNotificator) {
+		s := &iscmock.IEventNotificator{}
+		return s, s
+	})
+	BeforeEachCProvide(func() (*notifmock.IGenerator, notifications.IGenerator) {
+		g := &notifmock.IGenerator{}
+		g.On("RandomCode").Return(code)
+		g.On("RandomToken").Return(deletionToken)
+		return g, g
+	})
+	BeforeEachCProvide(func() (*sessmock.IStorage, sessions.IStorage) {
+		s := &sessmock.IStorage{}
+		return s, s
+	})
+	BeforeEachCProvide(func(storage nosql.IStorage) *throttle.Limiter {
+		return throttle.New(storage, "signin:phone", throttle.Params{
+			Window:      time.Minute,
+			MaxFailures: maxFailures,
+			Lockout:     time.Minute,
+			MaxLockout:  time.Hour,
+		}, time.Now)
+	})
+	BeforeEachCProvide(func(
+		d *db.Db,
+		storage nosql.IStorage,
+		notifier isc.IEventNotificator,
+		generator notifications.IGenerator,
+		sessStorage sessions.IStorage,
+		phoneLimiter *throttle.Limiter,
+	) handlers {
+		flow := NewFlow(
+			d, notifier, generator, storage, sessStorage, phoneLimiter, []byte("secret"), maxVerifyAttempts, time.Minute,
+			time.Minute,
+		)
+		return handlers{
+			start:  flow.StartHandler(),
+			verify: flow.VerifyHandler(),
+			finish: flow.FinishHandler(),
+		}
+	})
+	BeforeEachCProvide(func(d *db.Db) models.User {
+		user, err := models.NewUser(validPhone1, pass1, models.UserStatusActive, nil)
+		Expect(err).NotTo(HaveOccurred())
+		user, err = models.CreateUser(d, user)
+		Expect(err).NotTo(HaveOccurred())
+
+		_, err = kyc.Create(d, &kyc.Data{
+			UserID:    user.ID,
+			Status:    kyc.StatusPending,
+			Email:     "test@example.com",
+			FirstName: "First",
+			LastName:  "Last",
+			BirthDate: time.Date(1990, 1, 1, 0, 0, 0, 0, time.UTC),
+			Sex:       "male",
+			Country:   "Country",
+			Address:   map[string]interface{}{"city": "City"},
+		})
+		Expect(err).NotTo(HaveOccurred())
+		return user
+	})
+
+	ItD("should delete account", func(
+		d *db.Db,
+		h handlers,
+		user models.User,
+		notifier *iscmock.IEventNotificator,
+		sessStorage *sessmock.IStorage,
+	) {
+		notifier.On("AccountDeletionVerificationRequested", mock.Anything, validPhone1, code, isc.Delivery{}).Return(nil)
+		notifier.On("AccountDeleted", mock.Anything, validPhone1).Return(nil)
+		sessStorage.On("DeleteAll", map[string]interface{}{"phone": validPhone1}).Return(nil)
+
+		_, _, err := h.start(createContext(map[string]interface{}{"password": pass1}))
+		Expect(err).NotTo(HaveOccurred())
+
+		resp, _, err := h.verify(createContext(map[string]interface{}{"verification_code": code}))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(resp).To(Equal(TokenView{Token: deletionToken}))
+
+		_, _, err = h.finish(createContext(map[string]interface{}{"deletion_token": deletionToken}))
+		Expect(err).NotTo(HaveOccurred())
+		notifier.AssertExpectations(GinkgoT())
+		sessStorage.AssertExpectations(GinkgoT())
+
+		_, err = models.GetUserByPhone(d, validPhone1)
+		Expect(err).To(Equal(models.ErrUserNotFound))
+
+		deleted, err := models.GetUserByID(d, fmt.Sprint(user.ID))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(deleted.Status).To(Equal(models.UserStatusDeleted))
+
+		data, err := kyc.Get(d, user.ID)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(data.Email).To(BeEmpty())
+		Expect(data.FirstName).To(BeEmpty())
+		Expect(data.LastName).To(BeEmpty())
+	})
+
+	ItD("should allow to finish again after failure", func(
+		d *db.Db, h handlers, notifier *iscmock.IEventNotificator, sessStorage *sessmock.IStorage,
+	) {
+		notifier.On("AccountDeletionVerificationRequested", mock.Anything, validPhone1, code, isc.Delivery{}).Return(nil)
+		notifier.On("AccountDeleted", mock.Anything, validPhone1).Return(nil)
+		sessStorage.On("DeleteAll", map[string]interface{}{"phone": validPhone1}).
+			Return(errors.New("sessions storage is unavailable")).Once()
+		sessStorage.On("DeleteAll", map[string]interface{}{"phone": validPhone1}).Return(nil).Once()
+
+		_, _, err := h.start(createContext(map[string]interface{}{"password": pass1}))
+		Expect(err).NotTo(HaveOccurred())
+		_, _, err = h.verify(createContext(map[string]interface{}{"verification_code": code}))
+		Expect(err).NotTo(HaveOccurred())
+
+		_, _, err = h.finish(createContext(map[string]interface{}{"deletion_token": deletionToken}))
+		Expect(err).To(HaveOccurred())
+		_, err = models.GetUserByPhone(d, validPhone1)
+		Expect(err).NotTo(HaveOccurred())
+
+		By("failed finish doesn't consume token")
+		_, _, err = h.finish(createContext(map[string]interface{}{"deletion_token": deletionToken}))
+		Expect(err).NotTo(HaveOccurred())
+		sessStorage.AssertExpectations(GinkgoT())
+
+		_, err = models.GetUserByPhone(d, validPhone1)
+		Expect(err).To(Equal(models.ErrUserNotFound))
+	})
+
+	ItD("should burn code after too many wrong attempts", func(h handlers, notifier *iscmock.IEventNotificator) {
+		notifier.On("AccountDeletionVerificationRequested", mock.Anything, validPhone1, code, isc.Delivery{}).Return(nil)
+
+		_, _, err := h.start(createContext(map[string]interface{}{"password": pass1}))
+		Expect(err).NotTo(HaveOccurred())
+
+		for i := 1; i < maxVerifyAttempts; i++ {
+			_, _, err = h.verify(createContext(map[string]interface{}{"verification_code": "222222"}))
+			Expect(err).To(Equal(confflow.ErrFieldWrongCode))
+		}
+		_, _, err = h.verify(createContext(map[string]interface{}{"verification_code": "222222"}))
+		Expect(err).To(Equal(confflow.ErrFieldCodeBurned))
+
+		By("valid code is rejected too, since it's burned")
+		resp, _, err := h.verify(createContext(map[string]interface{}{"verification_code": code}))
+		Expect(resp).To(BeNil())
+		Expect(err).To(Equal(confflow.ErrFieldWrongCode))
+	})
+
+	ItD("should allow to start again right after code sending failed", func(
+		h handlers, notifier *iscmock.IEventNotificator,
+	) {
+		notifier.On("AccountDeletionVerificationRequested", mock.Anything, validPhone1, code, isc.Delivery{}).
+			Return(errors.New("notificator is unavailable")).Once()
+		notifier.On("AccountDeletionVerificationRequested", mock.Anything, validPhone1, code, isc.Delivery{}).
+			Return(nil).Once()
+
+		_, _, err := h.start(createContext(map[string]interface{}{"password": pass1}))
+		Expect(err).To(HaveOccurred())
+
+		By("failed attempt doesn't store code, so next attempt isn't throttled")
+		_, _, err = h.start(createContext(map[string]interface{}{"password": pass1}))
+		Expect(err).NotTo(HaveOccurred())
+		notifier.AssertExpectations(GinkgoT())
+
+		resp, _, err := h.verify(createContext(map[string]interface{}{"verification_code": code}))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(resp).To(Equal(TokenView{Token: deletionToken}))
+
+		By("successful attempt is throttled")
+		_, _, err = h.start(createContext(map[string]interface{}{"password": pass1}))
+		Expect(err).To(HaveOccurred())
+	})
+
+	ItD("should send code to the verified kyc email", func(
+		d *db.Db, h handlers, user models.User, notifier *iscmock.IEventNotificator,
+	) {
+		_, err := d.Exec(
+			`update personal_data set status_id = (select id from personal_data_statuses where name = 'verified')
+			where user_id = $1`,
+			user.ID,
+		)
+		Expect(err).NotTo(HaveOccurred())
+
+		delivery := isc.Delivery{Channel: notifications.ChannelEmail, Email: "test@example.com"}
+		notifier.On("AccountDeletionVerificationRequested", mock.Anything, validPhone1, code, delivery).Return(nil)
+
+		_, _, err = h.start(createContext(map[string]interface{}{"password": pass1, "channel": "email"}))
+		Expect(err).NotTo(HaveOccurred())
+		notifier.AssertExpectations(GinkgoT())
+	})
+
+	ItD("should not pass unverified kyc email", func(h handlers, notifier *iscmock.IEventNotificator) {
+		delivery := isc.Delivery{Channel: notifications.ChannelEmail}
+		notifier.On("AccountDeletionVerificationRequested", mock.Anything, validPhone1, code, delivery).Return(nil)
+
+		_, _, err := h.start(createContext(map[string]interface{}{"password": pass1, "channel": "email"}))
+		Expect(err).NotTo(HaveOccurred())
+		notifier.AssertExpectations(GinkgoT())
+	})
+
+	ItD("should reject unknown channel", func(h handlers, notifier *iscmock.IEventNotificator) {
+		_, _, err := h.start(createContext(map[string]interface{}{"password": pass1, "channel": "pigeon"}))
+		Expect(err).To(Equal(base.NewFieldErr("body", "channel", "value must be one of [sms, call, email]")))
+	})
+
+	ItD("should fail due to wrong password", func(h handlers, notifier *iscmock.IEventNotificator) {
+		resp, _, err := h.start(createContext(map[string]interface{}{"password": pass2}))
+		Expect(resp).To(BeNil())
+		Expect(err).To(Equal(errFieldWrongPassword))
+		notifier.AssertNotCalled(GinkgoT(), "AccountDeletionVerificationRequested", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	ItD("should lock phone after too many wrong passwords", func(
+		h handlers, notifier *iscmock.IEventNotificator, phoneLimiter *throttle.Limiter,
+	) {
+		for i := 0; i < maxFailures-1; i++ {
+			_, _, err := h.start(createContext(map[string]interface{}{"password": pass2}))
+			Expect(err).To(Equal(errFieldWrongPassword))
+		}
+		_, _, err := h.start(createContext(map[string]interface{}{"password": pass2}))
+		Expect(err).To(Equal(errTooManyAttempts))
+
+		By("even correct password is rejected while phone is locked")
+		_, _, err = h.start(createContext(map[string]interface{}{"password": pass1}))
+		Expect(err).To(Equal(errTooManyAttempts))
+		notifier.AssertNotCalled(
+			GinkgoT(), "AccountDeletionVerificationRequested", mock.Anything, mock.Anything, mock.Anything, mock.Anything,
+		)
+
+		_, locked, err := phoneLimiter.LockedUntil(validPhone1)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(locked).To(BeTrue())
+	})
+})
@@ -0,0 +1,189 @@
+package deletion
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"git.zam.io/wallet-backend/web-api/db"
+	"git.zam.io/wallet-backend/web-api/internal/models/kyc"
+	"git.zam.io/wallet-backend/web-api/internal/models/twofactor"
+	models "git.zam.io/wallet-backend/web-api/internal/models/user"
+	confflow "git.zam.io/wallet-backend/web-api/internal/server/handlers/flows/confirmation"
+	"git.zam.io/wallet-backend/web-api/internal/services/isc"
+	"git.zam.io/wallet-backend/web-api/internal/services/notifications"
+	"git.zam.io/wallet-backend/web-api/pkg/server/handlers/base"
+	"git.zam.io/wallet-backend/web-api/pkg/server/middlewares"
+	"git.zam.io/wallet-backend/web-api/pkg/services/nosql"
+	"git.zam.io/wallet-backend/web-api/pkg/services/sessions"
+	"git.zam.io/wallet-backend/web-api/pkg/services/throttle"
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+)
+
+var (
+	errUserNotFound       = base.ErrorView{Code: http.StatusNotFound, Message: "user not found"}
+	errFieldWrongPassword = base.NewFieldErr("body", "password", "password is wrong")
+	errTooManyAttempts    = base.ErrorView{Code: http.StatusTooManyRequests, Message: "too many attempts"}
+	errUserPhoneIsMissing = errors.New("deletion: user phone is missing in the session data")
+)
+
+// FlowKeyPattern deletion flow record key, it's bound to the user phone
+const FlowKeyPattern = "user:%s:deletion:flow"
+
+// NewFlow creates account deletion confirmation flow, user is identified by the session. Finish moves user into
+// deleted status, phone and kyc data are anonymized and all sessions are revoked. Wrong password is counted as failed
+// signin attempt of the phone, so phone limiter must be the one signin failures are counted by.
+func NewFlow(
+	d *db.Db,
+	notifier isc.IEventNotificator,
+	generator notifications.IGenerator,
+	storage nosql.IStorage,
+	sessStorage sessions.IStorage,
+	phoneLimiter *throttle.Limiter,
+	secret []byte,
+	maxVerifyAttempts int,
+	storageExpire time.Duration,
+	notifSendTO time.Duration,
+) *confflow.Flow {
+	return &confflow.Flow{
+		Resources: confflow.ExternalResources{
+			Database:  d,
+			Storage:   storage,
+			Generator: generator,
+		},
+		StateKey:          FlowKeyPattern,
+		Secret:            secret,
+		Expire:            storageExpire,
+		NotifSendTO:       notifSendTO,
+		MaxVerifyAttempts: maxVerifyAttempts,
+		GetUser: func(c *gin.Context, tx db.ITx, request interface{}) (user models.User, err error) {
+			phone, err := getUserPhone(c)
+			if err != nil {
+				return
+			}
+			user, err = getUser(tx, phone)
+			if err != nil {
+				return
+			}
+
+			// password is required only to start deletion
+			params, ok := request.(*StartRequest)
+			if !ok {
+				return
+			}
+
+			// locked phone isn't even checked for password
+			err = checkLocked(c, phoneLimiter, phone)
+			if err != nil {
+				return
+			}
+			passEqual, err := user.Password.Compare(params.Password)
+			if err != nil {
+				return
+			}
+			if !passEqual {
+				err = registerFailure(c, phoneLimiter, phone, errFieldWrongPassword)
+				return
+			}
+			err = phoneLimiter.Reset(phone)
+			return
+		},
+		Start: confflow.StartStep{
+			Request: func() interface{} {
+				return &StartRequest{}
+			},
+			SendCode: func(c *gin.Context, user models.User, code string, delivery isc.Delivery) error {
+				return notifier.AccountDeletionVerificationRequested(fmt.Sprint(user.ID), string(user.Phone), code, delivery)
+			},
+		},
+		Verify: confflow.VerifyStep{
+			Request: func() confflow.CodeRequest {
+				return &VerifyRequest{}
+			},
+			TokenView: func(token string) interface{} {
+				return TokenView{Token: token}
+			},
+		},
+		Finish: &confflow.FinishStep{
+			Request: func() confflow.TokenRequest {
+				return &FinishRequest{}
+			},
+			TokenField: "deletion_token",
+			OnFinished: func(c *gin.Context, tx db.ITx, user models.User, request interface{}) error {
+				err := kyc.Anonymize(tx, user.ID)
+				if err != nil {
+					return err
+				}
+
+				err = twofactor.Delete(tx, user.ID)
+				if err != nil && err != twofactor.ErrNotFound {
+					return err
+				}
+
+				_, err = models.DeleteUser(tx, user)
+				return err
+			},
+			Notify: func(user models.User) error {
+				return notifier.AccountDeleted(fmt.Sprint(user.ID), string(user.Phone))
+			},
+			Response: func(c *gin.Context, tx db.ITx, user models.User, request interface{}) (interface{}, error) {
+				// sessions hold the phone user had before deletion
+				err := sessStorage.DeleteAll(map[string]interface{}{"phone": string(user.Phone)})
+				if err == sessions.ErrNotSupported {
+					err = nil
+				}
+				return nil, err
+			},
+		},
+	}
+}
+
+// utils
+func getUser(tx db.ITx, phone string) (user models.User, err error) {
+	user, err = models.GetUserByPhoneAndStatus(tx, phone, models.UserStatusActive, true)
+	if err == models.ErrUserNotFound {
+		err = errUserNotFound
+	}
+	return
+}
+
+// checkLocked returns too many attempts error if phone is locked due to failed attempts
+func checkLocked(c *gin.Context, limiter *throttle.Limiter, phone string) error {
+	until, locked, err := limiter.LockedUntil(phone)
+	if err != nil || !locked {
+		return err
+	}
+	return tooManyAttempts(c, until)
+}
+
+// registerFailure counts wrong password as failed attempt of the phone, given error is returned if lock isn't reached
+func registerFailure(c *gin.Context, limiter *throttle.Limiter, phone string, failErr error) error {
+	until, locked, err := limiter.Fail(phone)
+	if err != nil {
+		return err
+	}
+	if locked {
+		return tooManyAttempts(c, until)
+	}
+	return failErr
+}
+
+func tooManyAttempts(c *gin.Context, until time.Time) error {
+	retryAfter := int64(math.Ceil(time.Until(until).Seconds()))
+	if retryAfter < 1 {
+		retryAfter = 1
+	}
+	c.Header("Retry-After", strconv.FormatInt(retryAfter, 10))
+	return errTooManyAttempts
+}
+
+func getUserPhone(c *gin.Context) (string, error) {
+	phone, ok := middlewares.GetUserDataFromContext(c)["phone"].(string)
+	if !ok {
+		return "", errUserPhoneIsMissing
+	}
+	return phone, nil
+}
@@ -0,0 +1,30 @@
+package deletion
+
+import confflow "git.zam.io/wallet-backend/web-api/internal/server/handlers/flows/confirmation"
+
+// StartRequest requires password, so stolen access token isn't enough to start deletion
+type StartRequest struct {
+	Password string `json:"password" validate:"required"`
+
+	confflow.ChannelParam
+}
+
+// VerifyRequest
+type VerifyRequest struct {
+	Code string `json:"verification_code" validate:"required,min=6"`
+}
+
+// VerificationCode implements confirmation.CodeRequest
+func (r *VerifyRequest) VerificationCode() string {
+	return r.Code
+}
+
+// FinishRequest
+type FinishRequest struct {
+	Token string `json:"deletion_token" validate:"required"`
+}
+
+// FinishToken implements confirmation.TokenRequest
+func (r *FinishRequest) FinishToken() string {
+	return r.Token
+}
@@ -0,0 +1,17 @@
+package deletion
+
+import (
+	"git.zam.io/wallet-backend/web-api/internal/server/handlers/auth/dependencies"
+	"git.zam.io/wallet-backend/web-api/pkg/services/throttle"
+	"github.com/gin-gonic/gin"
+)
+
+// Register creates and registers /user/me/deletion routes with given dependencies, group must be protected by the
+// auth middleware. Phone limiter must be the one signin failures are counted by.
+func Register(group gin.IRouter, deps dependencies.Dependencies, phoneLimiter *throttle.Limiter) gin.IRouter {
+	return NewFlow(
+		deps.Db, deps.Notificator, deps.Generator, deps.Storage, deps.SessStorage, phoneLimiter,
+		[]byte(deps.Conf.Auth.FlowSecret), deps.Conf.Auth.MaxVerifyAttempts,
+		deps.Conf.Auth.SignUpTokenExpire, deps.Conf.Auth.SignUpRetryDelay,
+	).Register(group)
+}
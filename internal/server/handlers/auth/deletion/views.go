@@ -0,0 +1,6 @@
+package deletion
+
+// TokenView
+type TokenView struct {
+	Token string `json:"deletion_token"`
+}
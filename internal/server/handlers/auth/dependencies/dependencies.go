@@ -7,7 +7,10 @@ import (
 	"git.zam.io/wallet-backend/web-api/internal/services/notifications"
 	"git.zam.io/wallet-backend/web-api/internal/services/stats"
 	"git.zam.io/wallet-backend/web-api/pkg/services/nosql"
+	"git.zam.io/wallet-backend/web-api/pkg/services/passpolicy"
 	"git.zam.io/wallet-backend/web-api/pkg/services/sessions"
+	"git.zam.io/wallet-backend/web-api/pkg/services/sessions/activity"
+	"git.zam.io/wallet-backend/web-api/pkg/services/sessions/refresh"
 	"github.com/gin-gonic/gin"
 	"go.uber.org/dig"
 )
@@ -19,11 +22,14 @@ type Dependencies struct {
 	Routes         gin.IRouter `name:"api_routes"`
 	Db             *db.Db
 	SessStorage    sessions.IStorage
+	Tokens         refresh.IStorage
+	Tracker        activity.ITracker
 	Notificator    isc.IEventNotificator
 	AuthMiddleware gin.HandlerFunc `name:"auth"`
 	Generator      notifications.IGenerator
 	Storage        nosql.IStorage
 	StatsGetter    stats.IUserWalletsGetter
+	PasswordPolicy *passpolicy.Policy
 
 	Conf server.Scheme
 }
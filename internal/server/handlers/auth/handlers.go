@@ -1,29 +1,80 @@
 package auth
 
 import (
+	"fmt"
+	"math"
+	"net/http"
 	"strconv"
 	"time"
 
+	"git.zam.io/wallet-backend/common/pkg/types"
 	"git.zam.io/wallet-backend/web-api/db"
+	"git.zam.io/wallet-backend/web-api/internal/models/authevents"
 	"git.zam.io/wallet-backend/web-api/internal/models/kyc"
+	"git.zam.io/wallet-backend/web-api/internal/models/twofactor"
 	models "git.zam.io/wallet-backend/web-api/internal/models/user"
+	"git.zam.io/wallet-backend/web-api/internal/server/handlers/auth/audit"
+	confflow "git.zam.io/wallet-backend/web-api/internal/server/handlers/flows/confirmation"
+	"git.zam.io/wallet-backend/web-api/internal/services/isc"
+	"git.zam.io/wallet-backend/web-api/internal/services/notifications"
 	"git.zam.io/wallet-backend/web-api/internal/services/stats"
 	"git.zam.io/wallet-backend/web-api/pkg/server/handlers/base"
 	"git.zam.io/wallet-backend/web-api/pkg/server/middlewares"
+	"git.zam.io/wallet-backend/web-api/pkg/services/nosql"
+	"git.zam.io/wallet-backend/web-api/pkg/services/passpolicy"
 	"git.zam.io/wallet-backend/web-api/pkg/services/sessions"
+	"git.zam.io/wallet-backend/web-api/pkg/services/sessions/activity"
+	"git.zam.io/wallet-backend/web-api/pkg/services/sessions/refresh"
+	"git.zam.io/wallet-backend/web-api/pkg/services/throttle"
 	"github.com/gin-gonic/gin"
+	"github.com/mitchellh/mapstructure"
 	"github.com/pkg/errors"
 )
 
-var errWrongUserOrPass = base.NewFieldErr("body", "phone", "either phone or password are invalid")
+var (
+	errWrongUserOrPass = base.NewFieldErr("body", "phone", "either phone or password are invalid")
+	errSessionNotFound = base.ErrorView{Code: http.StatusNotFound, Message: "session not found"}
+	errNotSupported    = base.ErrorView{
+		Code:    http.StatusBadRequest,
+		Message: "sessions management not supported by the current sessions storage",
+	}
+	errRefreshTokenReused = base.ErrorView{
+		Code:    http.StatusUnauthorized,
+		Message: refresh.ErrReused.Error(),
+	}
+	errTooManyAttempts = base.ErrorView{Code: http.StatusTooManyRequests, Message: "too many attempts"}
+	errInvalidTicket   = base.ErrorView{Code: http.StatusUnauthorized, Message: "2fa ticket is invalid or expired"}
+	errInvalidCode     = base.NewFieldErr("body", "code", "code is invalid")
+	errWrongPassword   = base.NewFieldErr("body", "old_password", "password is invalid")
+	errWrongUser       = base.NewFieldErr("body", "phone", "user not found")
+	errInvalidLimit    = base.NewFieldErr("query", "limit", fmt.Sprintf("limit must be between 1 and %d", maxEventsLimit))
+	errInvalidBefore   = base.NewFieldErr("query", "before", "before must be event id")
+
+	errSessionFamilyUnknown = errors.New("tokens family of the session is unknown, since session data is missing")
+)
+
+// Security events paging
+const (
+	defaultEventsLimit = 20
+	maxEventsLimit     = 100
+)
+
+// SigninCodeFlowKeyPattern one-time signin code flow record key, it's bound to the user phone
+const SigninCodeFlowKeyPattern = "user:%s:signin:flow"
 
-// SigninHandlerFactory returns handler which perform user authorization, requires session storage to store newly
-// created session
+// SigninHandlerFactory returns handler which perform user authorization, requires tokens storage to issue access and
+// refresh tokens of the newly created session. Failed attempts are limited both per phone and per ip, locked phone or
+// ip gets too many attempts error with Retry-After header. User with enabled 2FA gets ticket instead of tokens.
 func SigninHandlerFactory(
 	d *db.Db,
-	sessStorage sessions.IStorage,
-	authExpiration time.Duration,
+	tokens refresh.IStorage,
+	notifier isc.IEventNotificator,
+	phoneLimiter *throttle.Limiter,
+	ipLimiter *throttle.Limiter,
+	tickets *TwoFactorTickets,
 ) base.HandlerFunc {
+	limits := signinLimits{d: d, phone: phoneLimiter, ip: ipLimiter, notifier: notifier}
+
 	return func(c *gin.Context) (resp interface{}, code int, err error) {
 		params := UserSigninRequest{}
 		err = base.ShouldBindJSON(c, &params)
@@ -31,11 +82,18 @@ func SigninHandlerFactory(
 			return
 		}
 
+		// locked phone or ip isn't even checked for password
+		ip := middlewares.ClientIP(c)
+		err = limits.check(c, params.Phone, ip)
+		if err != nil {
+			return
+		}
+
 		// attempt to find user
 		user, err := models.GetUserByPhoneAndStatus(d, params.Phone, models.UserStatusActive)
 		if err != nil {
 			if err == models.ErrUserNotFound {
-				err = errWrongUserOrPass
+				err = limits.fail(c, params.Phone, ip, nil, errWrongUserOrPass)
 			}
 			return
 		}
@@ -46,62 +104,360 @@ func SigninHandlerFactory(
 			return
 		}
 		if !passEqual {
-			err = errWrongUserOrPass
+			err = limits.fail(c, params.Phone, ip, &user, errWrongUserOrPass)
 			return
 		}
 
-		// create new session token
-		token, err := sessStorage.New(map[string]interface{}{
-			"id":    user.ID,
-			"phone": string(user.Phone),
-		}, authExpiration)
+		err = limits.phone.Reset(params.Phone)
 		if err != nil {
-			// token not created, so whole handler failed
 			return
 		}
 
-		// all is ok, auth has been passed
-		resp = UserTokenResponse{Token: string(token)}
+		resp, err = signinResponse(c, d, tokens, notifier, tickets, user, params.Device)
 		return
 	}
 }
 
-// SignoutHandlerFactory returns signout handler
-func SignoutHandlerFactory(sessStorage sessions.IStorage, tokenName string) base.HandlerFunc {
+// SigninCodeFlow creates one-time signin code flow, code is exchanged for the session tokens right after it's
+// verified. Code sending is limited the same way as other confirmation flows, locked phone or ip doesn't receive codes.
+// Wrong codes are limited the same way as wrong passwords. User with enabled 2FA gets ticket instead of tokens.
+func SigninCodeFlow(
+	d *db.Db,
+	notifier isc.IEventNotificator,
+	generator notifications.IGenerator,
+	storage nosql.IStorage,
+	tokens refresh.IStorage,
+	secret []byte,
+	maxVerifyAttempts int,
+	codeExpire time.Duration,
+	notifSendTO time.Duration,
+	phoneLimiter *throttle.Limiter,
+	ipLimiter *throttle.Limiter,
+	tickets *TwoFactorTickets,
+) *confflow.Flow {
+	limits := signinLimits{d: d, phone: phoneLimiter, ip: ipLimiter, notifier: notifier}
+
+	return &confflow.Flow{
+		Resources: confflow.ExternalResources{
+			Database:  d,
+			Storage:   storage,
+			Generator: generator,
+		},
+		StateKey:          SigninCodeFlowKeyPattern,
+		Secret:            secret,
+		Expire:            codeExpire,
+		NotifSendTO:       notifSendTO,
+		MaxVerifyAttempts: maxVerifyAttempts,
+		GetUser: func(c *gin.Context, tx db.ITx, request interface{}) (user models.User, err error) {
+			phone := signinCodePhone(request)
+			err = limits.check(c, phone, middlewares.ClientIP(c))
+			if err != nil {
+				return
+			}
+
+			user, err = models.GetUserByPhoneAndStatus(tx, phone, models.UserStatusActive, true)
+			if err != models.ErrUserNotFound {
+				return
+			}
+			if _, verify := request.(*UserSigninCodeVerifyRequest); verify {
+				// no code has been issued for the unknown phone, it's reported as wrong code to not reveal whether
+				// user exists
+				err = confflow.ErrFieldWrongCode
+			} else {
+				err = limits.fail(c, phone, middlewares.ClientIP(c), nil, errWrongUser)
+			}
+			return
+		},
+		Start: confflow.StartStep{
+			Request: func() interface{} {
+				return &UserSigninCodeStartRequest{}
+			},
+			SendCode: func(c *gin.Context, user models.User, code string, delivery isc.Delivery) error {
+				return notifier.SigninVerificationRequested(fmt.Sprint(user.ID), string(user.Phone), code, delivery)
+			},
+		},
+		Verify: confflow.VerifyStep{
+			Request: func() confflow.CodeRequest {
+				return &UserSigninCodeVerifyRequest{}
+			},
+			Response: func(c *gin.Context, tx db.ITx, user models.User, request interface{}) (interface{}, error) {
+				err := limits.phone.Reset(string(user.Phone))
+				if err != nil {
+					return nil, err
+				}
+				return signinResponse(
+					c, tx, tokens, notifier, tickets, user, request.(*UserSigninCodeVerifyRequest).Device,
+				)
+			},
+			OnWrongCode: func(c *gin.Context, user *models.User, request confflow.CodeRequest, err error) error {
+				return limits.fail(c, signinCodePhone(request), middlewares.ClientIP(c), user, err)
+			},
+		},
+	}
+}
+
+// SigninTwoFactorHandlerFactory returns handler which exchanges ticket issued by signin for the session tokens,
+// requires valid TOTP or backup code. Invalid codes are limited the same way as wrong passwords.
+func SigninTwoFactorHandlerFactory(
+	d *db.Db,
+	tokens refresh.IStorage,
+	notifier isc.IEventNotificator,
+	phoneLimiter *throttle.Limiter,
+	ipLimiter *throttle.Limiter,
+	tickets *TwoFactorTickets,
+) base.HandlerFunc {
+	limits := signinLimits{d: d, phone: phoneLimiter, ip: ipLimiter, notifier: notifier}
+
+	return func(c *gin.Context) (resp interface{}, code int, err error) {
+		params := UserSigninTwoFactorRequest{}
+		err = base.ShouldBindJSON(c, &params)
+		if err != nil {
+			return
+		}
+
+		// ticket is single-use, it's claimed before code is verified and given back only if code is wrong
+		ticket, err := tickets.Claim(params.Ticket)
+		if err != nil {
+			if err == errTicketNotFound {
+				err = errInvalidTicket
+			}
+			return
+		}
+		device := ticket.Device
+
+		user, err := models.GetUserByID(d, fmt.Sprint(ticket.UserID))
+		if err == models.ErrUserNotFound || (err == nil && user.Status != models.UserStatusActive) {
+			err = errInvalidTicket
+		}
+		if err != nil {
+			return
+		}
+
+		phone := string(user.Phone)
+		ip := middlewares.ClientIP(c)
+		err = limits.check(c, phone, ip)
+		if err != nil {
+			return
+		}
+
+		err = d.Tx(func(tx db.ITx) error {
+			totpData, err := twofactor.Get(tx, user.ID, true)
+			if err == twofactor.ErrNotFound || (err == nil && !totpData.Enabled) {
+				// 2fa has been disabled since ticket issued
+				return errInvalidTicket
+			}
+			if err != nil {
+				return err
+			}
+
+			ok, err := totpData.Verify(params.Code, time.Now().UTC())
+			if err != nil {
+				return err
+			}
+			if !ok {
+				return errInvalidCode
+			}
+			return twofactor.Save(tx, totpData)
+		})
+		if err == errInvalidCode {
+			err = limits.fail(c, phone, ip, &user, errInvalidCode)
+			if err == errInvalidCode {
+				if releaseErr := tickets.Release(ticket); releaseErr != nil {
+					err = releaseErr
+				}
+			}
+		}
+		if err != nil {
+			return
+		}
+
+		err = limits.phone.Reset(phone)
+		if err != nil {
+			return
+		}
+
+		resp, err = signinSession(c, d, tokens, notifier, user, device)
+		return
+	}
+}
+
+// SignoutHandlerFactory returns signout handler, session issued as tokens pair is revoked along with it's refresh token
+func SignoutHandlerFactory(
+	d *db.Db, sessStorage sessions.IStorage, tokens refresh.IStorage, tokenName string,
+) base.HandlerFunc {
 	return func(c *gin.Context) (resp interface{}, code int, err error) {
 		authToken, err := middlewares.GetAuthTokenFromContext(c, tokenName)
 		if err != nil {
 			return
 		}
+		userData, err := getUserData(c)
+		if err != nil {
+			return
+		}
+		userID, err := getUserID(userData)
+		if err != nil {
+			return
+		}
 
-		err = sessStorage.Delete(sessions.Token(authToken))
+		if familyID := stringFromData(userData, sessions.TokenFamilyKey); familyID != "" {
+			err = tokens.Revoke(familyID)
+		} else {
+			err = sessStorage.Delete(sessions.Token(authToken))
+		}
 		if err == sessions.ErrNotFound || err == sessions.ErrExpired {
 			// shadow token miss to prevent token brute
 			err = nil
 		}
+		if err != nil {
+			return
+		}
+
+		err = audit.Record(c, d, userID, authevents.TypeSignout)
 		return
 	}
 }
 
-// RefreshTokenHandlerFactory returns handler which checks current token then refresh it
+// RefreshTokenHandlerFactory returns handler which exchanges refresh token passed in the Authorization header for the
+// new tokens pair, refresh token may be exchanged only once. Owner of the session is taken from the new access token.
 func RefreshTokenHandlerFactory(
-	sessStorage sessions.IStorage,
-	tokenName string,
-	authExpiration time.Duration,
+	d *db.Db, tokens refresh.IStorage, sessStorage sessions.IStorage, tokenName string,
 ) base.HandlerFunc {
+	return func(c *gin.Context) (resp interface{}, code int, err error) {
+		refreshToken, err := middlewares.GetAuthTokenFromContext(c, tokenName)
+		if err != nil {
+			err = base.ErrorView{Code: http.StatusUnauthorized, Message: err.Error()}
+			return
+		}
+
+		// backends wrap token format errors
+		pair, err := tokens.Refresh(sessions.Token(refreshToken))
+		switch errors.Cause(err) {
+		case nil:
+		case refresh.ErrReused:
+			err = errRefreshTokenReused
+			return
+		case sessions.ErrNotFound, sessions.ErrExpired, sessions.ErrUnexpectedToken, sessions.ErrRevoked,
+			refresh.ErrNotRefreshToken:
+			err = base.ErrorView{Code: http.StatusUnauthorized, Message: err.Error()}
+			return
+		default:
+			return
+		}
+
+		data, err := sessStorage.Get(pair.Access)
+		if err != nil {
+			return
+		}
+		userID, err := getUserID(data)
+		if err != nil {
+			return
+		}
+		err = audit.Record(c, d, userID, authevents.TypeTokenRefreshed)
+		if err != nil {
+			return
+		}
 
+		resp = TokenPairView(pair)
+		return
+	}
+}
+
+// SessionsListHandlerFactory returns handler which lists user active sessions with their metadata
+func SessionsListHandlerFactory(sessStorage sessions.IStorage, tracker activity.ITracker) base.HandlerFunc {
 	return func(c *gin.Context) (resp interface{}, code int, err error) {
-		authToken, err := middlewares.GetAuthTokenFromContext(c, tokenName)
+		userData, err := getUserData(c)
+		if err != nil {
+			return
+		}
+
+		userSessions, err := sessStorage.List(userData)
+		if err != nil {
+			if err == sessions.ErrNotSupported {
+				err = errNotSupported
+			}
+			return
+		}
+
+		lastSeen := make(map[string]time.Time, len(userSessions))
+		for _, s := range userSessions {
+			lastSeen[s.ID], err = tracker.LastSeen(s.ID)
+			if err != nil {
+				return
+			}
+		}
+
+		resp = SessionsResponse{Sessions: SessionsView(
+			userSessions, lastSeen, sessionViewID(stringFromData(userData, sessions.SessionIDKey), userData),
+		)}
+		return
+	}
+}
+
+// SessionDeleteHandlerFactory returns handler which revokes user session by it's id, session issued as tokens pair is
+// revoked along with the whole tokens family, which also may be passed as id
+func SessionDeleteHandlerFactory(sessStorage sessions.IStorage, tokens refresh.IStorage) base.HandlerFunc {
+	return func(c *gin.Context) (resp interface{}, code int, err error) {
+		userData, err := getUserData(c)
 		if err != nil {
 			return
 		}
 
-		newToken, err := sessStorage.RefreshToken(sessions.Token(authToken), authExpiration)
+		id := c.Param("id")
+		familyID, err := findSessionFamily(sessStorage, userData, id)
+		if err != nil {
+			if err == sessions.ErrNotSupported {
+				err = errNotSupported
+			}
+			return
+		}
+
+		if familyID != "" {
+			err = tokens.Revoke(familyID)
+		} else {
+			err = sessStorage.DeleteByID(userData, id)
+		}
+		switch err {
+		case sessions.ErrNotFound:
+			err = errSessionNotFound
+		case sessions.ErrNotSupported:
+			err = errNotSupported
+		}
+		return
+	}
+}
+
+// findSessionFamily looks for the tokens family of the user session with given id, id may be the family id itself.
+// Returns empty family if session doesn't belong to any family. Fails if session data is missing, otherwise refresh
+// token of the pair would survive revocation.
+func findSessionFamily(sessStorage sessions.IStorage, userData map[string]interface{}, id string) (string, error) {
+	userSessions, err := sessStorage.List(userData)
+	if err != nil {
+		return "", err
+	}
+	for _, s := range userSessions {
+		if s.ID == id && s.Data == nil {
+			return "", errSessionFamilyUnknown
+		}
+		familyID := stringFromData(s.Data, sessions.TokenFamilyKey)
+		if s.ID == id || (familyID != "" && familyID == id) {
+			return familyID, nil
+		}
+	}
+	return "", nil
+}
+
+// SessionsDeleteAllHandlerFactory returns handler which revokes all user sessions including current one
+func SessionsDeleteAllHandlerFactory(sessStorage sessions.IStorage) base.HandlerFunc {
+	return func(c *gin.Context) (resp interface{}, code int, err error) {
+		userData, err := getUserData(c)
 		if err != nil {
 			return
 		}
 
-		resp = UserTokenResponse{Token: string(newToken)}
+		err = sessStorage.DeleteAll(userData)
+		if err == sessions.ErrNotSupported {
+			err = errNotSupported
+		}
 		return
 	}
 }
@@ -118,6 +474,131 @@ func CheckHandlerFactory() base.HandlerFunc {
 	}
 }
 
+// ChangePasswordHandlerFactory returns handler which changes password of the authorized user, current password is
+// required. Password may be changed because it's compromised, so all user sessions are revoked and new tokens pair is
+// issued for the current client. Wrong current password is counted as failed signin attempt of the phone, so phone
+// limiter must be the one signin failures are counted by.
+func ChangePasswordHandlerFactory(
+	d *db.Db,
+	sessStorage sessions.IStorage,
+	tokens refresh.IStorage,
+	notifier isc.IEventNotificator,
+	policy *passpolicy.Policy,
+	phoneLimiter *throttle.Limiter,
+) base.HandlerFunc {
+	return func(c *gin.Context) (resp interface{}, code int, err error) {
+		params := UserChangePasswordRequest{}
+		err = base.ShouldBindJSON(c, &params)
+		if err != nil {
+			return
+		}
+
+		userData, err := getUserData(c)
+		if err != nil {
+			return
+		}
+		phone, err := getUserPhone(c)
+		if err != nil {
+			return
+		}
+
+		if violations := policy.Check(params.NewPassword, phone); violations != nil {
+			err = base.NewFieldErrs("body", "new_password", violations...)
+			return
+		}
+
+		// locked phone isn't even checked for password
+		err = checkPhoneLocked(c, phoneLimiter, phone)
+		if err != nil {
+			return
+		}
+
+		var user models.User
+		err = d.Tx(func(tx db.ITx) error {
+			var err error
+			user, err = models.GetUserByPhoneAndStatus(tx, phone, models.UserStatusActive, true)
+			if err != nil {
+				return err
+			}
+
+			passEqual, err := user.Password.Compare(params.OldPassword)
+			if err != nil {
+				return err
+			}
+			if !passEqual {
+				return errWrongPassword
+			}
+
+			user.Password, err = types.NewPass(params.NewPassword)
+			if err != nil {
+				return err
+			}
+			err = models.UpdateUser(tx, user)
+			if err != nil {
+				return err
+			}
+
+			return audit.Record(c, tx, user.ID, authevents.TypePasswordChanged)
+		})
+		if err == errWrongPassword {
+			err = failPhone(c, phoneLimiter, phone, err)
+			return
+		}
+		if err != nil {
+			return
+		}
+
+		err = phoneLimiter.Reset(phone)
+		if err != nil {
+			return
+		}
+
+		// notification is sent only when password is actually changed
+		err = notifier.PasswordChanged(fmt.Sprint(user.ID), string(user.Phone))
+		if err != nil {
+			return
+		}
+
+		err = sessStorage.DeleteAll(userData)
+		if err != nil && err != sessions.ErrNotSupported {
+			return
+		}
+
+		device, _ := userData[sessions.DeviceKey].(string)
+		resp, err = newUserSession(c, d, tokens, user, device)
+		return
+	}
+}
+
+// SecurityEventsHandlerFactory returns handler which lists authentication events of the user newest first. Events are
+// paged by "limit" and "before" query params, "before" takes "next" value of the previous page.
+func SecurityEventsHandlerFactory(d *db.Db) base.HandlerFunc {
+	return func(c *gin.Context) (resp interface{}, code int, err error) {
+		limit, before, err := getEventsPaging(c)
+		if err != nil {
+			return
+		}
+
+		userData, err := getUserData(c)
+		if err != nil {
+			return
+		}
+		userID, err := getUserID(userData)
+		if err != nil {
+			return
+		}
+
+		// one extra event tells whether next page exists
+		events, err := authevents.List(d, userID, before, limit+1)
+		if err != nil {
+			return
+		}
+
+		resp = SecurityEventsView(events, limit)
+		return
+	}
+}
+
 // StatFactory returns user statistic part of which is gathered from wallet api.
 func StatFactory(d *db.Db, statsGetter stats.IUserWalletsGetter) base.HandlerFunc {
 	return func(c *gin.Context) (resp interface{}, code int, err error) {
@@ -165,11 +646,235 @@ func StatFactory(d *db.Db, statsGetter stats.IUserWalletsGetter) base.HandlerFun
 	}
 }
 
+// signinLimits limits failed signin attempts, failed attempts are recorded into the security log
+type signinLimits struct {
+	d        *db.Db
+	phone    *throttle.Limiter
+	ip       *throttle.Limiter
+	notifier isc.IEventNotificator
+}
+
+// check returns too many attempts error if either phone or ip is locked
+func (l signinLimits) check(c *gin.Context, phone, ip string) error {
+	for _, lock := range []struct {
+		limiter *throttle.Limiter
+		subject string
+	}{{l.phone, phone}, {l.ip, ip}} {
+		until, locked, err := lock.limiter.LockedUntil(lock.subject)
+		if err != nil {
+			return err
+		}
+		if locked {
+			return tooManyAttempts(c, until)
+		}
+	}
+	return nil
+}
+
+// fail registers failed attempt, user is notified if his phone becomes locked. Given error is returned if lock isn't
+// reached.
+func (l signinLimits) fail(c *gin.Context, phone, ip string, user *models.User, failErr error) error {
+	// attempt is recorded apart from the request transaction, so record survives it's rollback
+	var err error
+	if user != nil {
+		err = audit.Record(c, l.d, user.ID, authevents.TypeSigninFailed)
+	} else {
+		err = audit.RecordUnknown(c, l.d, authevents.TypeSigninFailed)
+	}
+	if err != nil {
+		return err
+	}
+
+	until, locked, err := l.ip.Fail(ip)
+	if err != nil {
+		return err
+	}
+
+	phoneUntil, phoneLocked, err := l.phone.Fail(phone)
+	if err != nil {
+		return err
+	}
+	if phoneLocked {
+		if user != nil {
+			err = l.notifier.SigninLocked(fmt.Sprint(user.ID), string(user.Phone), ip, phoneUntil)
+			if err != nil {
+				return err
+			}
+		}
+		if !locked || phoneUntil.After(until) {
+			until, locked = phoneUntil, true
+		}
+	}
+
+	if locked {
+		return tooManyAttempts(c, until)
+	}
+	return failErr
+}
+
+// checkPhoneLocked returns too many attempts error if phone is locked due to failed attempts
+func checkPhoneLocked(c *gin.Context, limiter *throttle.Limiter, phone string) error {
+	until, locked, err := limiter.LockedUntil(phone)
+	if err != nil || !locked {
+		return err
+	}
+	return tooManyAttempts(c, until)
+}
+
+// failPhone counts wrong password as failed attempt of the phone, given error is returned if lock isn't reached
+func failPhone(c *gin.Context, limiter *throttle.Limiter, phone string, failErr error) error {
+	until, locked, err := limiter.Fail(phone)
+	if err != nil {
+		return err
+	}
+	if locked {
+		return tooManyAttempts(c, until)
+	}
+	return failErr
+}
+
+// signinResponse creates new session or issues 2FA ticket if second factor is required
+func signinResponse(
+	c *gin.Context,
+	tx db.ITx,
+	tokens refresh.IStorage,
+	notifier isc.IEventNotificator,
+	tickets *TwoFactorTickets,
+	user models.User,
+	device string,
+) (interface{}, error) {
+	totpData, err := twofactor.Get(tx, user.ID)
+	switch {
+	case err == twofactor.ErrNotFound:
+	case err != nil:
+		return nil, err
+	case totpData.Enabled:
+		// second factor is required, so session is created only after code is verified
+		ticket, err := tickets.New(user.ID, device)
+		if err != nil {
+			return nil, err
+		}
+		return TwoFactorTicketView(ticket), nil
+	}
+	return signinSession(c, tx, tokens, notifier, user, device)
+}
+
+// signinSession records signin and creates new session, user is notified if signin comes from the device he hasn't
+// used before. The very first signin isn't notified since there is no known devices yet.
+func signinSession(
+	c *gin.Context,
+	tx db.ITx,
+	tokens refresh.IStorage,
+	notifier isc.IEventNotificator,
+	user models.User,
+	device string,
+) (interface{}, error) {
+	err := audit.Record(c, tx, user.ID, authevents.TypeSignin)
+	if err != nil {
+		return nil, err
+	}
+
+	known, first, err := audit.RememberDevice(c, tx, user.ID, device)
+	if err != nil {
+		return nil, err
+	}
+	if !known && !first {
+		err = notifier.NewDeviceSignin(fmt.Sprint(user.ID), string(user.Phone), device, middlewares.ClientIP(c))
+		if err != nil {
+			return nil, err
+		}
+	}
+	return newUserSession(c, tx, tokens, user, device)
+}
+
+// signinCodePhone returns phone of the signin code flow request
+func signinCodePhone(request interface{}) string {
+	switch params := request.(type) {
+	case *UserSigninCodeStartRequest:
+		return params.Phone
+	case *UserSigninCodeVerifyRequest:
+		return params.Phone
+	}
+	return ""
+}
+
+// newUserSession issues tokens pair of the new user session, user roles are placed into the session data
+func newUserSession(
+	c *gin.Context, tx db.ITx, tokens refresh.IStorage, user models.User, device string,
+) (interface{}, error) {
+	roles, err := models.GetUserRoles(tx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	data := middlewares.SessionMetadata(c, device)
+	data["id"] = user.ID
+	data["phone"] = string(user.Phone)
+	data[middlewares.RolesKey] = roles
+
+	pair, err := tokens.New(data)
+	if err != nil {
+		// token not created, so whole handler failed
+		return nil, err
+	}
+
+	// all is ok, auth has been passed
+	return TokenPairView(pair), nil
+}
+
 // utils
-func getUserPhone(c *gin.Context) (string, error) {
+func tooManyAttempts(c *gin.Context, until time.Time) error {
+	retryAfter := int64(math.Ceil(time.Until(until).Seconds()))
+	if retryAfter < 1 {
+		retryAfter = 1
+	}
+	c.Header("Retry-After", strconv.FormatInt(retryAfter, 10))
+	return errTooManyAttempts
+}
+
+func getUserData(c *gin.Context) (map[string]interface{}, error) {
 	userData := middlewares.GetUserDataFromContext(c)
 	if userData == nil {
-		return "", errors.New("auth passed but no user data attached")
+		return nil, errors.New("auth passed but no user data attached")
+	}
+	return userData, nil
+}
+
+func getEventsPaging(c *gin.Context) (limit int, before int64, err error) {
+	limit = defaultEventsLimit
+	if raw := c.Query("limit"); raw != "" {
+		limit, err = strconv.Atoi(raw)
+		if err != nil || limit < 1 || limit > maxEventsLimit {
+			err = errInvalidLimit
+			return
+		}
+	}
+	if raw := c.Query("before"); raw != "" {
+		before, err = strconv.ParseInt(raw, 10, 64)
+		if err != nil || before < 1 {
+			err = errInvalidBefore
+			return
+		}
+	}
+	return
+}
+
+func getUserID(userData map[string]interface{}) (id int64, err error) {
+	var userID struct {
+		ID int64
+	}
+	err = mapstructure.Decode(userData, &userID)
+	if err != nil {
+		err = errors.Wrap(err, "auth")
+	}
+	id = userID.ID
+	return
+}
+
+func getUserPhone(c *gin.Context) (string, error) {
+	userData, err := getUserData(c)
+	if err != nil {
+		return "", err
 	}
 	return userData["phone"].(string), nil
 }
@@ -1,5 +1,7 @@
 package auth
 
+import confflow "git.zam.io/wallet-backend/web-api/internal/server/handlers/flows/confirmation"
+
 // UserSignupRequest represents user signup request TODO add phones validators
 type UserSignupRequest struct {
 	Phone                string  `validate:"required,min=5" json:"phone"`
@@ -12,6 +14,39 @@ type UserSignupRequest struct {
 type UserSigninRequest struct {
 	Phone    string `validate:"required,phone" json:"phone"`
 	Password string `validate:"required,min=5,eqfield=Password" json:"password"`
+	Device   string `validate:"max=128" json:"device"`
+}
+
+// UserSigninCodeStartRequest represents phone which should receive one-time signin code
+type UserSigninCodeStartRequest struct {
+	Phone string `validate:"required,phone" json:"phone"`
+
+	confflow.ChannelParam
+}
+
+// UserSigninCodeVerifyRequest represents one-time signin code sent to the user phone
+type UserSigninCodeVerifyRequest struct {
+	Phone  string `validate:"required,phone" json:"phone"`
+	Code   string `validate:"required,min=6" json:"verification_code"`
+	Device string `validate:"max=128" json:"device"`
+}
+
+// VerificationCode implements confirmation.CodeRequest
+func (r *UserSigninCodeVerifyRequest) VerificationCode() string {
+	return r.Code
+}
+
+// UserSigninTwoFactorRequest represents ticket issued by signin and second factor code
+type UserSigninTwoFactorRequest struct {
+	Ticket string `validate:"required" json:"ticket"`
+	Code   string `validate:"required,max=32" json:"code"`
+}
+
+// UserChangePasswordRequest represents current and new user passwords
+type UserChangePasswordRequest struct {
+	OldPassword             string `validate:"required" json:"old_password"`
+	NewPassword             string `validate:"required" json:"new_password"`
+	NewPasswordConfirmation string `validate:"required,eqfield=NewPassword" json:"new_password_confirmation"`
 }
 
 // UserMeRequest
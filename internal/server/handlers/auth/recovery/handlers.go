@@ -5,13 +5,17 @@ import (
 	"git.zam.io/wallet-backend/common/pkg/merrors"
 	"git.zam.io/wallet-backend/common/pkg/types"
 	"git.zam.io/wallet-backend/web-api/db"
+	"git.zam.io/wallet-backend/web-api/internal/models/authevents"
 	models "git.zam.io/wallet-backend/web-api/internal/models/user"
+	"git.zam.io/wallet-backend/web-api/internal/server/handlers/auth/audit"
 	confflow "git.zam.io/wallet-backend/web-api/internal/server/handlers/flows/confirmation"
 	"git.zam.io/wallet-backend/web-api/internal/services/isc"
 	"git.zam.io/wallet-backend/web-api/internal/services/notifications"
 	"git.zam.io/wallet-backend/web-api/pkg/server/handlers/base"
 	"git.zam.io/wallet-backend/web-api/pkg/services/nosql"
-	"github.com/pkg/errors"
+	"git.zam.io/wallet-backend/web-api/pkg/services/passpolicy"
+	"git.zam.io/wallet-backend/web-api/pkg/services/sessions"
+	"github.com/gin-gonic/gin"
 	"time"
 )
 
@@ -19,207 +23,136 @@ var (
 	errFieldUserNotFound = base.NewFieldErr("body", "phone", "user not found")
 )
 
-const (
-	verificationCodeKeyPattern = "user:%s:recovery:code"
-	tokenKeyPattern            = "user:%s:recovery:token"
-	notifSendTOKeyPattern      = "user:%s:recovery:notif_to"
-)
-
-func verificationCodeKey(user models.User) string {
-	return fmt.Sprintf(verificationCodeKeyPattern, user.Phone)
-}
-
-func tokenKeyFunc(user models.User) string {
-	return fmt.Sprintf(tokenKeyPattern, user.Phone)
-}
-
-func getUserState(_ db.ITx, storage nosql.IStorage, user models.User) (state confflow.State, err error) {
-	codePresent, tokenPresent := false, false
-
-	// check verification code first
-	_, err = storage.Get(verificationCodeKey(user))
-	if err != nil && err != nosql.ErrNoSuchKeyFound {
-		return
-	}
-	codePresent = err != nosql.ErrNoSuchKeyFound
-	err = nil
+// FlowKeyPattern recovery flow record key, it's bound to the user phone
+const FlowKeyPattern = "user:%s:recovery:flow"
 
-	// check token
-	_, err = storage.Get(tokenKeyFunc(user))
-	if err != nil && err != nosql.ErrNoSuchKeyFound {
-		return
-	}
-	tokenPresent = err != nosql.ErrNoSuchKeyFound
-	err = nil
-
-	// estimate state
-	switch {
-	case codePresent && !tokenPresent:
-		state = confflow.StatePending
-	case !codePresent && tokenPresent:
-		state = confflow.StateVerified
-	case !codePresent && !tokenPresent:
-		state = confflow.StateFinished
-	case codePresent && tokenPresent:
-		// inconsistent nosql storage state
-		err = errors.New("inconsistent storage state: both recovery code and token present")
-	}
-	return
-}
-
-// StartHandlerFactory
-func StartHandlerFactory(
+// NewFlow creates password recovery confirmation flow, finish sets new user password and revokes all user sessions
+// since account could be stolen
+func NewFlow(
 	d *db.Db,
 	notifier isc.IEventNotificator,
 	generator notifications.IGenerator,
 	storage nosql.IStorage,
+	sessStorage sessions.IStorage,
+	policy *passpolicy.Policy,
+	secret []byte,
+	maxVerifyAttempts int,
 	storageExpire time.Duration,
 	notifSendTO time.Duration,
-) base.HandlerFunc {
-	resources := confflow.ExternalResources{
-		Database:  d,
-		Storage:   storage,
-		Generator: generator,
-	}
-	return confflow.StartHandlerFactory(
-		resources,
-		func() interface{} {
-			return &StartRequest{}
-		},
-		func(tx db.ITx, request interface{}) (user models.User, err error) {
-			params := request.(*StartRequest)
-			user, err = models.GetUserByPhoneAndStatus(tx, params.Phone, models.UserStatusActive, true)
+) *confflow.Flow {
+	return &confflow.Flow{
+		Resources: confflow.ExternalResources{
+			Database:  d,
+			Storage:   storage,
+			Generator: generator,
+		},
+		StateKey:          FlowKeyPattern,
+		Secret:            secret,
+		Expire:            storageExpire,
+		NotifSendTO:       notifSendTO,
+		MaxVerifyAttempts: maxVerifyAttempts,
+		GetUser: func(c *gin.Context, tx db.ITx, request interface{}) (user models.User, err error) {
+			user, err = models.GetUserByPhoneAndStatus(tx, requestPhone(request), models.UserStatusActive, true)
 			if err == models.ErrUserNotFound {
 				err = errFieldUserNotFound
 			}
-			return
-		},
-		getUserState,
-		func(tx db.ITx, storage nosql.IStorage, user models.User, newState confflow.State, params interface{}) (err error) {
-			// do nothing, confirmation flow does all job for us
-			return
-		},
-		func(resources confflow.ExternalResources, request interface{}, fErr error) (err error) {
-			return postValidateFailedParams(d, fErr, request.(*StartRequest).Phone)
-		},
-		storageExpire,
-		verificationCodeKeyPattern,
-		func(user models.User, code string) error {
-			return notifier.PasswordRecoveryVerificationRequested(fmt.Sprint(user.ID), string(user.Phone), code)
-		},
-		notifSendTO,
-		notifSendTOKeyPattern,
-		tokenKeyPattern,
-	)
-}
-
-// VerifyHandlerFactory
-func VerifyHandlerFactory(
-	d *db.Db,
-	generator notifications.IGenerator,
-	storage nosql.IStorage,
-	storageExpire time.Duration,
-) base.HandlerFunc {
-	resources := confflow.ExternalResources{
-		Database:  d,
-		Storage:   storage,
-		Generator: generator,
-	}
+			if err != nil {
+				return
+			}
 
-	return confflow.VerifyHandlerFactory(
-		resources,
-		func() interface{} {
-			return &VerifyRequest{}
-		},
-		func(tx db.ITx, request interface{}) (user models.User, err error) {
-			params := request.(*VerifyRequest)
-			user, err = models.GetUserByPhoneAndStatus(tx, params.Phone, models.UserStatusActive, true)
-			if err == models.ErrUserNotFound {
-				err = errFieldUserNotFound
+			// check password before recovery token is consumed, so user may retry with another one
+			if params, ok := request.(*FinishRequest); ok {
+				if violations := policy.Check(params.Password, string(user.Phone)); violations != nil {
+					err = base.NewFieldErrs("body", "password", violations...)
+				}
 			}
 			return
 		},
-		getUserState,
-		func(tx db.ITx, storage nosql.IStorage, user models.User, newState confflow.State, params interface{}) (err error) {
-			// do nothing, confirmation flow does all job for us
-			return
-		},
-		func(resources confflow.ExternalResources, request interface{}, fErr error) (err error) {
-			return postValidateFailedParams(d, fErr, request.(*VerifyRequest).Phone)
+		Start: confflow.StartStep{
+			Request: func() interface{} {
+				return &StartRequest{}
+			},
+			PostValidate: postValidateFailedParams(d),
+			SendCode: func(c *gin.Context, user models.User, code string, delivery isc.Delivery) error {
+				return notifier.PasswordRecoveryVerificationRequested(fmt.Sprint(user.ID), string(user.Phone), code, delivery)
+			},
+			OnStarted: func(c *gin.Context, tx db.ITx, user models.User, request interface{}) error {
+				return audit.Record(c, tx, user.ID, authevents.TypeRecoveryStarted)
+			},
+		},
+		Verify: confflow.VerifyStep{
+			Request: func() confflow.CodeRequest {
+				return &VerifyRequest{}
+			},
+			PostValidate: postValidateFailedParams(d),
+			OnVerified: func(c *gin.Context, tx db.ITx, user models.User, request interface{}) error {
+				return audit.Record(c, tx, user.ID, authevents.TypeRecoveryVerified)
+			},
+			TokenView: func(token string) interface{} {
+				return TokenView{
+					Token: token,
+				}
+			},
+		},
+		Finish: &confflow.FinishStep{
+			Request: func() confflow.TokenRequest {
+				return &FinishRequest{}
+			},
+			PostValidate: postValidateFailedParams(d),
+			TokenField:   "recovery_token",
+			OnFinished: func(c *gin.Context, tx db.ITx, user models.User, request interface{}) error {
+				// parse pass
+				password, err := types.NewPass(request.(*FinishRequest).Password)
+				if err != nil {
+					return err
+				}
+
+				// update user fields
+				user.Password = password
+				return models.UpdateUser(tx, user)
+			},
+			Notify: func(user models.User) error {
+				return notifier.PasswordRecoveryCompleted(fmt.Sprint(user.ID), string(user.Phone))
+			},
+			Response: func(c *gin.Context, tx db.ITx, user models.User, request interface{}) (interface{}, error) {
+				err := audit.Record(c, tx, user.ID, authevents.TypeRecoveryFinished)
+				if err != nil {
+					return nil, err
+				}
+
+				// revoke sessions last, so password remains unchanged if it fails
+				return nil, sessStorage.DeleteAll(map[string]interface{}{"phone": string(user.Phone)})
+			},
 		},
-		func(request interface{}) string {
-			return request.(*VerifyRequest).Code
-		},
-		func(token string) interface{} {
-			return TokenView{
-				Token: token,
-			}
-		},
-		verificationCodeKeyPattern,
-		tokenKeyPattern,
-		storageExpire,
-	)
+	}
 }
 
-// FinishHandlerFactory
-func FinishHandlerFactory(d *db.Db, storage nosql.IStorage, notifier isc.IEventNotificator) base.HandlerFunc {
-	resources := confflow.ExternalResources{
-		Database: d,
-		Storage:  storage,
+// utils
+func requestPhone(request interface{}) string {
+	switch params := request.(type) {
+	case *StartRequest:
+		return params.Phone
+	case *VerifyRequest:
+		return params.Phone
+	case *FinishRequest:
+		return params.Phone
 	}
+	return ""
+}
 
-	return confflow.FinishHandlerFactory(
-		resources,
-		func() interface{} {
-			return &FinishRequest{}
-		},
-		func(tx db.ITx, request interface{}) (user models.User, err error) {
-			params := request.(*FinishRequest)
-			user, err = models.GetUserByPhoneAndStatus(tx, params.Phone, models.UserStatusActive, true)
+func postValidateFailedParams(d *db.Db) confflow.PostValidateFieldsFunc {
+	return func(c *gin.Context, request interface{}, fErr error) (err error) {
+		phone := requestPhone(request)
+		if !base.HaveFieldErr(fErr, "phone") && phone != "" {
+			_, err = models.GetUserByPhone(d, phone)
 			if err == models.ErrUserNotFound {
-				err = errFieldUserNotFound
+				fErr = merrors.Append(fErr, errFieldUserNotFound)
+				err = nil
 			}
+		}
+		if err != nil {
 			return
-		},
-		getUserState,
-		func(tx db.ITx, storage nosql.IStorage, user models.User, newState confflow.State, params interface{}) error {
-			// parse pass
-			password, err := types.NewPass(params.(*FinishRequest).Password)
-			if err != nil {
-				return err
-			}
-
-			// update user fields
-			user.Password = password
-			return models.UpdateUser(tx, user)
-		},
-		func(resources confflow.ExternalResources, request interface{}, fErr error) (err error) {
-			return postValidateFailedParams(d, fErr, request.(*FinishRequest).Phone)
-		},
-		func(params interface{}) string {
-			return params.(*FinishRequest).Token
-		},
-		func(tx db.ITx, user models.User) (resp interface{}, err error) {
-			return
-		},
-		func(user models.User) error {
-			return notifier.PasswordRecoveryCompleted(fmt.Sprint(user.ID), string(user.Phone))
-		},
-		tokenKeyPattern,
-		"recovery_token",
-	)
-}
-
-// utils
-func postValidateFailedParams(d *db.Db, fErr error, phone string) (err error) {
-	if !base.HaveFieldErr(fErr, "phone") && phone != "" {
-		_, err = models.GetUserByPhone(d, phone)
-		if err == models.ErrUserNotFound {
-			fErr = merrors.Append(fErr, errFieldUserNotFound)
 		}
+		return fErr
 	}
-	if err != nil {
-		return
-	}
-	return fErr
 }
@@ -1,8 +1,12 @@
 package recovery
 
+import confflow "git.zam.io/wallet-backend/web-api/internal/server/handlers/flows/confirmation"
+
 // StartRequest
 type StartRequest struct {
 	Phone string `json:"phone" validate:"required,phone"`
+
+	confflow.ChannelParam
 }
 
 // VerifyRequest
@@ -11,11 +15,21 @@ type VerifyRequest struct {
 	Code  string `json:"verification_code" validate:"required,min=6"`
 }
 
+// VerificationCode implements confirmation.CodeRequest
+func (r *VerifyRequest) VerificationCode() string {
+	return r.Code
+}
+
 // FinishRequest
 type FinishRequest struct {
 	Phone string `json:"phone" validate:"required,phone"`
 	Token string `json:"recovery_token" validate:"required"`
 
-	Password             string `validate:"required,min=6,alphanum" json:"password"`
+	Password             string `validate:"required" json:"password"`
 	PasswordConfirmation string `validate:"required,eqfield=Password" json:"password_confirmation" `
 }
+
+// FinishToken implements confirmation.TokenRequest
+func (r *FinishRequest) FinishToken() string {
+	return r.Token
+}
@@ -1,34 +1,99 @@
 package auth
 
 import (
+	"git.zam.io/wallet-backend/web-api/internal/models/apikeys"
+	"git.zam.io/wallet-backend/web-api/internal/server/handlers/auth/changephone"
+	"git.zam.io/wallet-backend/web-api/internal/server/handlers/auth/deletion"
 	"git.zam.io/wallet-backend/web-api/internal/server/handlers/auth/dependencies"
 	"git.zam.io/wallet-backend/web-api/internal/server/handlers/auth/recovery"
 	"git.zam.io/wallet-backend/web-api/internal/server/handlers/auth/signup"
 	"git.zam.io/wallet-backend/web-api/pkg/server/handlers/base"
+	"git.zam.io/wallet-backend/web-api/pkg/server/middlewares"
+	"git.zam.io/wallet-backend/web-api/pkg/services/throttle"
 	"github.com/gin-gonic/gin"
+	"time"
 )
 
 // Register creates and registers /auth routes with given dependencies
 func Register(deps dependencies.Dependencies) gin.IRouter {
 	// placed here until more user endpoints come
-	deps.Routes.GET("/user/me", deps.AuthMiddleware, base.WrapHandler(StatFactory(deps.Db, deps.StatsGetter)))
+	deps.Routes.GET(
+		"/user/me",
+		middlewares.APIKeyScope(apikeys.ScopeStatsRead),
+		deps.AuthMiddleware,
+		base.WrapHandler(StatFactory(deps.Db, deps.StatsGetter)),
+	)
+	deps.Routes.GET(
+		"/user/me/security-events",
+		middlewares.APIKeyScope(apikeys.ScopeSecurityEventsRead),
+		deps.AuthMiddleware,
+		base.WrapHandler(SecurityEventsHandlerFactory(deps.Db)),
+	)
+
+	nowFunc := func() time.Time { return time.Now().UTC() }
+	throttleConf := deps.Conf.Auth.SigninThrottle
+	phoneLimiter := throttle.New(deps.Storage, "signin:phone", throttle.Params{
+		Window:      throttleConf.Window,
+		MaxFailures: throttleConf.MaxPhoneFailures,
+		Lockout:     throttleConf.Lockout,
+		MaxLockout:  throttleConf.MaxLockout,
+	}, nowFunc)
+	ipLimiter := throttle.New(deps.Storage, "signin:ip", throttle.Params{
+		Window:      throttleConf.Window,
+		MaxFailures: throttleConf.MaxIPFailures,
+		Lockout:     throttleConf.Lockout,
+		MaxLockout:  throttleConf.MaxLockout,
+	}, nowFunc)
+
+	// wrong current password is limited the same way as signin
+	deps.Routes.POST("/user/me/password", deps.AuthMiddleware, base.WrapHandler(ChangePasswordHandlerFactory(
+		deps.Db, deps.SessStorage, deps.Tokens, deps.Notificator, deps.PasswordPolicy, phoneLimiter,
+	)))
+
+	tickets := NewTwoFactorTickets(deps.Storage, deps.Conf.Auth.TwoFactor.TicketExpire)
+
+	// register phone change endpoints
+	changephone.Register(deps.Routes.Group("/user/me/phone", deps.AuthMiddleware), deps, phoneLimiter, []string{
+		SigninCodeFlowKeyPattern, signup.FlowKeyPattern, recovery.FlowKeyPattern, deletion.FlowKeyPattern,
+	})
+
+	// register account deletion endpoints
+	deletion.Register(deps.Routes.Group("/user/me/deletion", deps.AuthMiddleware), deps, phoneLimiter)
 
 	group := deps.Routes.Group("/auth")
 
 	group.POST("/signin", base.WrapHandler(SigninHandlerFactory(
-		deps.Db, deps.SessStorage, deps.Conf.Auth.TokenExpire,
+		deps.Db, deps.Tokens, deps.Notificator, phoneLimiter, ipLimiter, tickets,
+	)))
+	group.POST("/signin/2fa", base.WrapHandler(SigninTwoFactorHandlerFactory(
+		deps.Db, deps.Tokens, deps.Notificator, phoneLimiter, ipLimiter, tickets,
 	)))
 
+	if deps.Conf.Auth.SigninCode.Enabled {
+		SigninCodeFlow(
+			deps.Db, deps.Notificator, deps.Generator, deps.Storage, deps.Tokens, []byte(deps.Conf.Auth.FlowSecret),
+			deps.Conf.Auth.MaxVerifyAttempts, deps.Conf.Auth.SigninCode.CodeExpire, deps.Conf.Auth.SigninCode.RetryDelay,
+			phoneLimiter, ipLimiter, tickets,
+		).Register(group.Group("/signin/code"))
+	}
+
 	group.DELETE("/signout", deps.AuthMiddleware, base.WrapHandler(SignoutHandlerFactory(
-		deps.SessStorage, deps.Conf.Auth.TokenName,
+		deps.Db, deps.SessStorage, deps.Tokens, deps.Conf.Auth.TokenName,
 	)))
 
-	group.GET("/refresh_token", deps.AuthMiddleware, base.WrapHandler(RefreshTokenHandlerFactory(
-		deps.SessStorage, deps.Conf.Auth.TokenName, deps.Conf.Auth.TokenExpire,
+	// refresh token is validated by the handler itself, since auth middleware accepts only access tokens
+	group.GET("/refresh_token", base.WrapHandler(RefreshTokenHandlerFactory(
+		deps.Db, deps.Tokens, deps.SessStorage, deps.Conf.Auth.TokenName,
 	)))
 
 	group.GET("/check", deps.AuthMiddleware, base.WrapHandler(CheckHandlerFactory()))
 
+	group.GET("/sessions", deps.AuthMiddleware, base.WrapHandler(SessionsListHandlerFactory(deps.SessStorage, deps.Tracker)))
+	group.DELETE("/sessions", deps.AuthMiddleware, base.WrapHandler(SessionsDeleteAllHandlerFactory(deps.SessStorage)))
+	group.DELETE("/sessions/:id", deps.AuthMiddleware, base.WrapHandler(SessionDeleteHandlerFactory(
+		deps.SessStorage, deps.Tokens,
+	)))
+
 	// register signup endpoints
 	signup.Register(group.Group("/signup"), deps)
 
@@ -5,13 +5,18 @@ import (
 	"git.zam.io/wallet-backend/common/pkg/merrors"
 	"git.zam.io/wallet-backend/common/pkg/types"
 	"git.zam.io/wallet-backend/web-api/db"
+	"git.zam.io/wallet-backend/web-api/internal/models/authevents"
 	models "git.zam.io/wallet-backend/web-api/internal/models/user"
+	"git.zam.io/wallet-backend/web-api/internal/server/handlers/auth/audit"
 	confflow "git.zam.io/wallet-backend/web-api/internal/server/handlers/flows/confirmation"
 	"git.zam.io/wallet-backend/web-api/internal/services/isc"
 	"git.zam.io/wallet-backend/web-api/internal/services/notifications"
 	"git.zam.io/wallet-backend/web-api/pkg/server/handlers/base"
+	"git.zam.io/wallet-backend/web-api/pkg/server/middlewares"
 	"git.zam.io/wallet-backend/web-api/pkg/services/nosql"
-	"git.zam.io/wallet-backend/web-api/pkg/services/sessions"
+	"git.zam.io/wallet-backend/web-api/pkg/services/passpolicy"
+	"git.zam.io/wallet-backend/web-api/pkg/services/sessions/refresh"
+	"github.com/gin-gonic/gin"
 	"time"
 )
 
@@ -21,253 +26,216 @@ var (
 	errFieldReferrerNotFound  = base.NewFieldErr("body", "referrer_phone", "referrer not found")
 )
 
-const (
-	verificationCodeKeyPattern = "user:%s:signup:code"
-	signupTokenKeyPatten       = "user:%s:signup:token"
-	notifSendTOKeyPatten       = "user:%s:signup:notif_to"
-)
-
-func getUserState(tx db.ITx, storage nosql.IStorage, user models.User) (state confflow.State, err error) {
-	switch user.Status {
-	case models.UserStatusCreated:
-		state = confflow.StatePending
-	case models.UserStatusPending:
-		state = confflow.StatePending
-	case models.UserStatusVerified:
-		state = confflow.StateVerified
-	case models.UserStatusActive:
-		state = confflow.StateFinished
-	default:
-		err = fmt.Errorf("unexpected user status %s occured", user.Status)
-	}
-	return
-}
+// FlowKeyPattern signup flow record key, it's bound to the phone being registered
+const FlowKeyPattern = "user:%s:signup:flow"
 
-// StartHandlerFactory
-func StartHandlerFactory(
+// NewFlow creates signup confirmation flow, user is created on start and activated on finish
+func NewFlow(
 	d *db.Db,
 	notifier isc.IEventNotificator,
 	generator notifications.IGenerator,
 	storage nosql.IStorage,
+	tokens refresh.IStorage,
+	policy *passpolicy.Policy,
+	secret []byte,
+	maxVerifyAttempts int,
 	storageExpire time.Duration,
 	notifSendTO time.Duration,
-) base.HandlerFunc {
-	resources := confflow.ExternalResources{
-		Database:  d,
-		Storage:   storage,
-		Generator: generator,
-	}
-	return confflow.StartHandlerFactory(
-		resources,
-		func() interface{} {
-			return &StartRequest{}
-		},
-		func(tx db.ITx, request interface{}) (user models.User, err error) {
-			params := request.(*StartRequest)
-
-			// fetch user by given phone
-			user, err = models.GetUserByPhone(tx, params.Phone, true)
-			if err != nil {
-				// if no such phone registered we will create user with "crated" status
-				if err == models.ErrUserNotFound {
-					user, err = models.NewUser(params.Phone, "", models.UserStatusCreated, &params.ReferrerPhone)
-					if err != nil {
-						// seems that validator was failed, return internal error in such case
-						return
-					}
-
-					// unique phone constraint will prevent concurrent creation (call will holds until first tx
-					// will commit (in this case ErrUserAlreadyExists will be raised) or rollback changes
-					user, err = models.CreateUser(tx, user)
-					if err != nil {
-						if err == models.ErrReferrerNotFound {
-							err = errFieldReferrerNotFound
-						}
-						return
-					}
-				} else {
+) *confflow.Flow {
+	return &confflow.Flow{
+		Resources: confflow.ExternalResources{
+			Database:  d,
+			Storage:   storage,
+			Generator: generator,
+		},
+		StateKey:          FlowKeyPattern,
+		Secret:            secret,
+		Expire:            storageExpire,
+		NotifSendTO:       notifSendTO,
+		MaxVerifyAttempts: maxVerifyAttempts,
+		GetUser: func(c *gin.Context, tx db.ITx, request interface{}) (user models.User, err error) {
+			switch params := request.(type) {
+			case *StartRequest:
+				return getOrCreateUser(tx, params)
+			case *VerifyRequest:
+				return models.GetUserByPhone(tx, params.Phone, true)
+			case *FinishRequest:
+				user, err = models.GetUserByPhone(tx, params.Phone, true)
+				if err != nil {
 					return
 				}
-			}
 
-			// not allowed in active state
-			if user.Status == models.UserStatusActive {
-				err = errFieldUserAlreadyExists
+				// check password before signup token is consumed, so user may retry with another one
+				if violations := policy.Check(params.Password, string(user.Phone)); violations != nil {
+					err = base.NewFieldErrs("body", "password", violations...)
+				}
 			}
 			return
 		},
-		getUserState,
-		func(tx db.ITx, storage nosql.IStorage, user models.User, newState confflow.State, params interface{}) (err error) {
-			// update user status even if it remains unchanged
-			// all returned errors, even logical, treated as internal
-			_, err = models.UpdateUserStatus(tx, user, models.UserStatusPending)
-			return
-		},
-		func(resources confflow.ExternalResources, request interface{}, fErr error) error {
-			params := request.(*StartRequest)
+		Start: confflow.StartStep{
+			Request: func() interface{} {
+				return &StartRequest{}
+			},
+			PostValidate: func(c *gin.Context, request interface{}, fErr error) error {
+				params := request.(*StartRequest)
+
+				if !base.HaveFieldErr(fErr, "phone") {
+					_, err := models.GetUserByPhone(d, params.Phone)
+					if err == nil {
+						fErr = merrors.Append(fErr, errFieldUserAlreadyExists)
+					} else if err != models.ErrUserNotFound {
+						return err
+					}
+				}
 
-			if !base.HaveFieldErr(fErr, "phone") {
-				_, err := models.GetUserByPhone(d, params.Phone)
-				if err == nil {
-					fErr = merrors.Append(fErr, errFieldUserAlreadyExists)
-				} else if err != models.ErrUserNotFound {
-					return err
+				if params.ReferrerPhone != "" && !base.HaveFieldErr(fErr, "referrer_phone") {
+					_, err := models.GetUserByPhone(d, params.ReferrerPhone)
+					if err == models.ErrUserNotFound {
+						fErr = merrors.Append(fErr, errFieldReferrerNotFound)
+					} else if err != nil {
+						return err
+					}
 				}
-			}
 
-			if params.ReferrerPhone != "" && !base.HaveFieldErr(fErr, "referrer_phone") {
-				_, err := models.GetUserByPhone(d, params.ReferrerPhone)
-				if err == models.ErrUserNotFound {
-					fErr = merrors.Append(fErr, errFieldReferrerNotFound)
-				} else if err != nil {
+				return fErr
+			},
+			SendCode: func(c *gin.Context, user models.User, code string, delivery isc.Delivery) error {
+				return notifier.RegistrationVerificationRequested(fmt.Sprint(user.ID), string(user.Phone), code, delivery)
+			},
+			OnStarted: func(c *gin.Context, tx db.ITx, user models.User, request interface{}) (err error) {
+				// update user status even if it remains unchanged
+				// all returned errors, even logical, treated as internal
+				_, err = models.UpdateUserStatus(tx, user, models.UserStatusPending)
+				if err != nil {
+					return
+				}
+				return audit.Record(c, tx, user.ID, authevents.TypeSignupStarted)
+			},
+		},
+		Verify: confflow.VerifyStep{
+			Request: func() confflow.CodeRequest {
+				return &VerifyRequest{}
+			},
+			PostValidate: func(c *gin.Context, request interface{}, fErr error) error {
+				return postValidateFailedParams(d, fErr, request.(*VerifyRequest).Phone)
+			},
+			OnVerified: func(c *gin.Context, tx db.ITx, user models.User, request interface{}) (err error) {
+				// update user status
+				_, err = models.UpdateUserStatus(tx, user, models.UserStatusVerified)
+				if err != nil {
+					return
+				}
+				return audit.Record(c, tx, user.ID, authevents.TypeSignupVerified)
+			},
+			TokenView: func(token string) interface{} {
+				return TokenView{
+					Token: token,
+				}
+			},
+		},
+		Finish: &confflow.FinishStep{
+			Request: func() confflow.TokenRequest {
+				return &FinishRequest{}
+			},
+			PostValidate: func(c *gin.Context, request interface{}, fErr error) error {
+				return postValidateFailedParams(d, fErr, request.(*FinishRequest).Phone)
+			},
+			TokenField: "signup_token",
+			OnFinished: func(c *gin.Context, tx db.ITx, user models.User, request interface{}) error {
+				// update user fields
+				// parse pass
+				password, err := types.NewPass(request.(*FinishRequest).Password)
+				if err != nil {
 					return err
 				}
-			}
 
-			return fErr
-		},
-		storageExpire,
-		verificationCodeKeyPattern,
-		func(user models.User, code string) error {
-			return notifier.RegistrationVerificationRequested(fmt.Sprint(user.ID), string(user.Phone), code)
-		},
-		notifSendTO,
-		notifSendTOKeyPatten,
-		signupTokenKeyPatten,
-	)
-}
+				user.Password = password
+				user.Status = models.UserStatusActive
+				return models.UpdateUser(tx, user)
+			},
+			Notify: func(user models.User) error {
+				return notifier.RegistrationCompleted(fmt.Sprint(user.ID), string(user.Phone))
+			},
+			Response: func(c *gin.Context, tx db.ITx, user models.User, request interface{}) (resp interface{}, err error) {
+				params := request.(*FinishRequest)
+
+				err = audit.Record(c, tx, user.ID, authevents.TypeSignupFinished)
+				if err != nil {
+					return
+				}
 
-// VerifyHandlerFactory
-func VerifyHandlerFactory(
-	d *db.Db,
-	generator notifications.IGenerator,
-	storage nosql.IStorage,
-	storageExpire time.Duration,
-) base.HandlerFunc {
-	resources := confflow.ExternalResources{
-		Database:  d,
-		Storage:   storage,
-		Generator: generator,
-	}
+				// device user signed up from is known, so later signin from it isn't reported as the new one
+				_, _, err = audit.RememberDevice(c, tx, user.ID, params.Device)
+				if err != nil {
+					return
+				}
 
-	return confflow.VerifyHandlerFactory(
-		resources,
-		func() interface{} {
-			return &VerifyRequest{}
-		},
-		func(tx db.ITx, request interface{}) (user models.User, err error) {
-			params := request.(*VerifyRequest)
-			return models.GetUserByPhone(tx, params.Phone, true)
-		},
-		getUserState,
-		func(tx db.ITx, storage nosql.IStorage, user models.User, newState confflow.State, params interface{}) (err error) {
-			// update user status
-			_, err = models.UpdateUserStatus(tx, user, models.UserStatusVerified)
-			return
-		},
-		func(resources confflow.ExternalResources, request interface{}, fErr error) (err error) {
-			params := request.(*VerifyRequest)
+				// generate auth tokens
+				data := middlewares.SessionMetadata(c, params.Device)
+				data["id"] = user.ID
+				data["phone"] = user.Phone
 
-			if !base.HaveFieldErr(fErr, "phone") && params.Phone != "" {
-				_, err = models.GetUserByPhone(d, params.Phone)
-				if err == models.ErrUserNotFound {
-					fErr = merrors.Append(fErr, errFieldUserNotFound)
-					err = nil
+				pair, err := tokens.New(data)
+				if err != nil {
+					return
+				}
+
+				// prepare answer
+				resp = FinishResponse{
+					Token:        string(pair.Access),
+					RefreshToken: string(pair.Refresh),
 				}
-			}
-			if err != nil {
 				return
-			}
-			return fErr
-		},
-		func(request interface{}) string {
-			return request.(*VerifyRequest).Code
+			},
 		},
-		func(token string) interface{} {
-			return TokenView{
-				Token: token,
-			}
-		},
-		verificationCodeKeyPattern,
-		signupTokenKeyPatten,
-		storageExpire,
-	)
-}
-
-// FinishHandlerFactory
-func FinishHandlerFactory(
-	d *db.Db,
-	storage nosql.IStorage,
-	notifier isc.IEventNotificator,
-	sessStorage sessions.IStorage,
-	authExpiration time.Duration,
-) base.HandlerFunc {
-	resources := confflow.ExternalResources{
-		Database: d,
-		Storage:  storage,
 	}
+}
 
-	return confflow.FinishHandlerFactory(
-		resources,
-		func() interface{} {
-			return &FinishRequest{}
-		},
-		func(tx db.ITx, request interface{}) (user models.User, err error) {
-			params := request.(*FinishRequest)
-			return models.GetUserByPhone(tx, params.Phone, true)
-		},
-		getUserState,
-		func(tx db.ITx, storage nosql.IStorage, user models.User, newState confflow.State, params interface{}) error {
-			// update user fields
-			// parse pass
-			password, err := types.NewPass(params.(*FinishRequest).Password)
-			if err != nil {
-				return err
-			}
-
-			user.Password = password
-			user.Status = models.UserStatusActive
-			return models.UpdateUser(tx, user)
-		},
-		func(resources confflow.ExternalResources, request interface{}, fErr error) (err error) {
-			params := request.(*FinishRequest)
-
-			// check logical errors
-			if !base.HaveFieldErr(fErr, "phone") && params.Phone != "" {
-				_, err = models.GetUserByPhone(d, params.Phone)
-				if err == models.ErrUserNotFound {
-					fErr = merrors.Append(fErr, errFieldUserNotFound)
-					err = nil
-				}
-			}
+// utils
+func getOrCreateUser(tx db.ITx, params *StartRequest) (user models.User, err error) {
+	// fetch user by given phone
+	user, err = models.GetUserByPhone(tx, params.Phone, true)
+	if err != nil {
+		// if no such phone registered we will create user with "crated" status
+		if err == models.ErrUserNotFound {
+			user, err = models.NewUser(params.Phone, "", models.UserStatusCreated, &params.ReferrerPhone)
 			if err != nil {
+				// seems that validator was failed, return internal error in such case
 				return
 			}
-			return fErr
-		},
-		func(params interface{}) string {
-			return params.(*FinishRequest).Token
-		},
-		func(tx db.ITx, user models.User) (resp interface{}, err error) {
-			// generate auth token
-			authToken, err := sessStorage.New(map[string]interface{}{
-				"id":    user.ID,
-				"phone": user.Phone,
-			}, authExpiration)
+
+			// unique phone constraint will prevent concurrent creation (call will holds until first tx
+			// will commit (in this case ErrUserAlreadyExists will be raised) or rollback changes
+			user, err = models.CreateUser(tx, user)
 			if err != nil {
+				if err == models.ErrReferrerNotFound {
+					err = errFieldReferrerNotFound
+				}
 				return
 			}
-
-			// prepare answer
-			resp = FinishResponse{
-				Token: string(authToken),
-			}
+		} else {
 			return
-		},
-		func(user models.User) error {
-			return notifier.RegistrationCompleted(fmt.Sprint(user.ID), string(user.Phone))
-		},
-		signupTokenKeyPatten,
-		"signup_token",
-	)
+		}
+	}
+
+	// not allowed in active state
+	if user.Status == models.UserStatusActive {
+		err = errFieldUserAlreadyExists
+	}
+	return
+}
+
+func postValidateFailedParams(d *db.Db, fErr error, phone string) (err error) {
+	// check logical errors
+	if !base.HaveFieldErr(fErr, "phone") && phone != "" {
+		_, err = models.GetUserByPhone(d, phone)
+		if err == models.ErrUserNotFound {
+			fErr = merrors.Append(fErr, errFieldUserNotFound)
+			err = nil
+		}
+	}
+	if err != nil {
+		return
+	}
+	return fErr
 }
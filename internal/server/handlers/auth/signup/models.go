@@ -1,9 +1,13 @@
 package signup
 
+import confflow "git.zam.io/wallet-backend/web-api/internal/server/handlers/flows/confirmation"
+
 // StartRequest
 type StartRequest struct {
 	Phone         string `json:"phone" validate:"required,phone"`
 	ReferrerPhone string `json:"referrer_phone" validate:"phone"`
+
+	confflow.ChannelParam
 }
 
 // VerifyRequest
@@ -12,11 +16,23 @@ type VerifyRequest struct {
 	Code  string `json:"verification_code" validate:"required,min=6"`
 }
 
+// VerificationCode implements confirmation.CodeRequest
+func (r *VerifyRequest) VerificationCode() string {
+	return r.Code
+}
+
 // FinishRequest
 type FinishRequest struct {
 	Phone string `json:"phone" validate:"required,phone"`
 	Token string `json:"signup_token" validate:"required"`
 
-	Password             string `validate:"required,min=6" json:"password"`
+	Password             string `validate:"required" json:"password"`
 	PasswordConfirmation string `validate:"required,eqfield=Password" json:"password_confirmation" `
+
+	Device string `validate:"max=128" json:"device"`
+}
+
+// FinishToken implements confirmation.TokenRequest
+func (r *FinishRequest) FinishToken() string {
+	return r.Token
 }
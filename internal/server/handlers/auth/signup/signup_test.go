@@ -10,6 +10,7 @@ import (
 	"git.zam.io/wallet-backend/web-api/fixtures/database"
 	"git.zam.io/wallet-backend/web-api/fixtures/database/migrations"
 	models "git.zam.io/wallet-backend/web-api/internal/models/user"
+	confflow "git.zam.io/wallet-backend/web-api/internal/server/handlers/flows/confirmation"
 	"git.zam.io/wallet-backend/web-api/internal/services/isc"
 	iscmock "git.zam.io/wallet-backend/web-api/internal/services/isc/mocks"
 	"git.zam.io/wallet-backend/web-api/internal/services/notifications"
@@ -17,8 +18,10 @@ import (
 	"git.zam.io/wallet-backend/web-api/pkg/server/handlers/base"
 	"git.zam.io/wallet-backend/web-api/pkg/services/nosql"
 	nosqlmock "git.zam.io/wallet-backend/web-api/pkg/services/nosql/mocks"
+	"git.zam.io/wallet-backend/web-api/pkg/services/passpolicy"
 	"git.zam.io/wallet-backend/web-api/pkg/services/sessions"
-	sessmock "git.zam.io/wallet-backend/web-api/pkg/services/sessions/mocks"
+	"git.zam.io/wallet-backend/web-api/pkg/services/sessions/refresh"
+	refreshmock "git.zam.io/wallet-backend/web-api/pkg/services/sessions/refresh/mocks"
 	"github.com/gin-gonic/gin"
 	. "github.com/onsi/ginkgo"
 	"github.com/onsi/ginkgo/extensions/table"
@@ -44,7 +47,10 @@ const (
 	signUpToken      = "SIGNUPTOKENTOKENTOKEN"
 	signUpToken2     = "2222SIGNUPTOKENTOKENTOKEN222"
 	authToken        = "AUTH TOKEN"
+	refreshToken     = "REFRESH TOKEN"
 	sendAttemptTO    = time.Minute / 2
+	flowKey1         = "user:" + validPhone1 + ":signup:flow"
+	flowKey2         = "user:" + validPhone2 + ":signup:flow"
 )
 
 func TestSignUpHandlers(t *testing.T) {
@@ -74,6 +80,28 @@ func createSimpleContext(body interface{}) *gin.Context {
 	return CreateContext("POST", "NOT DEFINED", body)
 }
 
+var flowSecret = []byte("FLOWSECRET")
+
+const maxVerifyAttempts = 5
+
+func pendingRecord(code string) string {
+	return confflow.Record{
+		State:    confflow.StatePending,
+		CodeHash: confflow.HashSecret(flowSecret, code),
+		ExpireAt: time.Now().Add(time.Minute),
+		SentAt:   time.Now(),
+	}.Encode()
+}
+
+func verifiedRecord(token string) string {
+	return confflow.Record{
+		State:     confflow.StateVerified,
+		TokenHash: confflow.HashSecret(flowSecret, token),
+		ExpireAt:  time.Now().Add(time.Minute),
+		SentAt:    time.Now(),
+	}.Encode()
+}
+
 var _ = Describe("Given user signup flow", func() {
 	Init()
 	database.Init()
@@ -94,8 +122,8 @@ var _ = Describe("Given user signup flow", func() {
 		return g, g
 	})
 
-	BeforeEachCProvide(func() (*sessmock.IStorage, sessions.IStorage) {
-		s := &sessmock.IStorage{}
+	BeforeEachCProvide(func() (*refreshmock.IStorage, refresh.IStorage) {
+		s := &refreshmock.IStorage{}
 		return s, s
 	})
 
@@ -107,7 +135,10 @@ var _ = Describe("Given user signup flow", func() {
 				notifier isc.IEventNotificator,
 				generator notifications.IGenerator,
 			) base.HandlerFunc {
-				return StartHandlerFactory(d, notifier, generator, storage, time.Minute, sendAttemptTO)
+				flow := NewFlow(
+					d, notifier, generator, storage, nil, nil, flowSecret, maxVerifyAttempts, time.Minute, sendAttemptTO,
+				)
+				return flow.StartHandler()
 			},
 		)
 		BeforeEachCProvide(func(d *db.Db) models.User {
@@ -127,10 +158,13 @@ var _ = Describe("Given user signup flow", func() {
 			) {
 				// setup mocks
 				generator.On("RandomCode").Return(confirmCode)
-				storage.On("SetWithExpire", "user:"+validPhone2+":signup:code", confirmCode, mock.Anything).Return(nil)
-				storage.On("Get", "user:"+validPhone2+":signup:notif_to").Return(nil, nosql.ErrNoSuchKeyFound)
-				storage.On("SetWithExpire", "user:"+validPhone2+":signup:notif_to", mock.Anything, sendAttemptTO).Return(nil)
-				storage.On("Delete", "user:"+validPhone2+":signup:token").Return(nil)
+				storage.On("Get", flowKey2).Return(nil, nosql.ErrNoSuchKeyFound)
+				storage.On("CompareAndSwap", flowKey2, nil, mock.MatchedBy(func(raw string) bool {
+					r := confflow.Record{}
+					Expect(json.Unmarshal([]byte(raw), &r)).To(Succeed())
+					return r.State == confflow.StatePending && r.TokenHash == "" && r.Attempts == 0 &&
+						confflow.SecretMatches(flowSecret, confirmCode, r.CodeHash)
+				}), time.Minute).Return(true, nil)
 			})
 
 			for _, state := range []models.UserStatusName{models.UserStatusPending, models.UserStatusVerified} {
@@ -148,6 +182,7 @@ var _ = Describe("Given user signup flow", func() {
 							fmt.Sprint(user.ID),
 							validPhone2,
 							confirmCode,
+							isc.Delivery{},
 						).Return(nil)
 
 						return providedUser(user)
@@ -179,6 +214,7 @@ var _ = Describe("Given user signup flow", func() {
 						mock.Anything,
 						validPhone2,
 						confirmCode,
+						isc.Delivery{},
 					).Return(nil)
 
 					val, _, err := handler(createSimpleContext(gin.H{
@@ -193,7 +229,7 @@ var _ = Describe("Given user signup flow", func() {
 
 					By("verifying notifier mock calls")
 					Expect(len(notifSender.Calls)).To(Equal(1))
-					Expect(len(notifSender.Calls[0].Arguments)).To(Equal(3))
+					Expect(len(notifSender.Calls[0].Arguments)).To(Equal(4))
 					Expect(notifSender.Calls[0].Arguments[0]).To(Equal(fmt.Sprint(user.ID)))
 				},
 			)
@@ -206,6 +242,7 @@ var _ = Describe("Given user signup flow", func() {
 						mock.Anything,
 						validPhone2,
 						confirmCode,
+						isc.Delivery{},
 					).Return(nil)
 
 					val, _, err := handler(createSimpleContext(gin.H{
@@ -222,7 +259,7 @@ var _ = Describe("Given user signup flow", func() {
 
 					By("verifying notifier mock calls")
 					Expect(len(notifSender.Calls)).To(Equal(1))
-					Expect(len(notifSender.Calls[0].Arguments)).To(Equal(3))
+					Expect(len(notifSender.Calls[0].Arguments)).To(Equal(4))
 					Expect(notifSender.Calls[0].Arguments[0]).To(Equal(fmt.Sprint(user.ID)))
 				},
 			)
@@ -304,7 +341,10 @@ var _ = Describe("Given user signup flow", func() {
 	Context("when querying /auth/signup/verify", func() {
 		BeforeEachCProvide(
 			func(d *db.Db, storage nosql.IStorage, generator notifications.IGenerator) base.HandlerFunc {
-				return VerifyHandlerFactory(d, generator, storage, time.Minute)
+				flow := NewFlow(
+					d, nil, generator, storage, nil, nil, flowSecret, maxVerifyAttempts, time.Minute, time.Minute,
+				)
+				return flow.VerifyHandler()
 			},
 		)
 		BeforeEachCProvide(func(d *db.Db) models.User {
@@ -319,9 +359,14 @@ var _ = Describe("Given user signup flow", func() {
 
 		Context("when code is valid", func() {
 			BeforeEachCInvoke(func(storage *nosqlmock.IStorage, generator *notifmock.IGenerator) {
-				storage.On("Get", "user:"+validPhone1+":signup:code").Return(confirmCode, nil)
-				storage.On("Delete", "user:"+validPhone1+":signup:code").Return(nil)
-				storage.On("SetWithExpire", "user:"+validPhone1+":signup:token", signUpToken, mock.Anything).Return(nil)
+				record := pendingRecord(confirmCode)
+				storage.On("Get", flowKey1).Return(record, nil)
+				storage.On("CompareAndSwap", flowKey1, record, mock.MatchedBy(func(raw string) bool {
+					r := confflow.Record{}
+					Expect(json.Unmarshal([]byte(raw), &r)).To(Succeed())
+					return r.State == confflow.StateVerified && r.CodeHash == "" &&
+						r.TokenHash == confflow.HashSecret(flowSecret, signUpToken)
+				}), time.Minute).Return(true, nil)
 				generator.On("RandomToken").Return(signUpToken)
 			})
 
@@ -345,7 +390,14 @@ var _ = Describe("Given user signup flow", func() {
 
 		Context("when code is wrong", func() {
 			BeforeEachCInvoke(func(storage *nosqlmock.IStorage, generator *notifmock.IGenerator) {
-				storage.On("Get", "user:"+validPhone1+":signup:code").Return(confirmCode2, nil)
+				record := pendingRecord(confirmCode2)
+				storage.On("Get", flowKey1).Return(record, nil)
+				storage.On("CompareAndSwap", flowKey1, record, mock.MatchedBy(func(raw string) bool {
+					r := confflow.Record{}
+					Expect(json.Unmarshal([]byte(raw), &r)).To(Succeed())
+					return r.State == confflow.StatePending && r.Attempts == 1
+				}), time.Minute).Return(true, nil)
+				generator.On("RandomToken").Return(signUpToken)
 			})
 
 			ItD("should fail because verification code is't long enough", func(d *db.Db, handler base.HandlerFunc, user models.User) {
@@ -373,9 +425,13 @@ var _ = Describe("Given user signup flow", func() {
 				storage nosql.IStorage,
 				generator notifications.IGenerator,
 				notifier isc.IEventNotificator,
-				sessStorage sessions.IStorage,
+				tokens refresh.IStorage,
 			) base.HandlerFunc {
-				return FinishHandlerFactory(d, storage, notifier, sessStorage, time.Minute)
+				policy := passpolicy.New(passpolicy.Params{MinLength: 6, ForbidPhone: true})
+				flow := NewFlow(
+					d, notifier, generator, storage, tokens, policy, flowSecret, maxVerifyAttempts, time.Minute, time.Minute,
+				)
+				return flow.FinishHandler()
 			},
 		)
 		BeforeEachCProvide(func(d *db.Db) models.User {
@@ -394,16 +450,20 @@ var _ = Describe("Given user signup flow", func() {
 				storage *nosqlmock.IStorage,
 				generator *notifmock.IGenerator,
 				notifier *iscmock.IEventNotificator,
-				sessStorage *sessmock.IStorage,
+				tokens *refreshmock.IStorage,
 			) {
-				storage.On("Get", "user:"+validPhone1+":signup:token").Return(signUpToken, nil)
-				sessStorage.On(
-					"New", map[string]interface{}{
-						"id":    user.ID,
-						"phone": user.Phone,
-					}, time.Minute,
-				).Return(sessions.Token(authToken), nil)
-				storage.On("Delete", "user:"+validPhone1+":signup:token").Return(nil)
+				record := verifiedRecord(signUpToken)
+				storage.On("Get", flowKey1).Return(record, nil)
+				storage.On("CompareAndSwap", flowKey1, record, mock.MatchedBy(func(raw string) bool {
+					r := confflow.Record{}
+					Expect(json.Unmarshal([]byte(raw), &r)).To(Succeed())
+					return r.State == confflow.StateFinished && r.TokenHash == ""
+				}), time.Minute).Return(true, nil)
+				tokens.On(
+					"New", mock.MatchedBy(func(data map[string]interface{}) bool {
+						return data["id"] == user.ID && data["phone"] == user.Phone
+					}),
+				).Return(refresh.Pair{Access: sessions.Token(authToken), Refresh: sessions.Token(refreshToken)}, nil)
 				notifier.On("RegistrationCompleted", fmt.Sprint(user.ID), validPhone1).Return(nil)
 			})
 
@@ -416,9 +476,11 @@ var _ = Describe("Given user signup flow", func() {
 				}))
 				Expect(err).NotTo(HaveOccurred())
 				Expect(val).To(BeEquivalentTo(struct {
-					Token string
+					Token        string
+					RefreshToken string
 				}{
-					Token: authToken,
+					Token:        authToken,
+					RefreshToken: refreshToken,
 				}))
 
 				u, err := models.GetUserByID(d, fmt.Sprintf("%d", user.ID))
@@ -427,6 +489,20 @@ var _ = Describe("Given user signup flow", func() {
 				Expect(u.CreatedAt.IsZero()).NotTo(Equal(true))
 			})
 
+			ItD("should fail because password violates policy and keep token", func(
+				handler base.HandlerFunc, storage *nosqlmock.IStorage,
+			) {
+				val, _, err := handler(createSimpleContext(gin.H{
+					"phone":                 validPhone1,
+					"signup_token":          signUpToken,
+					"password":              "pass1111111",
+					"password_confirmation": "pass1111111",
+				}))
+				Expect(val).To(BeNil())
+				Expect(err).To(Equal(base.NewFieldErrs("body", "password", "password mustn't contain phone number")))
+				storage.AssertNotCalled(GinkgoT(), "CompareAndSwap", flowKey1, mock.Anything, mock.Anything, mock.Anything)
+			})
+
 			ItD("should fail because password confirmation is wrong", func(handler base.HandlerFunc) {
 				val, _, err := handler(createSimpleContext(gin.H{
 					"phone":                 validPhone1,
@@ -445,7 +521,7 @@ var _ = Describe("Given user signup flow", func() {
 				))
 			})
 
-			ItD("should fail because password does't 6 char long", func(handler base.HandlerFunc) {
+			ItD("should fail because password is shorter than policy allows", func(handler base.HandlerFunc) {
 				val, _, err := handler(createSimpleContext(gin.H{
 					"phone":                 validPhone1,
 					"signup_token":          signUpToken,
@@ -456,7 +532,7 @@ var _ = Describe("Given user signup flow", func() {
 				Expect(val).To(BeNil())
 
 				Expect(err).To(Equal(
-					base.NewFieldErr("body", "password", `field value must be at least 6 items long`),
+					base.NewFieldErrs("body", "password", "password must be at least 6 characters long"),
 				))
 			})
 
@@ -482,9 +558,9 @@ var _ = Describe("Given user signup flow", func() {
 				storage *nosqlmock.IStorage,
 				generator *notifmock.IGenerator,
 				notifier *iscmock.IEventNotificator,
-				sessStorage *sessmock.IStorage,
+				tokens *refreshmock.IStorage,
 			) {
-				storage.On("Get", "user:"+validPhone1+":signup:token").Return(signUpToken, nil)
+				storage.On("Get", flowKey1).Return(verifiedRecord(signUpToken), nil)
 			})
 
 			ItD("should fail because of wrong token", func(d *db.Db, user models.User, handler base.HandlerFunc) {
@@ -7,5 +7,6 @@ type TokenView struct {
 
 // FinishResponse represent finish request response
 type FinishResponse struct {
-	Token string `json:"token"`
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
 }
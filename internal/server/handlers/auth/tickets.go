@@ -0,0 +1,113 @@
+package auth
+
+import (
+	"fmt"
+	"time"
+
+	"git.zam.io/wallet-backend/web-api/pkg/services/nosql"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+)
+
+const twoFactorTicketKeyPattern = "signin:2fa:%s"
+
+// errTicketNotFound returned when ticket is unknown or already expired
+var errTicketNotFound = errors.New("2fa ticket not found")
+
+// TwoFactorTickets issues short-lived tickets which prove that user has passed password check, ticket must be
+// exchanged for the session tokens along with the second factor code
+type TwoFactorTickets struct {
+	storage nosql.IStorage
+	expire  time.Duration
+}
+
+// NewTwoFactorTickets creates tickets which are kept in the given storage until expiration
+func NewTwoFactorTickets(storage nosql.IStorage, expire time.Duration) *TwoFactorTickets {
+	return &TwoFactorTickets{storage: storage, expire: expire}
+}
+
+// ClaimedTicket ticket taken from the storage, it's invalid until released
+type ClaimedTicket struct {
+	ID       string
+	UserID   int64
+	Device   string
+	ExpireAt time.Time
+}
+
+// New issues ticket for the user, device is kept to be stored in the session created later
+func (t *TwoFactorTickets) New(userID int64, device string) (string, error) {
+	claimed := ClaimedTicket{
+		ID:       uuid.New().String(),
+		UserID:   userID,
+		Device:   device,
+		ExpireAt: time.Now().Add(t.expire),
+	}
+	err := t.store(claimed, t.expire)
+	if err != nil {
+		return "", err
+	}
+	return claimed.ID, nil
+}
+
+// Claim takes ticket from the storage, so it can't be used by the concurrent request. Ticket is consumed unless it's
+// released.
+func (t *TwoFactorTickets) Claim(ticket string) (claimed ClaimedTicket, err error) {
+	if _, err = uuid.Parse(ticket); err != nil {
+		return claimed, errTicketNotFound
+	}
+
+	raw, err := t.storage.Get(ticketKey(ticket))
+	if err == nosql.ErrNoSuchKeyFound {
+		return claimed, errTicketNotFound
+	}
+	if err != nil {
+		return
+	}
+
+	data, ok := raw.(map[string]interface{})
+	if !ok {
+		err = fmt.Errorf("unexpected 2fa ticket type %T", raw)
+		return
+	}
+
+	claimed.UserID, err = nosql.Int64(data["user_id"])
+	if err != nil {
+		return
+	}
+	// ticket without valid expiration can't be released, so it's rejected before it's consumed
+	expireAt, err := nosql.Int64(data["expire_at"])
+	if err != nil {
+		return
+	}
+	claimed.ID = ticket
+	claimed.Device, _ = data["device"].(string)
+	claimed.ExpireAt = time.Unix(expireAt, 0)
+
+	// only one of the concurrent requests succeeds to delete the ticket
+	err = t.storage.Delete(ticketKey(ticket))
+	if err == nosql.ErrNoSuchKeyFound {
+		err = errTicketNotFound
+	}
+	return
+}
+
+// Release gives claimed ticket back until it's initial expiration, so it may be used again
+func (t *TwoFactorTickets) Release(claimed ClaimedTicket) error {
+	expire := time.Until(claimed.ExpireAt)
+	if expire <= 0 {
+		return nil
+	}
+	return t.store(claimed, expire)
+}
+
+func (t *TwoFactorTickets) store(claimed ClaimedTicket, expire time.Duration) error {
+	return t.storage.SetWithExpire(ticketKey(claimed.ID), map[string]interface{}{
+		"user_id":   claimed.UserID,
+		"device":    claimed.Device,
+		"expire_at": claimed.ExpireAt.Unix(),
+	}, expire)
+}
+
+func ticketKey(ticket string) string {
+	return fmt.Sprintf(twoFactorTicketKeyPattern, ticket)
+}
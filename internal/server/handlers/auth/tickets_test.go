@@ -0,0 +1,49 @@
+package auth
+
+import (
+	"time"
+
+	"git.zam.io/wallet-backend/web-api/pkg/services/nosql"
+	"git.zam.io/wallet-backend/web-api/pkg/services/nosql/mem"
+	"github.com/google/uuid"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("testing two-factor tickets", func() {
+	var (
+		storage nosql.IStorage
+		tickets *TwoFactorTickets
+	)
+	BeforeEach(func() {
+		storage = mem.New()
+		tickets = NewTwoFactorTickets(storage, time.Minute)
+	})
+
+	It("should release claimed ticket", func() {
+		ticket, err := tickets.New(1, "phone")
+		Expect(err).NotTo(HaveOccurred())
+
+		claimed, err := tickets.Claim(ticket)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(claimed.UserID).To(Equal(int64(1)))
+		_, err = tickets.Claim(ticket)
+		Expect(err).To(Equal(errTicketNotFound))
+
+		Expect(tickets.Release(claimed)).To(Succeed())
+		_, err = tickets.Claim(ticket)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("should fail to claim ticket without expiration", func() {
+		ticket := uuid.New().String()
+		err := storage.SetWithExpire(ticketKey(ticket), map[string]interface{}{
+			"user_id": int64(1), "device": "phone", "expire_at": "unknown",
+		}, time.Minute)
+		Expect(err).NotTo(HaveOccurred())
+
+		_, err = tickets.Claim(ticket)
+		Expect(err).To(HaveOccurred())
+		Expect(err).NotTo(Equal(errTicketNotFound))
+	})
+})
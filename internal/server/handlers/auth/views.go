@@ -1,12 +1,36 @@
 package auth
 
 import (
+	"strconv"
+	"time"
+
 	"git.zam.io/wallet-backend/common/pkg/types/decimal"
+	"git.zam.io/wallet-backend/web-api/internal/models/authevents"
+	"git.zam.io/wallet-backend/web-api/pkg/services/nosql"
+	"git.zam.io/wallet-backend/web-api/pkg/services/sessions"
+	"git.zam.io/wallet-backend/web-api/pkg/services/sessions/refresh"
 )
 
 // UserTokenResponse represents user sigin and signup responses
 type UserTokenResponse struct {
-	Token string `json:"token"`
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+// TokenPairView
+func TokenPairView(pair refresh.Pair) UserTokenResponse {
+	return UserTokenResponse{Token: string(pair.Access), RefreshToken: string(pair.Refresh)}
+}
+
+// TwoFactorTicketResponse represents signin response of the user with enabled 2FA
+type TwoFactorTicketResponse struct {
+	TwoFactorRequired bool   `json:"two_factor_required"`
+	Ticket            string `json:"ticket"`
+}
+
+// TwoFactorTicketView
+func TwoFactorTicketView(ticket string) TwoFactorTicketResponse {
+	return TwoFactorTicketResponse{TwoFactorRequired: true, Ticket: ticket}
 }
 
 // UserPhoneResponse represents user auth check response
@@ -29,3 +53,109 @@ type UserResponse struct {
 	RegisteredAt int64            `json:"registered_at"`
 	Wallets      WalletsStatsView `json:"wallets"`
 }
+
+// SessionView represents user session
+type SessionView struct {
+	ID        string `json:"id"`
+	Current   bool   `json:"current"`
+	IP        string `json:"ip,omitempty"`
+	UserAgent string `json:"user_agent,omitempty"`
+	Device    string `json:"device,omitempty"`
+	CreatedAt int64  `json:"created_at,omitempty"`
+	LastSeen  int64  `json:"last_seen,omitempty"`
+}
+
+// SessionsResponse represents user sessions list
+type SessionsResponse struct {
+	Sessions []SessionView `json:"sessions"`
+}
+
+// SessionsView creates sessions views marking session with given id as current one. Sessions of the same tokens
+// family are represented by the single view identified by the family id (see sessionViewID).
+func SessionsView(userSessions []sessions.Session, lastSeen map[string]time.Time, currentID string) []SessionView {
+	views := make([]SessionView, 0, len(userSessions))
+	indexes := make(map[string]int, len(userSessions))
+	for _, s := range userSessions {
+		id := sessionViewID(s.ID, s.Data)
+		i, ok := indexes[id]
+		if !ok {
+			i = len(views)
+			indexes[id] = i
+			views = append(views, SessionView{ID: id, Current: id == currentID})
+		}
+		view := &views[i]
+
+		if view.IP == "" {
+			view.IP = stringFromData(s.Data, sessions.IPKey)
+		}
+		if view.UserAgent == "" {
+			view.UserAgent = stringFromData(s.Data, sessions.UserAgentKey)
+		}
+		if view.Device == "" {
+			view.Device = stringFromData(s.Data, sessions.DeviceKey)
+		}
+		createdAt := timestampFromData(s.Data, sessions.CreatedAtKey)
+		if createdAt != 0 && (view.CreatedAt == 0 || createdAt < view.CreatedAt) {
+			view.CreatedAt = createdAt
+		}
+		if seen := lastSeen[s.ID]; !seen.IsZero() && seen.Unix() > view.LastSeen {
+			view.LastSeen = seen.Unix()
+		}
+	}
+	return views
+}
+
+// SecurityEventView represents authentication event of the user
+type SecurityEventView struct {
+	ID        string `json:"id"`
+	Type      string `json:"type"`
+	IP        string `json:"ip,omitempty"`
+	UserAgent string `json:"user_agent,omitempty"`
+	CreatedAt int64  `json:"created_at"`
+}
+
+// SecurityEventsResponse represents page of the user authentication events, next is nil on the last page
+type SecurityEventsResponse struct {
+	Events []SecurityEventView `json:"events"`
+	Next   *string             `json:"next"`
+}
+
+// SecurityEventsView creates page of at most limit events, events beyond limit only indicate that next page exists
+func SecurityEventsView(events []authevents.Event, limit int) SecurityEventsResponse {
+	page := SecurityEventsResponse{Events: make([]SecurityEventView, 0, len(events))}
+	if len(events) > limit {
+		events = events[:limit]
+		next := strconv.FormatInt(events[limit-1].ID, 10)
+		page.Next = &next
+	}
+	for _, e := range events {
+		page.Events = append(page.Events, SecurityEventView{
+			ID:        strconv.FormatInt(e.ID, 10),
+			Type:      string(e.Type),
+			IP:        e.IP,
+			UserAgent: e.UserAgent,
+			CreatedAt: e.CreatedAt.Unix(),
+		})
+	}
+	return page
+}
+
+// utils
+// sessionViewID returns id under which session is listed, sessions issued as tokens pair are listed by their family
+func sessionViewID(id string, data map[string]interface{}) string {
+	if familyID := stringFromData(data, sessions.TokenFamilyKey); familyID != "" {
+		return familyID
+	}
+	return id
+}
+
+func stringFromData(data map[string]interface{}, key string) string {
+	val, _ := data[key].(string)
+	return val
+}
+
+// timestampFromData extracts unix timestamp, returns zero if there is no such timestamp
+func timestampFromData(data map[string]interface{}, key string) int64 {
+	ts, _ := nosql.Int64(data[key])
+	return ts
+}
@@ -1,3 +1,4 @@
-// Packages confirmation contains generalized handler factories which allows to control operations which is performed
-// in 3 steps (start, verify, finish)
+// Packages confirmation contains generalized confirmation flow which allows to control operations which is performed
+// in 3 steps (start, verify, finish). Flow is declared using Flow struct, which registers all steps routes at once.
+// User flow state is kept as a single nosql record, so concurrent steps can't break it.
 package confirmation
@@ -0,0 +1,170 @@
+package confirmation
+
+import (
+	"fmt"
+	"git.zam.io/wallet-backend/web-api/db"
+	"git.zam.io/wallet-backend/web-api/internal/models/kyc"
+	models "git.zam.io/wallet-backend/web-api/internal/models/user"
+	"git.zam.io/wallet-backend/web-api/internal/services/isc"
+	"git.zam.io/wallet-backend/web-api/internal/services/notifications"
+	"git.zam.io/wallet-backend/web-api/pkg/server/handlers/base"
+	"git.zam.io/wallet-backend/web-api/pkg/services/nosql"
+	"github.com/gin-gonic/gin"
+	"time"
+)
+
+type ExternalResources struct {
+	Database  *db.Db
+	Storage   nosql.IStorage
+	Generator notifications.IGenerator
+}
+
+type State string
+
+const (
+	StatePending  State = "state_pending"
+	StateVerified       = "state_verified"
+	StateFinished       = "state_finished"
+)
+
+// ChannelRequest implemented by start step requests which allow to choose code delivery channel
+type ChannelRequest interface {
+	DeliveryChannel() notifications.Channel
+}
+
+// ChannelParam embedded into start step requests to implement ChannelRequest
+type ChannelParam struct {
+	Channel string `json:"channel" validate:"omitempty,oneof=sms call email"`
+}
+
+// DeliveryChannel implements ChannelRequest
+func (p ChannelParam) DeliveryChannel() notifications.Channel {
+	return notifications.Channel(p.Channel)
+}
+
+// CodeRequest implemented by verify step requests
+type CodeRequest interface {
+	VerificationCode() string
+}
+
+// TokenRequest implemented by finish step requests
+type TokenRequest interface {
+	FinishToken() string
+}
+
+type ParamsFactory func() interface{}
+
+type PostValidateFieldsFunc func(c *gin.Context, request interface{}, err error) error
+type GetUserFunc func(c *gin.Context, tx db.ITx, request interface{}) (user models.User, err error)
+type StepHookFunc func(c *gin.Context, tx db.ITx, user models.User, request interface{}) error
+type RespFactory func(c *gin.Context, tx db.ITx, user models.User, request interface{}) (interface{}, error)
+
+// Flow declares confirmation flow: start sends verification code to the user, verify exchanges the code for the finish
+// token and finish performs the operation. Flow without finish step performs it right after code is verified.
+type Flow struct {
+	Resources ExternalResources
+
+	// StateKey nosql key pattern of the flow record, formatted with the user phone
+	StateKey string
+	// Secret HMAC key verification code and finish token are hashed with, so they can't be read from the storage
+	Secret []byte
+
+	// Expire verification code and finish token live duration
+	Expire time.Duration
+	// NotifSendTO minimal interval between codes sending
+	NotifSendTO time.Duration
+	// MaxVerifyAttempts count of wrong codes after which verification code is burned, so flow must be started again
+	MaxVerifyAttempts int
+
+	// GetUser finds the user flow is performed for, it's called by every step within transaction
+	GetUser GetUserFunc
+
+	Start  StartStep
+	Verify VerifyStep
+	Finish *FinishStep
+}
+
+// StartStep sends verification code
+type StartStep struct {
+	Request      ParamsFactory
+	PostValidate PostValidateFieldsFunc
+
+	// SendCode delivers verification code to the user the requested way, transaction is rollbacked if it fails
+	SendCode func(c *gin.Context, user models.User, code string, delivery isc.Delivery) error
+	// OnStarted called after code is sent but before flow record is stored, may be nil
+	OnStarted StepHookFunc
+}
+
+// VerifyStep checks verification code
+type VerifyStep struct {
+	Request      func() CodeRequest
+	PostValidate PostValidateFieldsFunc
+
+	// OnVerified called after code is checked, may be nil
+	OnVerified StepHookFunc
+	// TokenView creates response which holds finish token, required if flow has finish step
+	TokenView func(token string) interface{}
+	// Response creates response of the flow without finish step
+	Response RespFactory
+	// OnWrongCode called after transaction rollback when code is wrong or burned, so failure may be persisted. User
+	// is nil if it wasn't found. May be nil.
+	OnWrongCode func(c *gin.Context, user *models.User, request CodeRequest, err error) error
+}
+
+// FinishStep performs the operation
+type FinishStep struct {
+	Request      func() TokenRequest
+	PostValidate PostValidateFieldsFunc
+
+	// TokenField request field which holds finish token
+	TokenField string
+	// OnFinished performs the operation itself
+	OnFinished StepHookFunc
+	// Notify notifies user about finish, may be nil
+	Notify func(user models.User) error
+	// Response may be nil
+	Response RespFactory
+	// OnCommitted called after transaction is committed, e.g. to update user state kept apart from the database or to
+	// emit events which mustn't be emitted if operation is rolled back. May be nil.
+	OnCommitted func(c *gin.Context, user models.User, request interface{}) error
+}
+
+// Register registers flow steps routes on the given group
+func (f *Flow) Register(group gin.IRouter) gin.IRouter {
+	group.POST("/start", base.WrapHandler(f.StartHandler()))
+	group.POST("/verify", base.WrapHandler(f.VerifyHandler()))
+	if f.Finish != nil {
+		group.PUT("/finish", base.WrapHandler(f.FinishHandler()))
+	}
+	return group
+}
+
+// delivery resolves code delivery requested by the user, email channel requires verified kyc email
+func (f *Flow) delivery(tx db.ITx, user models.User, request interface{}) (delivery isc.Delivery, err error) {
+	r, ok := request.(ChannelRequest)
+	if !ok {
+		return
+	}
+
+	delivery.Channel = r.DeliveryChannel()
+	if delivery.Channel != notifications.ChannelEmail {
+		return
+	}
+
+	data, err := kyc.Get(tx, user.ID)
+	if err == kyc.ErrNoSuchUser {
+		// code will be sent by sms
+		return delivery, nil
+	}
+	if err != nil {
+		return
+	}
+	if data.Status == kyc.StatusVerified {
+		delivery.Email = data.Email
+	}
+	return
+}
+
+func (f *Flow) key(pattern string, user models.User) string {
+	return fmt.Sprintf(pattern, user.Phone)
+}
@@ -4,31 +4,23 @@ import (
 	"fmt"
 	"git.zam.io/wallet-backend/web-api/db"
 	models "git.zam.io/wallet-backend/web-api/internal/models/user"
-	"git.zam.io/wallet-backend/web-api/internal/services/notifications"
 	"git.zam.io/wallet-backend/web-api/pkg/server/handlers/base"
-	"git.zam.io/wallet-backend/web-api/pkg/services/nosql"
 	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
 	"net/http"
 	"time"
 )
 
-type ExternalResources struct {
-	Database  *db.Db
-	Storage   nosql.IStorage
-	Generator notifications.IGenerator
-}
-
-type State string
+var (
+	// ErrFieldWrongCode returned by verify handlers when verification code is wrong or expired
+	ErrFieldWrongCode = base.NewFieldErr("body", "verification_code", "code is wrong")
 
-const (
-	StatePending  State = "state_pending"
-	StateVerified       = "state_verified"
-	StateFinished       = "state_finished"
-)
+	// ErrFieldCodeBurned returned by verify handlers when too many wrong codes are submitted, code is removed
+	ErrFieldCodeBurned = base.NewFieldErr(
+		"body", "verification_code", "too many wrong attempts, new code must be requested",
+	)
 
-var (
-	errFieldWrongCode = base.NewFieldErr("body", "verification_code", "code is wrong")
-	errNotAllowed     = base.ErrorView{
+	errNotAllowed = base.ErrorView{
 		Code:    http.StatusBadRequest,
 		Message: "such action not allowed",
 	}
@@ -38,278 +30,256 @@ var (
 	}
 )
 
-type ParamsFactory func() interface{}
-
-type PostValidateFieldsFunc func(resources ExternalResources, request interface{}, err error) error
-type GetUserFunc func(tx db.ITx, request interface{}) (user models.User, err error)
-type GetUserStateFunc func(tx db.ITx, storage nosql.IStorage, user models.User) (state State, err error)
-type SetUserStateFunc func(tx db.ITx, storage nosql.IStorage, user models.User, newState State, params interface{}) error
-
-// StartHandlerFactory creates
-func StartHandlerFactory(
-	resources ExternalResources,
-	factory ParamsFactory,
-	userFunc GetUserFunc,
-	userStateFunc GetUserStateFunc,
-	setUserStateFunc SetUserStateFunc,
-	postValidateFunc PostValidateFieldsFunc,
-	verifCodeExpire time.Duration,
-	verifCodeKeyPattern string,
-	verifEventSender func(user models.User, code string) error,
-	nextSendTO time.Duration,
-	nextSendTOKeyPattern string,
-	finishTokenKeyPattern string,
-) base.HandlerFunc {
+// StartHandler creates start step handler
+func (f *Flow) StartHandler() base.HandlerFunc {
+	resources := f.Resources
 	return func(c *gin.Context) (resp interface{}, code int, err error) {
-		params, err := paramsOrErr(c, resources, factory, postValidateFunc)
+		params, err := paramsOrErr(c, f.Start.Request(), f.Start.PostValidate)
 		if err != nil {
 			return
 		}
 
 		err = resources.Database.Tx(func(tx db.ITx) error {
-			user, err := userFunc(tx, params)
+			user, err := f.GetUser(c, tx, params)
 			if err != nil {
 				return err
 			}
 
-			_, err = userStateFunc(tx, resources.Storage, user)
+			// start attempt must occur later then specified timeout (NotifSendTO), check it before code is sent, but
+			// record is stored only after successful sending, so failed attempt doesn't block next one
+			err = f.transition(user, f.checkSendTO)
 			if err != nil {
 				return err
 			}
 
-			// check is this start attempt occurs later then specified timeout (nextSendTO)
-			_, err = resources.Storage.Get(genNextSendTOKey(nextSendTOKeyPattern, user))
-			if err == nosql.ErrNoSuchKeyFound { // process positive case first
-				// that't means that T/O already expired and request may be processed
-				err = nil
-			} else if err == nil { // then process deterministic negative
-				// if T/O record found, that's mean T/O not expired
-				err = errToFrequent
-				return err
-			} else { // then non-deterministic case (any unexpected error)
-				return err
-			}
-
-			// adds notification send T/O
-			err = resources.Storage.SetWithExpire(
-				genNextSendTOKey(nextSendTOKeyPattern, user), map[string]interface{}{}, nextSendTO,
-			)
+			// issue and send new confirmation code
+			code := resources.Generator.RandomCode()
+			delivery, err := f.delivery(tx, user, params)
 			if err != nil {
 				return err
 			}
+			err = f.Start.SendCode(c, user, code, delivery)
 
-			// issue new confirmation code
-			code := resources.Generator.RandomCode()
-
-			// save it in storage
-			err = resources.Storage.SetWithExpire(
-				genVerificationCodeKey(verifCodeKeyPattern, user), code, verifCodeExpire,
-			)
+			// sadly, but whole transaction should be rollbacked if notification sent fails
 			if err != nil {
 				return err
 			}
 
-			// clear finish token
-			err = resources.Storage.Delete(genFinishTokenKey(finishTokenKeyPattern, user))
-			if err != nil {
-				if err == nosql.ErrNoSuchKeyFound {
-					err = nil
-				} else {
+			if f.Start.OnStarted != nil {
+				err = f.Start.OnStarted(c, tx, user, params)
+				if err != nil {
 					return err
 				}
 			}
 
-			// send confirmation code
-			err = verifEventSender(user, code)
-
-			// sadly, but whole transaction should be rollbacked if notification sent fails
-			if err != nil {
-				return err
-			}
-
-			// update state
-			return setUserStateFunc(tx, resources.Storage, user, StatePending, params)
+			// new code replaces previous code or token and gets it's own attempts, timeout is checked again since
+			// concurrent start may be completed meanwhile
+			return f.transition(user, func(r *Record) (*Record, error) {
+				if _, err := f.checkSendTO(r); err != nil {
+					return nil, err
+				}
+				now := time.Now()
+				return &Record{
+					State:    StatePending,
+					CodeHash: HashSecret(f.Secret, code),
+					ExpireAt: now.Add(f.Expire),
+					SentAt:   now,
+				}, nil
+			})
 		})
 		return
 	}
 }
 
-// VerifyHandlerFactory
-func VerifyHandlerFactory(
-	resources ExternalResources,
-	factory ParamsFactory,
-	userFunc GetUserFunc,
-	userStateFunc GetUserStateFunc,
-	setUserStateFunc SetUserStateFunc,
-	postValidateFunc PostValidateFieldsFunc,
-	getCodeFromParams func(interface{}) string,
-	tokenRespFactory func(token string) interface{},
-	verifCodeKeyPattern,
-	finishTokenKeyPattern string,
-	finishTokenExpire time.Duration,
-) base.HandlerFunc {
+// checkSendTO fails if code has been sent within NotifSendTO, record is never replaced
+func (f *Flow) checkSendTO(r *Record) (*Record, error) {
+	if r != nil && time.Now().Before(r.SentAt.Add(f.NotifSendTO)) {
+		return nil, errToFrequent
+	}
+	return nil, nil
+}
+
+// VerifyHandler creates verify step handler. If flow has finish step, finish token is issued, otherwise flow is
+// finished right after code is verified
+func (f *Flow) VerifyHandler() base.HandlerFunc {
+	resources := f.Resources
 	return func(c *gin.Context) (resp interface{}, code int, err error) {
-		params, err := paramsOrErr(c, resources, factory, postValidateFunc)
+		request := f.Verify.Request()
+		_, err = paramsOrErr(c, request, f.Verify.PostValidate)
 		if err != nil {
 			return
 		}
 
+		var user *models.User
 		err = resources.Database.Tx(func(tx db.ITx) (err error) {
-			user, err := userFunc(tx, params)
+			found, err := f.GetUser(c, tx, request)
 			if err != nil {
 				return err
 			}
+			user = &found
 
-			state, err := userStateFunc(tx, resources.Storage, user)
-			if err != nil {
-				return err
+			// validate passed verification code and consume it, so it may be used only once
+			var token string
+			if f.Finish != nil {
+				token = resources.Generator.RandomToken()
 			}
-
-			// validate passed verification code
-			codeKey := genVerificationCodeKey(verifCodeKeyPattern, user)
-			code, err := resources.Storage.Get(codeKey)
-			if err == nosql.ErrNoSuchKeyFound || getCodeFromParams(params) != code {
-				err = errFieldWrongCode
-				return
-			} else if err != nil {
+			err = f.transition(found, func(r *Record) (*Record, error) {
+				return f.verifyRecord(r, request.VerificationCode(), token)
+			})
+			if err != nil {
 				return
 			}
 
-			// remove verification code
-			err = resources.Storage.Delete(codeKey)
-			// skip no suck key found error
-			if err != nil && err != nosql.ErrNoSuchKeyFound {
+			if f.Finish != nil {
+				resp, err = f.issueToken(c, tx, found, request, token)
 				return
 			}
 
-			// check state after code confirmation to prevent leaks
-			if state != StatePending {
-				err = errNotAllowed
-				return
+			if f.Verify.OnVerified != nil {
+				err = f.Verify.OnVerified(c, tx, found, request)
+				if err != nil {
+					return
+				}
 			}
+			resp, err = f.Verify.Response(c, tx, found, request)
+			return
+		})
+		if (err == ErrFieldWrongCode || err == ErrFieldCodeBurned) && f.Verify.OnWrongCode != nil {
+			err = f.Verify.OnWrongCode(c, user, request, err)
+		}
+		return
+	}
+}
 
-			// generate new finish token
-			token := resources.Generator.RandomToken()
-			tokenKey := genFinishTokenKey(finishTokenKeyPattern, user)
-			err = resources.Storage.SetWithExpire(tokenKey, token, finishTokenExpire)
-			if err != nil {
-				return
-			}
+// verifyRecord checks verification code against pending record (see CheckCode). Valid code moves record to the
+// verified state with the finish token, or to the finished state if flow has no finish step.
+func (f *Flow) verifyRecord(r *Record, code, token string) (*Record, error) {
+	failed, err := CheckCode(r, f.Secret, code, f.MaxVerifyAttempts)
+	if failed != nil || err != nil {
+		return failed, err
+	}
 
-			// update user status
-			err = setUserStateFunc(tx, resources.Storage, user, StateVerified, params)
-			if err != nil {
-				return
-			}
+	// check state after code confirmation to prevent leaks
+	if r.State != StatePending {
+		return nil, errNotAllowed
+	}
 
-			// prepare response
-			resp = tokenRespFactory(token)
+	next := *r
+	next.CodeHash = ""
+	next.Attempts = 0
+	if f.Finish == nil {
+		next.State = StateFinished
+		return &next, nil
+	}
+	next.State = StateVerified
+	next.TokenHash = HashSecret(f.Secret, token)
+	next.ExpireAt = time.Now().Add(f.Expire)
+	return &next, nil
+}
 
+func (f *Flow) issueToken(
+	c *gin.Context, tx db.ITx, user models.User, request interface{}, token string,
+) (resp interface{}, err error) {
+	if f.Verify.OnVerified != nil {
+		err = f.Verify.OnVerified(c, tx, user, request)
+		if err != nil {
 			return
-		})
-		return
+		}
 	}
+
+	// prepare response
+	resp = f.Verify.TokenView(token)
+	return
 }
 
-// FinishHandlerFactory
-func FinishHandlerFactory(
-	resources ExternalResources,
-	factory ParamsFactory,
-	userFunc GetUserFunc,
-	userStateFunc GetUserStateFunc,
-	setUserStateFunc SetUserStateFunc,
-	postValidateFunc PostValidateFieldsFunc,
-	getTokenFromParams func(interface{}) string,
-	respFactory func(tx db.ITx, user models.User) (interface{}, error),
-	finishEventSender func(user models.User) error,
-	finishTokenKeyPattern string,
-	tokenFieldName string,
-) base.HandlerFunc {
+// FinishHandler creates finish step handler
+func (f *Flow) FinishHandler() base.HandlerFunc {
+	resources := f.Resources
 	return func(c *gin.Context) (resp interface{}, code int, err error) {
-		params, err := paramsOrErr(c, resources, factory, postValidateFunc)
+		request := f.Finish.Request()
+		_, err = paramsOrErr(c, request, f.Finish.PostValidate)
 		if err != nil {
 			return
 		}
 
+		var (
+			user               models.User
+			verified, finished *Record
+		)
 		err = resources.Database.Tx(func(tx db.ITx) (err error) {
-			user, err := userFunc(tx, params)
+			user, err = f.GetUser(c, tx, request)
 			if err != nil {
 				return err
 			}
 
-			state, err := userStateFunc(tx, resources.Storage, user)
-			if err != nil {
-				return err
-			}
+			// validate and consume finish token, finish allowed only on verified state. Token is consumed before the
+			// operation, so concurrent requests can't perform it twice, it's restored if operation fails.
+			var claimed, next Record
+			err = f.transition(user, func(r *Record) (*Record, error) {
+				if r == nil || r.Expired() || !SecretMatches(f.Secret, request.FinishToken(), r.TokenHash) {
+					return nil, base.NewFieldErr(
+						"body", f.Finish.TokenField, fmt.Sprintf("%s is wrong", f.Finish.TokenField),
+					)
+				}
+				if r.State != StateVerified {
+					return nil, errNotAllowed
+				}
 
-			// validate token
-			tokenKey := genFinishTokenKey(finishTokenKeyPattern, user)
-			token, err := resources.Storage.Get(tokenKey)
-			if err == nosql.ErrNoSuchKeyFound || getTokenFromParams(params) != token {
-				err = base.NewFieldErr("body", tokenFieldName, fmt.Sprintf("%s is wrong", tokenFieldName))
-				return
-			}
-			// delete finish token
-			err = resources.Storage.Delete(tokenKey)
+				claimed, next = *r, *r
+				next.State = StateFinished
+				next.TokenHash = ""
+				return &next, nil
+			})
 			if err != nil {
 				return
 			}
+			verified, finished = &claimed, &next
 
-			// finish allowed only on verified state
-			if state != StateVerified {
-				err = errNotAllowed
-				return
-			} else if err != nil {
-				return
-			}
-
-			// update user fields
-			// parse pass
-			err = setUserStateFunc(tx, resources.Storage, user, StateFinished, params)
+			err = f.Finish.OnFinished(c, tx, user, request)
 			if err != nil {
 				return
 			}
 
-			if finishEventSender != nil {
+			if f.Finish.Notify != nil {
 				// notify about finish
-				err = finishEventSender(user)
+				err = f.Finish.Notify(user)
 				if err != nil {
 					return
 				}
 			}
 
 			// prepare answer
-			resp, err = respFactory(tx, user)
+			if f.Finish.Response != nil {
+				resp, err = f.Finish.Response(c, tx, user, request)
+			}
 			return
 		})
+		if err != nil && finished != nil {
+			restoreErr := f.restore(user, finished, verified)
+			if restoreErr != nil {
+				err = errors.Wrapf(restoreErr, "confirmation: finish token isn't restored after failure: %v", err)
+			}
+		}
+		if err != nil {
+			return
+		}
+
+		if f.Finish.OnCommitted != nil {
+			err = f.Finish.OnCommitted(c, user, request)
+		}
 		return
 	}
 }
 
-//
+// paramsOrErr binds request body into params, binding errors may be enriched by postValidateFunc
 func paramsOrErr(
 	c *gin.Context,
-	resources ExternalResources,
-	factory ParamsFactory,
+	params interface{},
 	postValidateFunc PostValidateFieldsFunc,
-) (params interface{}, err error) {
-	params = factory()
-	err = base.ShouldBindJSON(c, params)
-	if err != nil {
-		err = postValidateFunc(resources, params, err)
-		return
+) (interface{}, error) {
+	err := base.ShouldBindJSON(c, params)
+	if err != nil && postValidateFunc != nil {
+		err = postValidateFunc(c, params, err)
 	}
-	return
-}
-
-func genNextSendTOKey(pattern string, user models.User) string {
-	return fmt.Sprintf(pattern, user.Phone)
-}
-
-func genVerificationCodeKey(pattern string, user models.User) string {
-	return fmt.Sprintf(pattern, user.Phone)
-}
-
-func genFinishTokenKey(pattern string, user models.User) string {
-	return fmt.Sprintf(pattern, user.Phone)
+	return params, err
 }
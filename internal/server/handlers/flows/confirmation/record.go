@@ -0,0 +1,141 @@
+package confirmation
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	models "git.zam.io/wallet-backend/web-api/internal/models/user"
+	"git.zam.io/wallet-backend/web-api/pkg/services/nosql"
+	"github.com/pkg/errors"
+	"time"
+)
+
+// maxTransitionAttempts count of compare-and-swap retries after which concurrent modification reported as error
+const maxTransitionAttempts = 10
+
+var errConcurrentModification = errors.New("confirmation: flow record is concurrently modified")
+
+// Record user flow state, it's stored as a single nosql value, so every state transition is performed atomically using
+// compare-and-swap. Code and token are kept only as keyed hashes.
+type Record struct {
+	State     State     `json:"state"`
+	CodeHash  string    `json:"code_hash,omitempty"`
+	TokenHash string    `json:"token_hash,omitempty"`
+	Attempts  int       `json:"attempts,omitempty"`
+	ExpireAt  time.Time `json:"expire_at"`
+	SentAt    time.Time `json:"sent_at"`
+
+	// Subject value confirmed by the code apart from the user, e.g. new phone, may be empty
+	Subject string `json:"subject,omitempty"`
+}
+
+// Encode serializes record into the value stored in nosql
+func (r Record) Encode() string {
+	// record consists of plain fields, so marshaling never fails
+	bytes, _ := json.Marshal(r)
+	return string(bytes)
+}
+
+// Expired checks whether record code or token is expired
+func (r Record) Expired() bool {
+	return !r.ExpireAt.After(time.Now())
+}
+
+// HashSecret hashes verification code or finish token using HMAC-SHA256 with the given key before it's stored
+func HashSecret(key []byte, secret string) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(secret))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// SecretMatches checks submitted code or token against stored hash in constant time, empty hash never matches
+func SecretMatches(key []byte, submitted, hash string) bool {
+	if hash == "" {
+		return false
+	}
+	return hmac.Equal([]byte(HashSecret(key, submitted)), []byte(hash))
+}
+
+// CheckCode checks code against the record. Wrong attempts are counted in the returned record, code is burned once
+// maxAttempts reached. Nil record and error are returned if code is valid, so caller decides how record changes.
+func CheckCode(r *Record, secret []byte, code string, maxAttempts int) (*Record, error) {
+	if r == nil || r.CodeHash == "" || r.Expired() {
+		return nil, ErrFieldWrongCode
+	}
+	if SecretMatches(secret, code, r.CodeHash) {
+		return nil, nil
+	}
+
+	next := *r
+	next.Attempts++
+	if next.Attempts < maxAttempts {
+		return &next, ErrFieldWrongCode
+	}
+	next.CodeHash = ""
+	next.Attempts = 0
+	return &next, ErrFieldCodeBurned
+}
+
+// decodeRecord parses value stored in nosql
+func decodeRecord(raw interface{}) (*Record, error) {
+	str, ok := raw.(string)
+	if !ok {
+		return nil, fmt.Errorf("confirmation: unexpected flow record type %T", raw)
+	}
+
+	r := &Record{}
+	err := json.Unmarshal([]byte(str), r)
+	if err != nil {
+		return nil, errors.Wrap(err, "confirmation: malformed flow record")
+	}
+	return r, nil
+}
+
+// transition replaces user flow record, see Transition
+func (f *Flow) transition(user models.User, apply func(r *Record) (*Record, error)) error {
+	return Transition(f.Resources.Storage, f.key(f.StateKey, user), f.Expire, apply)
+}
+
+// restore puts previous user flow record back unless the record has been modified since it was replaced by the given
+// one, e.g. when finish token is consumed, but operation is failed
+func (f *Flow) restore(user models.User, replaced, previous *Record) error {
+	_, err := f.Resources.Storage.CompareAndSwap(f.key(f.StateKey, user), replaced.Encode(), previous.Encode(), f.Expire)
+	return err
+}
+
+// Transition replaces record stored under the key with the one returned by apply. Nil record is passed to apply if
+// there is no record. If apply returns nil record, nothing is stored and it's error returned as is, otherwise error is
+// returned after the record is stored. Apply is repeated if record is concurrently modified.
+func Transition(storage nosql.IStorage, key string, expire time.Duration, apply func(r *Record) (*Record, error)) error {
+	for i := 0; i < maxTransitionAttempts; i++ {
+		var current *Record
+		raw, err := storage.Get(key)
+		switch err {
+		case nil:
+			current, err = decodeRecord(raw)
+			if err != nil {
+				return err
+			}
+		case nosql.ErrNoSuchKeyFound:
+			raw = nil
+		default:
+			return err
+		}
+
+		next, applyErr := apply(current)
+		if next == nil {
+			return applyErr
+		}
+
+		swapped, err := storage.CompareAndSwap(key, raw, next.Encode(), expire)
+		if err != nil {
+			return err
+		}
+		if swapped {
+			return applyErr
+		}
+	}
+	return errConcurrentModification
+}
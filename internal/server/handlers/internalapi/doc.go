@@ -0,0 +1,3 @@
+// Package internalapi holds handlers of the internal api which is called by other services, requests are authorized by
+// service tokens instead of user sessions
+package internalapi
@@ -0,0 +1,189 @@
+package internalapi
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+
+	"git.zam.io/wallet-backend/common/pkg/types"
+	"git.zam.io/wallet-backend/web-api/db"
+	"git.zam.io/wallet-backend/web-api/internal/models/kyc"
+	models "git.zam.io/wallet-backend/web-api/internal/models/user"
+	"git.zam.io/wallet-backend/web-api/pkg/server/handlers/base"
+	"git.zam.io/wallet-backend/web-api/pkg/server/middlewares"
+	"git.zam.io/wallet-backend/web-api/pkg/services/sessions"
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+)
+
+var (
+	errServiceIsMissing = errors.New("internalapi: service identity is missing in the context")
+	errUserNotFound     = base.ErrorView{Code: http.StatusNotFound, Message: "user not found"}
+	errInvalidUserID    = base.NewFieldErr("path", "id", "user id is invalid")
+	errInvalidPhone     = base.NewFieldErr("path", "phone", "phone is invalid")
+)
+
+// CheckFactory returns handler which describes calling service, it allows services to check their tokens
+func CheckFactory() base.HandlerFunc {
+	return func(c *gin.Context) (resp interface{}, code int, err error) {
+		identity, ok := middlewares.GetServiceFromContext(c)
+		if !ok {
+			err = errServiceIsMissing
+			return
+		}
+
+		scopes := identity.Scopes
+		if scopes == nil {
+			scopes = []string{}
+		}
+		resp = ServiceView{Name: identity.Name, Scopes: scopes}
+		return
+	}
+}
+
+// UserByIDFactory returns handler which gets user by id
+func UserByIDFactory(d *db.Db) base.HandlerFunc {
+	return func(c *gin.Context) (resp interface{}, code int, err error) {
+		var user models.User
+		var status kyc.StatusType
+		err = d.Tx(func(tx db.ITx) (err error) {
+			user, err = models.GetUserByID(tx, c.Param("id"))
+			if err != nil {
+				return
+			}
+			status, err = getKYCStatus(tx, user.ID)
+			return
+		})
+		switch err {
+		case nil:
+		case models.ErrInvalidUserID:
+			err = errInvalidUserID
+			return
+		case models.ErrUserNotFound:
+			err = errUserNotFound
+			return
+		default:
+			return
+		}
+
+		resp = NewUserView(user, status)
+		return
+	}
+}
+
+// UserByPhoneFactory returns handler which gets user by phone
+func UserByPhoneFactory(d *db.Db) base.HandlerFunc {
+	return func(c *gin.Context) (resp interface{}, code int, err error) {
+		phone, err := types.NewPhone(c.Param("phone"))
+		if err != nil {
+			err = errInvalidPhone
+			return
+		}
+
+		var user models.User
+		var status kyc.StatusType
+		err = d.Tx(func(tx db.ITx) (err error) {
+			user, err = models.GetUserByPhone(tx, string(phone))
+			if err != nil {
+				return
+			}
+			status, err = getKYCStatus(tx, user.ID)
+			return
+		})
+		if err == models.ErrUserNotFound {
+			err = errUserNotFound
+		}
+		if err != nil {
+			return
+		}
+
+		resp = NewUserView(user, status)
+		return
+	}
+}
+
+// UsersBatchFactory returns handler which gets users by list of ids, missing users are reported separately
+func UsersBatchFactory(d *db.Db) base.HandlerFunc {
+	return func(c *gin.Context) (resp interface{}, code int, err error) {
+		params := UsersBatchRequest{}
+		err = base.ShouldBindJSON(c, &params)
+		if err != nil {
+			return
+		}
+
+		batch := UsersBatchResponse{Users: []UserView{}, NotFound: []string{}}
+		err = d.Tx(func(tx db.ITx) error {
+			users, err := models.GetUsersByIDs(tx, params.IDs)
+			if err != nil {
+				return err
+			}
+
+			byID := make(map[int64]models.User, len(users))
+			for _, user := range users {
+				byID[user.ID] = user
+			}
+
+			// keep order of requested ids
+			seen := make(map[int64]bool, len(params.IDs))
+			for _, id := range params.IDs {
+				if seen[id] {
+					continue
+				}
+				seen[id] = true
+
+				user, ok := byID[id]
+				if !ok {
+					batch.NotFound = append(batch.NotFound, strconv.FormatInt(id, 10))
+					continue
+				}
+				status, err := getKYCStatus(tx, user.ID)
+				if err != nil {
+					return err
+				}
+				batch.Users = append(batch.Users, NewUserView(user, status))
+			}
+			return nil
+		})
+		if err != nil {
+			return
+		}
+
+		resp = batch
+		return
+	}
+}
+
+// IntrospectFactory returns handler which describes given session token in the RFC 7662 manner, invalid, expired and
+// revoked tokens are reported as inactive. Only session data services rely on is exposed.
+func IntrospectFactory(sessStorage sessions.IStorage) base.HandlerFunc {
+	return func(c *gin.Context) (resp interface{}, code int, err error) {
+		params := IntrospectRequest{}
+		err = base.ShouldBindJSON(c, &params)
+		if err != nil {
+			return
+		}
+
+		// backends wrap token format errors
+		data, err := sessStorage.Get(sessions.Token(params.Token))
+		switch errors.Cause(err) {
+		case nil:
+		case sessions.ErrNotFound, sessions.ErrUnexpectedToken, sessions.ErrExpired, sessions.ErrRevoked:
+			resp, err = IntrospectionView{Active: false}, nil
+			return
+		default:
+			return
+		}
+
+		resp = NewIntrospectionView(data)
+		return
+	}
+}
+
+// getKYCStatus returns unloaded status if user hasn't uploaded kyc data
+func getKYCStatus(tx db.ITx, userID int64) (status kyc.StatusType, err error) {
+	status, err = kyc.GetStatus(tx, userID)
+	if err == sql.ErrNoRows {
+		status, err = kyc.StatusUnloaded, nil
+	}
+	return
+}
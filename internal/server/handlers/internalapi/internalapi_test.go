@@ -0,0 +1,257 @@
+package internalapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"git.zam.io/wallet-backend/web-api/db"
+	. "git.zam.io/wallet-backend/web-api/fixtures"
+	"git.zam.io/wallet-backend/web-api/fixtures/database"
+	"git.zam.io/wallet-backend/web-api/fixtures/database/migrations"
+	"git.zam.io/wallet-backend/web-api/internal/models/kyc"
+	models "git.zam.io/wallet-backend/web-api/internal/models/user"
+	"git.zam.io/wallet-backend/web-api/pkg/server/handlers/base"
+	"git.zam.io/wallet-backend/web-api/pkg/services/sessions"
+	"git.zam.io/wallet-backend/web-api/pkg/services/sessions/jwt"
+	sessmem "git.zam.io/wallet-backend/web-api/pkg/services/sessions/mem"
+	"github.com/gin-gonic/gin"
+	. "github.com/onsi/ginkgo"
+	"github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+const (
+	validPhone1 = "+79871111111"
+	validPhone2 = "+79871111112"
+	pass1       = "123451"
+)
+
+func TestInternalApiHandlers(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Internal Api Handlers Suite")
+}
+
+func createContext(method string, params gin.Params, body interface{}) *gin.Context {
+	var bodyCont []byte
+	if body != nil {
+		var err error
+		bodyCont, err = json.Marshal(body)
+		if err != nil {
+			panic(err)
+		}
+	}
+
+	req, err := http.NewRequest(method, "NOT DEFINED", bytes.NewBuffer(bodyCont))
+	if err != nil {
+		panic(err)
+	}
+
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = req
+	c.Params = params
+	return c
+}
+
+func idParams(id int64) gin.Params {
+	return gin.Params{{Key: "id", Value: fmt.Sprint(id)}}
+}
+
+// testUsers referrer with kyc data and referral without
+type testUsers struct {
+	referrer, referral models.User
+}
+
+var _ = Describe("Given the internal api", func() {
+	Init()
+	database.Init()
+	migrations.Init()
+
+	BeforeEachCProvide(func(d *db.Db) testUsers {
+		referrer, err := models.NewUser(validPhone1, pass1, models.UserStatusActive, nil)
+		Expect(err).NotTo(HaveOccurred())
+		referrer, err = models.CreateUser(d, referrer)
+		Expect(err).NotTo(HaveOccurred())
+
+		_, err = kyc.Create(d, &kyc.Data{
+			UserID:    referrer.ID,
+			Status:    kyc.StatusPending,
+			Email:     "test@example.com",
+			FirstName: "First",
+			LastName:  "Last",
+			BirthDate: time.Date(1990, 1, 1, 0, 0, 0, 0, time.UTC),
+			Sex:       "male",
+			Country:   "Country",
+			Address:   map[string]interface{}{"city": "City"},
+		})
+		Expect(err).NotTo(HaveOccurred())
+
+		referrerPhone := validPhone1
+		referral, err := models.NewUser(validPhone2, pass1, models.UserStatusPending, &referrerPhone)
+		Expect(err).NotTo(HaveOccurred())
+		referral, err = models.CreateUser(d, referral)
+		Expect(err).NotTo(HaveOccurred())
+
+		return testUsers{referrer: referrer, referral: referral}
+	})
+
+	Context("when querying user by id", func() {
+		BeforeEachCProvide(func(d *db.Db) base.HandlerFunc {
+			return UserByIDFactory(d)
+		})
+
+		ItD("should return user with kyc status", func(handler base.HandlerFunc, users testUsers) {
+			resp, _, err := handler(createContext("GET", idParams(users.referrer.ID), nil))
+			Expect(err).NotTo(HaveOccurred())
+
+			view := resp.(UserView)
+			Expect(view.Phone).To(Equal(validPhone1))
+			Expect(view.Status).To(Equal(string(models.UserStatusActive)))
+			Expect(view.KYC).To(Equal(string(kyc.StatusPending)))
+			Expect(view.RegisteredAt).NotTo(BeNil())
+			Expect(view.Referrer).To(BeNil())
+		})
+
+		ItD("should return referrer and unloaded kyc", func(handler base.HandlerFunc, users testUsers) {
+			resp, _, err := handler(createContext("GET", idParams(users.referral.ID), nil))
+			Expect(err).NotTo(HaveOccurred())
+
+			view := resp.(UserView)
+			Expect(view.KYC).To(Equal(string(kyc.StatusUnloaded)))
+			Expect(view.Referrer).To(Equal(&ReferrerView{ID: fmt.Sprint(users.referrer.ID), Phone: validPhone1}))
+		})
+
+		ItD("should fail due to invalid or unknown id", func(handler base.HandlerFunc) {
+			_, _, err := handler(createContext("GET", gin.Params{{Key: "id", Value: "abc"}}, nil))
+			Expect(err).To(Equal(errInvalidUserID))
+
+			_, _, err = handler(createContext("GET", gin.Params{{Key: "id", Value: "100500"}}, nil))
+			Expect(err).To(Equal(errUserNotFound))
+		})
+	})
+
+	Context("when querying user by phone", func() {
+		BeforeEachCProvide(func(d *db.Db) base.HandlerFunc {
+			return UserByPhoneFactory(d)
+		})
+
+		ItD("should return user", func(handler base.HandlerFunc, users testUsers) {
+			resp, _, err := handler(createContext("GET", gin.Params{{Key: "phone", Value: validPhone2}}, nil))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(resp.(UserView).ID).To(Equal(fmt.Sprint(users.referral.ID)))
+		})
+	})
+
+	Context("when querying users batch", func() {
+		BeforeEachCProvide(func(d *db.Db) base.HandlerFunc {
+			return UsersBatchFactory(d)
+		})
+
+		ItD("should return users in requested order", func(handler base.HandlerFunc, users testUsers) {
+			resp, _, err := handler(createContext("POST", nil, map[string]interface{}{
+				"ids": []int64{users.referral.ID, 100500, users.referrer.ID, users.referral.ID},
+			}))
+			Expect(err).NotTo(HaveOccurred())
+
+			batch := resp.(UsersBatchResponse)
+			Expect(batch.Users).To(HaveLen(2))
+			Expect(batch.Users[0].ID).To(Equal(fmt.Sprint(users.referral.ID)))
+			Expect(batch.Users[1].ID).To(Equal(fmt.Sprint(users.referrer.ID)))
+			Expect(batch.NotFound).To(Equal([]string{"100500"}))
+		})
+
+		ItD("should fail due to empty ids", func(handler base.HandlerFunc) {
+			_, _, err := handler(createContext("POST", nil, map[string]interface{}{"ids": []int64{}}))
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Context("when introspecting token", func() {
+		BeforeEachCProvide(func() sessions.IStorage {
+			return sessmem.New(func(data map[string]interface{}) string {
+				return fmt.Sprint(data["phone"])
+			})
+		})
+		BeforeEachCProvide(func(storage sessions.IStorage) base.HandlerFunc {
+			return IntrospectFactory(storage)
+		})
+
+		ItD("should describe active token", func(handler base.HandlerFunc, storage sessions.IStorage) {
+			token, err := storage.New(map[string]interface{}{
+				"id":               int64(5),
+				"phone":            validPhone1,
+				"roles":            []string{"user"},
+				sessions.IPKey:     "127.0.0.1",
+				sessions.DeviceKey: "phone",
+			}, time.Hour)
+			Expect(err).NotTo(HaveOccurred())
+
+			resp, _, err := handler(createContext("POST", nil, map[string]interface{}{"token": string(token)}))
+			Expect(err).NotTo(HaveOccurred())
+
+			view := resp.(IntrospectionView)
+			Expect(view.Active).To(BeTrue())
+			Expect(view.TokenType).To(Equal(sessions.TokenTypeAccess))
+			Expect(view.Sub).To(Equal("5"))
+			Expect(view.SessionID).NotTo(BeEmpty())
+			Expect(view.Exp).NotTo(BeNil())
+			Expect(view.Iat).NotTo(BeNil())
+			Expect(*view.Exp - *view.Iat).To(BeNumerically("~", int64(time.Hour/time.Second), 1))
+			Expect(view.Data).To(Equal(map[string]interface{}{"phone": validPhone1, sessions.DeviceKey: "phone"}))
+		})
+
+		ItD("should report deleted and malformed tokens as inactive", func(handler base.HandlerFunc, storage sessions.IStorage) {
+			token, err := storage.New(map[string]interface{}{"id": int64(5), "phone": validPhone1}, time.Hour)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(storage.Delete(token)).To(Succeed())
+
+			for _, t := range []string{string(token), "not a token"} {
+				resp, _, err := handler(createContext("POST", nil, map[string]interface{}{"token": t}))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(resp).To(Equal(IntrospectionView{Active: false}))
+			}
+		})
+
+		ItD("should fail due to missing token", func(handler base.HandlerFunc) {
+			_, _, err := handler(createContext("POST", nil, map[string]interface{}{}))
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Context("when introspecting jwt token", func() {
+		secret := []byte("secret")
+		handler := IntrospectFactory(jwt.New("HS256", secret, time.Now))
+		issue := func(secret []byte, nowFunc func() time.Time) string {
+			data := map[string]interface{}{"phone": validPhone1}
+			token, err := jwt.New("HS256", secret, nowFunc).New(data, time.Minute)
+			Expect(err).NotTo(HaveOccurred())
+			return string(token)
+		}
+
+		It("should describe active token", func() {
+			resp, _, err := handler(createContext("POST", nil, map[string]interface{}{"token": issue(secret, time.Now)}))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(resp.(IntrospectionView).Active).To(BeTrue())
+		})
+
+		table.DescribeTable(
+			"should report invalid token as inactive",
+			func(token func() string) {
+				resp, _, err := handler(createContext("POST", nil, map[string]interface{}{"token": token()}))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(resp).To(Equal(IntrospectionView{Active: false}))
+			},
+			table.Entry("expired token", func() string {
+				return issue(secret, func() time.Time { return time.Now().Add(-time.Hour) })
+			}),
+			table.Entry("token signed by the unknown key", func() string {
+				return issue([]byte("other secret"), time.Now)
+			}),
+			table.Entry("malformed token", func() string { return "garbage" }),
+			table.Entry("token with malformed segments", func() string { return "a.b.c" }),
+		)
+	})
+})
@@ -0,0 +1,11 @@
+package internalapi
+
+// UsersBatchRequest
+type UsersBatchRequest struct {
+	IDs []int64 `json:"ids" validate:"required,min=1,max=100"`
+}
+
+// IntrospectRequest
+type IntrospectRequest struct {
+	Token string `json:"token" validate:"required"`
+}
@@ -0,0 +1,44 @@
+package internalapi
+
+import (
+	"git.zam.io/wallet-backend/web-api/db"
+	"git.zam.io/wallet-backend/web-api/pkg/server/handlers/base"
+	"git.zam.io/wallet-backend/web-api/pkg/server/middlewares"
+	"git.zam.io/wallet-backend/web-api/pkg/services/sessions"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/dig"
+)
+
+// Scopes of the internal api
+const (
+	// ScopeUsersRead allows services to lookup users
+	ScopeUsersRead = "users:read"
+
+	// ScopeTokensIntrospect allows services to introspect user session tokens
+	ScopeTokensIntrospect = "tokens:introspect"
+)
+
+// Dependencies dependencies used by internal api endpoints
+type Dependencies struct {
+	dig.In
+
+	Db          *db.Db
+	SessStorage sessions.IStorage
+	Routes      gin.IRouter `name:"internal_routes"`
+}
+
+// Register
+func Register(deps Dependencies) {
+	deps.Routes.GET("/check", base.WrapHandler(CheckFactory()))
+
+	users := deps.Routes.Group("/users", middlewares.RequireScope(ScopeUsersRead))
+	users.GET("/id/:id", base.WrapHandler(UserByIDFactory(deps.Db)))
+	users.GET("/phone/:phone", base.WrapHandler(UserByPhoneFactory(deps.Db)))
+	users.POST("/batch", base.WrapHandler(UsersBatchFactory(deps.Db)))
+
+	deps.Routes.POST(
+		"/introspect",
+		middlewares.RequireScope(ScopeTokensIntrospect),
+		base.WrapHandler(IntrospectFactory(deps.SessStorage)),
+	)
+}
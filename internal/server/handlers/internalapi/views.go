@@ -0,0 +1,109 @@
+package internalapi
+
+import (
+	"fmt"
+	"git.zam.io/wallet-backend/web-api/internal/models/kyc"
+	models "git.zam.io/wallet-backend/web-api/internal/models/user"
+	"git.zam.io/wallet-backend/web-api/pkg/services/nosql"
+	"git.zam.io/wallet-backend/web-api/pkg/services/sessions"
+	"strconv"
+)
+
+// ServiceView represents authorized service
+type ServiceView struct {
+	Name   string   `json:"name"`
+	Scopes []string `json:"scopes"`
+}
+
+// UserView represents user for other services
+type UserView struct {
+	ID           string        `json:"id"`
+	Phone        string        `json:"phone"`
+	Status       string        `json:"status"`
+	KYC          string        `json:"kyc"`
+	RegisteredAt *int64        `json:"registered_at"`
+	Referrer     *ReferrerView `json:"referrer"`
+}
+
+// ReferrerView
+type ReferrerView struct {
+	ID    string `json:"id"`
+	Phone string `json:"phone"`
+}
+
+// UsersBatchResponse holds found users in order of requested ids and ids of missing users
+type UsersBatchResponse struct {
+	Users    []UserView `json:"users"`
+	NotFound []string   `json:"not_found"`
+}
+
+// NewUserView
+func NewUserView(user models.User, kycStatus kyc.StatusType) UserView {
+	view := UserView{
+		ID:     strconv.FormatInt(user.ID, 10),
+		Phone:  string(user.Phone),
+		Status: string(user.Status),
+		KYC:    string(kycStatus),
+	}
+	if user.RegisteredAt != nil {
+		registeredAt := user.RegisteredAt.Unix()
+		view.RegisteredAt = &registeredAt
+	}
+	if user.ReferrerID != nil && user.ReferrerPhone != nil {
+		view.Referrer = &ReferrerView{
+			ID:    strconv.FormatInt(*user.ReferrerID, 10),
+			Phone: *user.ReferrerPhone,
+		}
+	}
+	return view
+}
+
+// IntrospectionView describes session token, all fields except active are omitted for inactive tokens
+type IntrospectionView struct {
+	Active    bool                   `json:"active"`
+	TokenType string                 `json:"token_type,omitempty"`
+	Sub       string                 `json:"sub,omitempty"`
+	SessionID string                 `json:"session_id,omitempty"`
+	Exp       *int64                 `json:"exp,omitempty"`
+	Iat       *int64                 `json:"iat,omitempty"`
+	Data      map[string]interface{} `json:"data,omitempty"`
+}
+
+// introspectionDataKeys session data keys exposed by the introspection, other data such as client ip or user roles
+// isn't required by the services, so it isn't exposed
+var introspectionDataKeys = []string{"phone", sessions.DeviceKey}
+
+// NewIntrospectionView creates view of the active token from the session data returned by the sessions storage
+func NewIntrospectionView(data map[string]interface{}) IntrospectionView {
+	view := IntrospectionView{
+		Active:    true,
+		TokenType: sessions.TokenTypeAccess,
+		Exp:       timestampFromData(data, sessions.ExpiresAtKey),
+		Iat:       timestampFromData(data, sessions.IssuedAtKey),
+	}
+	for _, key := range introspectionDataKeys {
+		if val, ok := data[key]; ok {
+			if view.Data == nil {
+				view.Data = make(map[string]interface{}, len(introspectionDataKeys))
+			}
+			view.Data[key] = val
+		}
+	}
+	if tokenType, ok := data[sessions.TokenTypeKey].(string); ok {
+		view.TokenType = tokenType
+	}
+	if id, ok := data["id"]; ok && id != nil {
+		view.Sub = fmt.Sprint(id)
+	}
+	view.SessionID, _ = data[sessions.SessionIDKey].(string)
+	return view
+}
+
+// timestampFromData returns nil if there is no such timestamp
+func timestampFromData(data map[string]interface{}, key string) *int64 {
+	ts, err := nosql.Int64(data[key])
+	if err != nil {
+		return nil
+	}
+	return &ts
+}
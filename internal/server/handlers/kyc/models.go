@@ -61,7 +61,7 @@ func CreateGetResponse(data *kyc.Data) GetResponse {
 	if data != nil {
 		status = string(data.Status)
 	} else {
-		status = "unloaded"
+		status = string(kyc.StatusUnloaded)
 	}
 
 	return GetResponse{
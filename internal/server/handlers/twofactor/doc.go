@@ -0,0 +1,2 @@
+// Package twofactor holds handlers which manage user TOTP two-factor authentication
+package twofactor
@@ -0,0 +1,321 @@
+package twofactor
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"git.zam.io/wallet-backend/web-api/db"
+	models "git.zam.io/wallet-backend/web-api/internal/models/twofactor"
+	"git.zam.io/wallet-backend/web-api/pkg/server/handlers/base"
+	"git.zam.io/wallet-backend/web-api/pkg/server/middlewares"
+	"git.zam.io/wallet-backend/web-api/pkg/services/throttle"
+	"git.zam.io/wallet-backend/web-api/pkg/services/totp"
+	"github.com/gin-gonic/gin"
+	"github.com/mitchellh/mapstructure"
+	"github.com/pkg/errors"
+)
+
+// backupCodesCount count of backup codes generated at once
+const backupCodesCount = 10
+
+var (
+	errAlreadyEnabled  = base.ErrorView{Code: http.StatusBadRequest, Message: "2fa already enabled"}
+	errNotEnrolled     = base.ErrorView{Code: http.StatusBadRequest, Message: "2fa not enrolled"}
+	errNotEnabled      = base.ErrorView{Code: http.StatusBadRequest, Message: "2fa not enabled"}
+	errInvalidCode     = base.NewFieldErr("body", "code", "code is invalid")
+	errTooManyAttempts = base.ErrorView{Code: http.StatusTooManyRequests, Message: "too many attempts"}
+)
+
+// StatusFactory
+func StatusFactory(d *db.Db) base.HandlerFunc {
+	return func(c *gin.Context) (resp interface{}, code int, err error) {
+		userID, err := getUserIDFromContext(c)
+		if err != nil {
+			return
+		}
+
+		data, err := models.Get(d, userID)
+		if err == models.ErrNotFound {
+			// 2fa hasn't been enrolled, so show disabled
+			resp, err = StatusResponse{}, nil
+			return
+		}
+		if err != nil {
+			return
+		}
+
+		view := StatusResponse{Enabled: data.Enabled}
+		if data.Enabled {
+			view.BackupCodesLeft = len(data.BackupCodes)
+		}
+		resp = view
+		return
+	}
+}
+
+// EnrollFactory returns handler which generates new TOTP secret, 2FA remains disabled until secret is confirmed, so
+// repeated enrollment simply replaces unconfirmed secret
+func EnrollFactory(d *db.Db, issuer string) base.HandlerFunc {
+	return func(c *gin.Context) (resp interface{}, code int, err error) {
+		userID, err := getUserIDFromContext(c)
+		if err != nil {
+			return
+		}
+		phone, err := getUserPhoneFromContext(c)
+		if err != nil {
+			return
+		}
+
+		secret, err := totp.GenerateSecret()
+		if err != nil {
+			return
+		}
+
+		err = d.Tx(func(tx db.ITx) error {
+			data, err := models.Get(tx, userID, true)
+			switch {
+			case err == models.ErrNotFound:
+			case err != nil:
+				return err
+			case data.Enabled:
+				return errAlreadyEnabled
+			}
+
+			return models.Save(tx, &models.Data{
+				UserID:    userID,
+				Secret:    secret,
+				CreatedAt: time.Now().UTC(),
+			})
+		})
+		if err != nil {
+			return
+		}
+
+		resp = EnrollResponse{Secret: secret, ProvisioningURI: totp.ProvisioningURI(issuer, phone, secret)}
+		return
+	}
+}
+
+// ConfirmFactory returns handler which enables 2FA if given code matches enrolled secret, backup codes are generated
+// and returned. Failed attempts are limited per user.
+func ConfirmFactory(d *db.Db, limiter *throttle.Limiter) base.HandlerFunc {
+	return func(c *gin.Context) (resp interface{}, code int, err error) {
+		params := CodeRequest{}
+		err = base.ShouldBindJSON(c, &params)
+		if err != nil {
+			return
+		}
+
+		userID, err := getUserIDFromContext(c)
+		if err != nil {
+			return
+		}
+		subject := fmt.Sprint(userID)
+		err = checkLocked(c, limiter, subject)
+		if err != nil {
+			return
+		}
+
+		backupCodes, err := totp.GenerateBackupCodes(backupCodesCount)
+		if err != nil {
+			return
+		}
+
+		err = d.Tx(func(tx db.ITx) error {
+			data, err := models.Get(tx, userID, true)
+			switch {
+			case err == models.ErrNotFound:
+				return errNotEnrolled
+			case err != nil:
+				return err
+			case data.Enabled:
+				return errAlreadyEnabled
+			}
+
+			// backup codes aren't generated yet, so only TOTP code is accepted here
+			now := time.Now().UTC()
+			ok, err := data.Verify(params.Code, now)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				return errInvalidCode
+			}
+
+			data.Enabled = true
+			data.ConfirmedAt = &now
+			data.SetBackupCodes(backupCodes)
+			return models.Save(tx, data)
+		})
+		err = registerAttempt(c, limiter, subject, err)
+		if err != nil {
+			return
+		}
+
+		resp = BackupCodesResponse{BackupCodes: backupCodes}
+		return
+	}
+}
+
+// DisableFactory returns handler which disables 2FA, requires valid TOTP or backup code
+func DisableFactory(d *db.Db, limiter *throttle.Limiter) base.HandlerFunc {
+	return func(c *gin.Context) (resp interface{}, code int, err error) {
+		params := CodeRequest{}
+		err = base.ShouldBindJSON(c, &params)
+		if err != nil {
+			return
+		}
+
+		userID, err := getUserIDFromContext(c)
+		if err != nil {
+			return
+		}
+		subject := fmt.Sprint(userID)
+		err = checkLocked(c, limiter, subject)
+		if err != nil {
+			return
+		}
+
+		err = d.Tx(func(tx db.ITx) error {
+			_, err := verifyEnabled(tx, userID, params.Code)
+			if err != nil {
+				return err
+			}
+			return models.Delete(tx, userID)
+		})
+		err = registerAttempt(c, limiter, subject, err)
+		return
+	}
+}
+
+// BackupCodesFactory returns handler which replaces backup codes with new ones, requires valid TOTP or backup code
+func BackupCodesFactory(d *db.Db, limiter *throttle.Limiter) base.HandlerFunc {
+	return func(c *gin.Context) (resp interface{}, code int, err error) {
+		params := CodeRequest{}
+		err = base.ShouldBindJSON(c, &params)
+		if err != nil {
+			return
+		}
+
+		userID, err := getUserIDFromContext(c)
+		if err != nil {
+			return
+		}
+		subject := fmt.Sprint(userID)
+		err = checkLocked(c, limiter, subject)
+		if err != nil {
+			return
+		}
+
+		backupCodes, err := totp.GenerateBackupCodes(backupCodesCount)
+		if err != nil {
+			return
+		}
+
+		err = d.Tx(func(tx db.ITx) error {
+			data, err := verifyEnabled(tx, userID, params.Code)
+			if err != nil {
+				return err
+			}
+			data.SetBackupCodes(backupCodes)
+			return models.Save(tx, data)
+		})
+		err = registerAttempt(c, limiter, subject, err)
+		if err != nil {
+			return
+		}
+
+		resp = BackupCodesResponse{BackupCodes: backupCodes}
+		return
+	}
+}
+
+// verifyEnabled locks user TOTP data and verifies code, accepted code is marked as spent within the same transaction
+func verifyEnabled(tx db.ITx, userID int64, code string) (data *models.Data, err error) {
+	data, err = models.Get(tx, userID, true)
+	switch {
+	case err == models.ErrNotFound:
+		return nil, errNotEnabled
+	case err != nil:
+		return
+	case !data.Enabled:
+		return nil, errNotEnabled
+	}
+
+	ok, err := data.Verify(code, time.Now().UTC())
+	if err != nil {
+		return
+	}
+	if !ok {
+		return nil, errInvalidCode
+	}
+	err = models.Save(tx, data)
+	return
+}
+
+// checkLocked returns too many attempts error if user is locked due to failed attempts
+func checkLocked(c *gin.Context, limiter *throttle.Limiter, subject string) error {
+	until, locked, err := limiter.LockedUntil(subject)
+	if err != nil || !locked {
+		return err
+	}
+	return tooManyAttempts(c, until)
+}
+
+// registerAttempt counts invalid code as failed attempt, failures are reset by the successful one
+func registerAttempt(c *gin.Context, limiter *throttle.Limiter, subject string, err error) error {
+	if err != nil && err != errInvalidCode {
+		return err
+	}
+	if err == nil {
+		return limiter.Reset(subject)
+	}
+
+	until, locked, err := limiter.Fail(subject)
+	if err != nil {
+		return err
+	}
+	if locked {
+		return tooManyAttempts(c, until)
+	}
+	return errInvalidCode
+}
+
+func tooManyAttempts(c *gin.Context, until time.Time) error {
+	retryAfter := int64(math.Ceil(time.Until(until).Seconds()))
+	if retryAfter < 1 {
+		retryAfter = 1
+	}
+	c.Header("Retry-After", strconv.FormatInt(retryAfter, 10))
+	return errTooManyAttempts
+}
+
+func getUserIDFromContext(c *gin.Context) (id int64, err error) {
+	var userID struct {
+		ID int64
+	}
+
+	data := middlewares.GetUserDataFromContext(c)
+	if data == nil {
+		err = errors.New("twofactor: user auth middleware is missing")
+		return
+	}
+	err = mapstructure.Decode(data, &userID)
+	if err != nil {
+		err = errors.Wrap(err, "twofactor")
+	}
+	id = userID.ID
+	return
+}
+
+func getUserPhoneFromContext(c *gin.Context) (phone string, err error) {
+	data := middlewares.GetUserDataFromContext(c)
+	phone, ok := data["phone"].(string)
+	if !ok {
+		err = errors.New("twofactor: user phone is missing in the session data")
+	}
+	return
+}
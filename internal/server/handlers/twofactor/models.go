@@ -0,0 +1,24 @@
+package twofactor
+
+// CodeRequest holds either TOTP code or one of the backup codes
+type CodeRequest struct {
+	Code string `json:"code" validate:"required,max=32"`
+}
+
+// StatusResponse
+type StatusResponse struct {
+	Enabled         bool `json:"enabled"`
+	BackupCodesLeft int  `json:"backup_codes_left"`
+}
+
+// EnrollResponse holds secret which must be added into authenticator app, usually by scanning provisioning uri shown
+// as QR code
+type EnrollResponse struct {
+	Secret          string `json:"secret"`
+	ProvisioningURI string `json:"provisioning_uri"`
+}
+
+// BackupCodesResponse holds backup codes, they are shown only once
+type BackupCodesResponse struct {
+	BackupCodes []string `json:"backup_codes"`
+}
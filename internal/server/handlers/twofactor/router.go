@@ -0,0 +1,42 @@
+package twofactor
+
+import (
+	"git.zam.io/wallet-backend/web-api/config/server"
+	"git.zam.io/wallet-backend/web-api/db"
+	"git.zam.io/wallet-backend/web-api/pkg/server/handlers/base"
+	"git.zam.io/wallet-backend/web-api/pkg/services/nosql"
+	"git.zam.io/wallet-backend/web-api/pkg/services/throttle"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/dig"
+	"time"
+)
+
+// Dependencies dependencies used by 2FA endpoints
+type Dependencies struct {
+	dig.In
+
+	Db             *db.Db
+	Routes         gin.IRouter     `name:"api_routes"`
+	AuthMiddleware gin.HandlerFunc `name:"auth"`
+	Storage        nosql.IStorage
+	Conf           server.Scheme
+}
+
+// Register
+func Register(deps Dependencies) {
+	// invalid codes are limited the same way as failed signin attempts
+	throttleConf := deps.Conf.Auth.SigninThrottle
+	limiter := throttle.New(deps.Storage, "2fa:user", throttle.Params{
+		Window:      throttleConf.Window,
+		MaxFailures: throttleConf.MaxPhoneFailures,
+		Lockout:     throttleConf.Lockout,
+		MaxLockout:  throttleConf.MaxLockout,
+	}, func() time.Time { return time.Now().UTC() })
+
+	group := deps.Routes.Group("/user/me/2fa", deps.AuthMiddleware)
+	group.GET("", base.WrapHandler(StatusFactory(deps.Db)))
+	group.POST("", base.WrapHandler(EnrollFactory(deps.Db, deps.Conf.Auth.TwoFactor.Issuer)))
+	group.DELETE("", base.WrapHandler(DisableFactory(deps.Db, limiter)))
+	group.POST("/confirm", base.WrapHandler(ConfirmFactory(deps.Db, limiter)))
+	group.POST("/backup_codes", base.WrapHandler(BackupCodesFactory(deps.Db, limiter)))
+}
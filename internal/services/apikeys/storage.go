@@ -0,0 +1,66 @@
+// Package apikeys resolves personal api keys into the user data for the auth middleware
+package apikeys
+
+import (
+	"fmt"
+	"time"
+
+	"git.zam.io/wallet-backend/web-api/db"
+	"git.zam.io/wallet-backend/web-api/internal/models/apikeys"
+	models "git.zam.io/wallet-backend/web-api/internal/models/user"
+	"git.zam.io/wallet-backend/web-api/pkg/server/middlewares"
+)
+
+// storage implements middlewares.IAPIKeyStorage on top of the api keys table
+type storage struct {
+	d             *db.Db
+	touchThrottle time.Duration
+}
+
+// New api keys storage, key usage time is written at most once per touch throttle interval
+func New(d *db.Db, touchThrottle time.Duration) middlewares.IAPIKeyStorage {
+	return &storage{d: d, touchThrottle: touchThrottle}
+}
+
+// Get returns user data of the active user which owns the key, data holds the same user fields as the session data
+func (s *storage) Get(key string) (data map[string]interface{}, err error) {
+	err = s.d.Tx(func(tx db.ITx) error {
+		k, err := apikeys.GetByHash(tx, apikeys.Hash(key))
+		if err == apikeys.ErrNotFound {
+			return middlewares.ErrAPIKeyInvalid
+		}
+		if err != nil {
+			return err
+		}
+
+		user, err := models.GetUserByID(tx, fmt.Sprint(k.UserID))
+		if err == models.ErrUserNotFound || (err == nil && user.Status != models.UserStatusActive) {
+			return middlewares.ErrAPIKeyInvalid
+		}
+		if err != nil {
+			return err
+		}
+
+		roles, err := models.GetUserRoles(tx, user.ID)
+		if err != nil {
+			return err
+		}
+
+		now := time.Now().UTC()
+		if k.LastUsedAt == nil || now.Sub(*k.LastUsedAt) >= s.touchThrottle {
+			err = apikeys.Touch(tx, k.ID, now)
+			if err != nil {
+				return err
+			}
+		}
+
+		data = map[string]interface{}{
+			"id":                        user.ID,
+			"phone":                     string(user.Phone),
+			middlewares.RolesKey:        roles,
+			middlewares.APIKeyScopesKey: k.Scopes,
+		}
+		return nil
+	})
+	return
+}
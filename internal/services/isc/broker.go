@@ -2,6 +2,7 @@ package isc
 
 import (
 	"git.zam.io/wallet-backend/web-api/pkg/services/broker"
+	"time"
 )
 
 const (
@@ -12,6 +13,19 @@ const (
 
 	actionPasswordRecoveryVerificationRequired = "password_recovery_verification_required_event"
 	actionPasswordRecoveryCompleted            = "password_recovery_completed_event"
+
+	actionSigninVerificationRequired = "signin_verification_required_event"
+
+	actionPasswordChanged = "password_changed_event"
+
+	actionPhoneChangeVerificationRequired = "phone_change_verification_required_event"
+	actionPhoneChanged                    = "phone_changed_event"
+
+	actionAccountDeletionVerificationRequired = "account_deletion_verification_required_event"
+	actionAccountDeleted                      = "account_deleted_event"
+
+	actionSigninLocked    = "signin_locked_event"
+	actionNewDeviceSignin = "new_device_signin_event"
 )
 
 // notificator implements IEventNotificator sending events thought broker according to docs
@@ -25,11 +39,15 @@ func New(b broker.IBroker) IEventNotificator {
 }
 
 // RegistrationVerificationRequested
-func (n notificator) RegistrationVerificationRequested(userID, userPhone, verificationCode string) error {
+func (n notificator) RegistrationVerificationRequested(
+	userID, userPhone, verificationCode string, delivery Delivery,
+) error {
 	return n.b.Publish(identifier(actionRegistrationVerificationRequired, userID), pl{
 		"user_id":           userID,
 		"user_phone":        userPhone,
 		"verification_code": verificationCode,
+		"channel":           string(delivery.Channel),
+		"email":             delivery.Email,
 	})
 }
 
@@ -42,11 +60,15 @@ func (n notificator) RegistrationCompleted(userID, userPhone string) error {
 }
 
 // PasswordRecoveryVerificationRequested
-func (n notificator) PasswordRecoveryVerificationRequested(userID, userPhone, verificationCode string) error {
+func (n notificator) PasswordRecoveryVerificationRequested(
+	userID, userPhone, verificationCode string, delivery Delivery,
+) error {
 	return n.b.Publish(identifier(actionPasswordRecoveryVerificationRequired, userID), pl{
 		"user_id":       userID,
 		"user_phone":    userPhone,
 		"recovery_code": verificationCode,
+		"channel":       string(delivery.Channel),
+		"email":         delivery.Email,
 	})
 }
 
@@ -58,6 +80,90 @@ func (n notificator) PasswordRecoveryCompleted(userID, userPhone string) error {
 	})
 }
 
+// SigninVerificationRequested
+func (n notificator) SigninVerificationRequested(
+	userID, userPhone, verificationCode string, delivery Delivery,
+) error {
+	return n.b.Publish(identifier(actionSigninVerificationRequired, userID), pl{
+		"user_id":           userID,
+		"user_phone":        userPhone,
+		"verification_code": verificationCode,
+		"channel":           string(delivery.Channel),
+		"email":             delivery.Email,
+	})
+}
+
+// PasswordChanged
+func (n notificator) PasswordChanged(userID, userPhone string) error {
+	return n.b.Publish(identifier(actionPasswordChanged, userID), pl{
+		"user_id":    userID,
+		"user_phone": userPhone,
+	})
+}
+
+// PhoneChangeVerificationRequested
+func (n notificator) PhoneChangeVerificationRequested(
+	userID, userPhone, verificationCode string, delivery Delivery,
+) error {
+	return n.b.Publish(identifier(actionPhoneChangeVerificationRequired, userID), pl{
+		"user_id":           userID,
+		"user_phone":        userPhone,
+		"verification_code": verificationCode,
+		"channel":           string(delivery.Channel),
+		"email":             delivery.Email,
+	})
+}
+
+// PhoneChanged
+func (n notificator) PhoneChanged(userID, oldPhone, newPhone string) error {
+	return n.b.Publish(identifier(actionPhoneChanged, userID), pl{
+		"user_id":   userID,
+		"old_phone": oldPhone,
+		"new_phone": newPhone,
+	})
+}
+
+// AccountDeletionVerificationRequested
+func (n notificator) AccountDeletionVerificationRequested(
+	userID, userPhone, verificationCode string, delivery Delivery,
+) error {
+	return n.b.Publish(identifier(actionAccountDeletionVerificationRequired, userID), pl{
+		"user_id":           userID,
+		"user_phone":        userPhone,
+		"verification_code": verificationCode,
+		"channel":           string(delivery.Channel),
+		"email":             delivery.Email,
+	})
+}
+
+// AccountDeleted
+func (n notificator) AccountDeleted(userID, userPhone string) error {
+	return n.b.Publish(identifier(actionAccountDeleted, userID), pl{
+		"user_id":    userID,
+		"user_phone": userPhone,
+	})
+}
+
+// SigninLocked
+func (n notificator) SigninLocked(userID, userPhone, ip string, lockedUntil time.Time) error {
+	return n.b.Publish(identifier(actionSigninLocked, userID), pl{
+		"user_id":      userID,
+		"user_phone":   userPhone,
+		"ip":           ip,
+		"locked_until": lockedUntil.Unix(),
+	})
+}
+
+// NewDeviceSignin
+func (n notificator) NewDeviceSignin(userID, userPhone, device, ip string) error {
+	return n.b.Publish(identifier(actionNewDeviceSignin, userID), pl{
+		"user_id":    userID,
+		"user_phone": userPhone,
+		"device":     device,
+		"ip":         ip,
+	})
+}
+
 func identifier(action, id string) broker.Identifier {
 	return broker.Identifier{
 		Resource: resource,
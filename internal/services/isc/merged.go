@@ -2,6 +2,7 @@ package isc
 
 import (
 	"git.zam.io/wallet-backend/web-api/internal/services/notifications"
+	"time"
 )
 
 // mergedNotificator wraps event notificator and old notificator sending notification simultaneously
@@ -15,19 +16,23 @@ func NewMerged(eventNotificator IEventNotificator, oldNotificator notifications.
 	return &mergedNotificator{eventNotificator, oldNotificator}
 }
 
-func (n *mergedNotificator) RegistrationVerificationRequested(userID, userPhone, verificationCode string) error {
+func (n *mergedNotificator) RegistrationVerificationRequested(
+	userID, userPhone, verificationCode string, delivery Delivery,
+) error {
 	err := n.oldNotificator.Send(
 		notifications.ActionRegistrationConfirmationRequested,
 		map[string]interface{}{
-			"phone": userPhone,
-			"code":  verificationCode,
+			"phone":   userPhone,
+			"code":    verificationCode,
+			"channel": delivery.Channel,
+			"email":   delivery.Email,
 		},
 		notifications.Urgent,
 	)
 	if err != nil {
 		return err
 	}
-	return n.eventNotificator.RegistrationVerificationRequested(userID, userPhone, verificationCode)
+	return n.eventNotificator.RegistrationVerificationRequested(userID, userPhone, verificationCode, delivery)
 }
 
 func (n *mergedNotificator) RegistrationCompleted(userID, userPhone string) error {
@@ -44,19 +49,23 @@ func (n *mergedNotificator) RegistrationCompleted(userID, userPhone string) erro
 	return n.eventNotificator.RegistrationCompleted(userID, userPhone)
 }
 
-func (n *mergedNotificator) PasswordRecoveryVerificationRequested(userID, userPhone, verificationCode string) error {
+func (n *mergedNotificator) PasswordRecoveryVerificationRequested(
+	userID, userPhone, verificationCode string, delivery Delivery,
+) error {
 	err := n.oldNotificator.Send(
 		notifications.ActionPasswordRecoveryConfirmationRequested,
 		map[string]interface{}{
-			"phone": userPhone,
-			"code":  verificationCode,
+			"phone":   userPhone,
+			"code":    verificationCode,
+			"channel": delivery.Channel,
+			"email":   delivery.Email,
 		},
 		notifications.Urgent,
 	)
 	if err != nil {
 		return err
 	}
-	return n.eventNotificator.PasswordRecoveryVerificationRequested(userID, userPhone, verificationCode)
+	return n.eventNotificator.PasswordRecoveryVerificationRequested(userID, userPhone, verificationCode, delivery)
 }
 
 func (n *mergedNotificator) PasswordRecoveryCompleted(userID, userPhone string) error {
@@ -72,3 +81,96 @@ func (n *mergedNotificator) PasswordRecoveryCompleted(userID, userPhone string)
 	}
 	return n.eventNotificator.PasswordRecoveryCompleted(userID, userPhone)
 }
+
+func (n *mergedNotificator) SigninVerificationRequested(
+	userID, userPhone, verificationCode string, delivery Delivery,
+) error {
+	err := n.oldNotificator.Send(
+		notifications.ActionSigninConfirmationRequested,
+		map[string]interface{}{
+			"phone":   userPhone,
+			"code":    verificationCode,
+			"channel": delivery.Channel,
+			"email":   delivery.Email,
+		},
+		notifications.Urgent,
+	)
+	if err != nil {
+		return err
+	}
+	return n.eventNotificator.SigninVerificationRequested(userID, userPhone, verificationCode, delivery)
+}
+
+// PasswordChanged old notificator has no such action, so only event is emitted
+func (n *mergedNotificator) PasswordChanged(userID, userPhone string) error {
+	return n.eventNotificator.PasswordChanged(userID, userPhone)
+}
+
+func (n *mergedNotificator) PhoneChangeVerificationRequested(
+	userID, userPhone, verificationCode string, delivery Delivery,
+) error {
+	err := n.oldNotificator.Send(
+		notifications.ActionPhoneChangeConfirmationRequested,
+		map[string]interface{}{
+			"phone":   userPhone,
+			"code":    verificationCode,
+			"channel": delivery.Channel,
+			"email":   delivery.Email,
+		},
+		notifications.Urgent,
+	)
+	if err != nil {
+		return err
+	}
+	return n.eventNotificator.PhoneChangeVerificationRequested(userID, userPhone, verificationCode, delivery)
+}
+
+// PhoneChanged old notificator has no such action, so only event is emitted
+func (n *mergedNotificator) PhoneChanged(userID, oldPhone, newPhone string) error {
+	return n.eventNotificator.PhoneChanged(userID, oldPhone, newPhone)
+}
+
+func (n *mergedNotificator) AccountDeletionVerificationRequested(
+	userID, userPhone, verificationCode string, delivery Delivery,
+) error {
+	err := n.oldNotificator.Send(
+		notifications.ActionAccountDeletionConfirmationRequested,
+		map[string]interface{}{
+			"phone":   userPhone,
+			"code":    verificationCode,
+			"channel": delivery.Channel,
+			"email":   delivery.Email,
+		},
+		notifications.Urgent,
+	)
+	if err != nil {
+		return err
+	}
+	return n.eventNotificator.AccountDeletionVerificationRequested(userID, userPhone, verificationCode, delivery)
+}
+
+// AccountDeleted old notificator has no such action, so only event is emitted
+func (n *mergedNotificator) AccountDeleted(userID, userPhone string) error {
+	return n.eventNotificator.AccountDeleted(userID, userPhone)
+}
+
+// SigninLocked old notificator has no such action, so only event is emitted
+func (n *mergedNotificator) SigninLocked(userID, userPhone, ip string, lockedUntil time.Time) error {
+	return n.eventNotificator.SigninLocked(userID, userPhone, ip, lockedUntil)
+}
+
+func (n *mergedNotificator) NewDeviceSignin(userID, userPhone, device, ip string) error {
+	err := n.oldNotificator.Send(
+		notifications.ActionNewDeviceSignin,
+		map[string]interface{}{
+			"phone":  userPhone,
+			"device": device,
+			"ip":     ip,
+		},
+		notifications.Urgent,
+	)
+	if err != nil {
+		return err
+	}
+	return n.eventNotificator.NewDeviceSignin(userID, userPhone, device, ip)
+}
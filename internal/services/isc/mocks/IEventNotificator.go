@@ -1,13 +1,71 @@
 // Code generated by mockery v1.0.0. DO NOT EDIT.
 package mocks
 
+import isc "git.zam.io/wallet-backend/web-api/internal/services/isc"
 import mock "github.com/stretchr/testify/mock"
+import time "time"
 
 // IEventNotificator is an autogenerated mock type for the IEventNotificator type
 type IEventNotificator struct {
 	mock.Mock
 }
 
+// AccountDeleted provides a mock function with given fields: userID, userPhone
+func (_m *IEventNotificator) AccountDeleted(userID string, userPhone string) error {
+	ret := _m.Called(userID, userPhone)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(string, string) error); ok {
+		r0 = rf(userID, userPhone)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// AccountDeletionVerificationRequested provides a mock function with given fields: userID, userPhone, verificationCode, delivery
+func (_m *IEventNotificator) AccountDeletionVerificationRequested(userID string, userPhone string, verificationCode string, delivery isc.Delivery) error {
+	ret := _m.Called(userID, userPhone, verificationCode, delivery)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(string, string, string, isc.Delivery) error); ok {
+		r0 = rf(userID, userPhone, verificationCode, delivery)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// NewDeviceSignin provides a mock function with given fields: userID, userPhone, device, ip
+func (_m *IEventNotificator) NewDeviceSignin(userID string, userPhone string, device string, ip string) error {
+	ret := _m.Called(userID, userPhone, device, ip)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(string, string, string, string) error); ok {
+		r0 = rf(userID, userPhone, device, ip)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// PasswordChanged provides a mock function with given fields: userID, userPhone
+func (_m *IEventNotificator) PasswordChanged(userID string, userPhone string) error {
+	ret := _m.Called(userID, userPhone)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(string, string) error); ok {
+		r0 = rf(userID, userPhone)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // PasswordRecoveryCompleted provides a mock function with given fields: userID, userPhone
 func (_m *IEventNotificator) PasswordRecoveryCompleted(userID string, userPhone string) error {
 	ret := _m.Called(userID, userPhone)
@@ -22,13 +80,41 @@ func (_m *IEventNotificator) PasswordRecoveryCompleted(userID string, userPhone
 	return r0
 }
 
-// PasswordRecoveryVerificationRequested provides a mock function with given fields: userID, userPhone, verificationCode
-func (_m *IEventNotificator) PasswordRecoveryVerificationRequested(userID string, userPhone string, verificationCode string) error {
-	ret := _m.Called(userID, userPhone, verificationCode)
+// PasswordRecoveryVerificationRequested provides a mock function with given fields: userID, userPhone, verificationCode, delivery
+func (_m *IEventNotificator) PasswordRecoveryVerificationRequested(userID string, userPhone string, verificationCode string, delivery isc.Delivery) error {
+	ret := _m.Called(userID, userPhone, verificationCode, delivery)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(string, string, string, isc.Delivery) error); ok {
+		r0 = rf(userID, userPhone, verificationCode, delivery)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// PhoneChangeVerificationRequested provides a mock function with given fields: userID, userPhone, verificationCode, delivery
+func (_m *IEventNotificator) PhoneChangeVerificationRequested(userID string, userPhone string, verificationCode string, delivery isc.Delivery) error {
+	ret := _m.Called(userID, userPhone, verificationCode, delivery)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(string, string, string, isc.Delivery) error); ok {
+		r0 = rf(userID, userPhone, verificationCode, delivery)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// PhoneChanged provides a mock function with given fields: userID, oldPhone, newPhone
+func (_m *IEventNotificator) PhoneChanged(userID string, oldPhone string, newPhone string) error {
+	ret := _m.Called(userID, oldPhone, newPhone)
 
 	var r0 error
 	if rf, ok := ret.Get(0).(func(string, string, string) error); ok {
-		r0 = rf(userID, userPhone, verificationCode)
+		r0 = rf(userID, oldPhone, newPhone)
 	} else {
 		r0 = ret.Error(0)
 	}
@@ -50,13 +136,41 @@ func (_m *IEventNotificator) RegistrationCompleted(userID string, userPhone stri
 	return r0
 }
 
-// RegistrationVerificationRequested provides a mock function with given fields: userID, userPhone, verificationCode
-func (_m *IEventNotificator) RegistrationVerificationRequested(userID string, userPhone string, verificationCode string) error {
-	ret := _m.Called(userID, userPhone, verificationCode)
+// RegistrationVerificationRequested provides a mock function with given fields: userID, userPhone, verificationCode, delivery
+func (_m *IEventNotificator) RegistrationVerificationRequested(userID string, userPhone string, verificationCode string, delivery isc.Delivery) error {
+	ret := _m.Called(userID, userPhone, verificationCode, delivery)
 
 	var r0 error
-	if rf, ok := ret.Get(0).(func(string, string, string) error); ok {
-		r0 = rf(userID, userPhone, verificationCode)
+	if rf, ok := ret.Get(0).(func(string, string, string, isc.Delivery) error); ok {
+		r0 = rf(userID, userPhone, verificationCode, delivery)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// SigninLocked provides a mock function with given fields: userID, userPhone, ip, lockedUntil
+func (_m *IEventNotificator) SigninLocked(userID string, userPhone string, ip string, lockedUntil time.Time) error {
+	ret := _m.Called(userID, userPhone, ip, lockedUntil)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(string, string, string, time.Time) error); ok {
+		r0 = rf(userID, userPhone, ip, lockedUntil)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// SigninVerificationRequested provides a mock function with given fields: userID, userPhone, verificationCode, delivery
+func (_m *IEventNotificator) SigninVerificationRequested(userID string, userPhone string, verificationCode string, delivery isc.Delivery) error {
+	ret := _m.Called(userID, userPhone, verificationCode, delivery)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(string, string, string, isc.Delivery) error); ok {
+		r0 = rf(userID, userPhone, verificationCode, delivery)
 	} else {
 		r0 = ret.Error(0)
 	}
@@ -1,16 +1,56 @@
 package isc
 
+import (
+	"git.zam.io/wallet-backend/web-api/internal/services/notifications"
+	"time"
+)
+
+// Delivery describes how verification code should be delivered to the user
+type Delivery struct {
+	// Channel requested delivery channel, code is sent by sms if it's empty or isn't available
+	Channel notifications.Channel
+
+	// Email verified user email, email channel isn't available without it
+	Email string
+}
+
 // IEventNotificator used to notify other services about events which occurs in web API related to an user
 type IEventNotificator interface {
 	// RegistrationVerificationRequested emitted when user phone registration is required during registration process
-	RegistrationVerificationRequested(userID, userPhone, verificationCode string) error
+	RegistrationVerificationRequested(userID, userPhone, verificationCode string, delivery Delivery) error
 
 	// RegistrationCompleted emitted when user completes registration process
 	RegistrationCompleted(userID, userPhone string) error
 
 	// RegistrationVerificationRequested emitted when user should verify password recovery
-	PasswordRecoveryVerificationRequested(userID, userPhone, verificationCode string) error
+	PasswordRecoveryVerificationRequested(userID, userPhone, verificationCode string, delivery Delivery) error
 
 	// RegistrationCompleted emitted when user completes password recovery
 	PasswordRecoveryCompleted(userID, userPhone string) error
+
+	// SigninVerificationRequested emitted when user requests signin by the one-time code
+	SigninVerificationRequested(userID, userPhone, verificationCode string, delivery Delivery) error
+
+	// PasswordChanged emitted when authorized user changes his password
+	PasswordChanged(userID, userPhone string) error
+
+	// PhoneChangeVerificationRequested emitted when user requests phone change, it's emitted twice: with the code
+	// which must be sent to the old phone and with the code which must be sent to the new one
+	PhoneChangeVerificationRequested(userID, userPhone, verificationCode string, delivery Delivery) error
+
+	// PhoneChanged emitted when user completes phone change
+	PhoneChanged(userID, oldPhone, newPhone string) error
+
+	// AccountDeletionVerificationRequested emitted when user should verify account deletion
+	AccountDeletionVerificationRequested(userID, userPhone, verificationCode string, delivery Delivery) error
+
+	// AccountDeleted emitted when user account is deleted, user phone is the one user had before deletion
+	AccountDeleted(userID, userPhone string) error
+
+	// SigninLocked emitted when user phone is locked due to too many failed signin attempts, it may be caused by the
+	// password brute-force
+	SigninLocked(userID, userPhone, ip string, lockedUntil time.Time) error
+
+	// NewDeviceSignin emitted when user signs in from the device or ip he hasn't used before
+	NewDeviceSignin(userID, userPhone, device, ip string) error
 }
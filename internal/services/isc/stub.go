@@ -2,6 +2,7 @@ package isc
 
 import (
 	"github.com/sirupsen/logrus"
+	"time"
 )
 
 // stubNotificator logs all events using logger
@@ -14,11 +15,14 @@ func NewStub(logger logrus.FieldLogger) IEventNotificator {
 	return &stubNotificator{logger.WithField("module", "notificator.stub")}
 }
 
-func (n stubNotificator) RegistrationVerificationRequested(userID, userPhone, verificationCode string) error {
+func (n stubNotificator) RegistrationVerificationRequested(
+	userID, userPhone, verificationCode string, delivery Delivery,
+) error {
 	n.logger.WithFields(logrus.Fields{
 		"user_id":           userID,
 		"user_phone":        userPhone,
 		"verification_code": verificationCode,
+		"channel":           delivery.Channel,
 	}).Info("user registration verification required")
 	return nil
 }
@@ -31,11 +35,14 @@ func (n stubNotificator) RegistrationCompleted(userID, userPhone string) error {
 	return nil
 }
 
-func (n stubNotificator) PasswordRecoveryVerificationRequested(userID, userPhone, verificationCode string) error {
+func (n stubNotificator) PasswordRecoveryVerificationRequested(
+	userID, userPhone, verificationCode string, delivery Delivery,
+) error {
 	n.logger.WithFields(logrus.Fields{
 		"user_id":       userID,
 		"user_phone":    userPhone,
 		"recovery_code": verificationCode,
+		"channel":       delivery.Channel,
 	}).Info("user password recovery verification required")
 	return nil
 }
@@ -47,3 +54,84 @@ func (n stubNotificator) PasswordRecoveryCompleted(userID, userPhone string) err
 	}).Info("user password recovery completed")
 	return nil
 }
+
+func (n stubNotificator) SigninVerificationRequested(
+	userID, userPhone, verificationCode string, delivery Delivery,
+) error {
+	n.logger.WithFields(logrus.Fields{
+		"user_id":           userID,
+		"user_phone":        userPhone,
+		"verification_code": verificationCode,
+		"channel":           delivery.Channel,
+	}).Info("user signin verification required")
+	return nil
+}
+
+func (n stubNotificator) PasswordChanged(userID, userPhone string) error {
+	n.logger.WithFields(logrus.Fields{
+		"user_id":    userID,
+		"user_phone": userPhone,
+	}).Info("user password changed")
+	return nil
+}
+
+func (n stubNotificator) PhoneChangeVerificationRequested(
+	userID, userPhone, verificationCode string, delivery Delivery,
+) error {
+	n.logger.WithFields(logrus.Fields{
+		"user_id":           userID,
+		"user_phone":        userPhone,
+		"verification_code": verificationCode,
+		"channel":           delivery.Channel,
+	}).Info("user phone change verification required")
+	return nil
+}
+
+func (n stubNotificator) PhoneChanged(userID, oldPhone, newPhone string) error {
+	n.logger.WithFields(logrus.Fields{
+		"user_id":   userID,
+		"old_phone": oldPhone,
+		"new_phone": newPhone,
+	}).Info("user phone changed")
+	return nil
+}
+
+func (n stubNotificator) AccountDeletionVerificationRequested(
+	userID, userPhone, verificationCode string, delivery Delivery,
+) error {
+	n.logger.WithFields(logrus.Fields{
+		"user_id":           userID,
+		"user_phone":        userPhone,
+		"verification_code": verificationCode,
+		"channel":           delivery.Channel,
+	}).Info("user account deletion verification required")
+	return nil
+}
+
+func (n stubNotificator) AccountDeleted(userID, userPhone string) error {
+	n.logger.WithFields(logrus.Fields{
+		"user_id":    userID,
+		"user_phone": userPhone,
+	}).Info("user account deleted")
+	return nil
+}
+
+func (n stubNotificator) SigninLocked(userID, userPhone, ip string, lockedUntil time.Time) error {
+	n.logger.WithFields(logrus.Fields{
+		"user_id":      userID,
+		"user_phone":   userPhone,
+		"ip":           ip,
+		"locked_until": lockedUntil,
+	}).Info("user signin locked")
+	return nil
+}
+
+func (n stubNotificator) NewDeviceSignin(userID, userPhone, device, ip string) error {
+	n.logger.WithFields(logrus.Fields{
+		"user_id":    userID,
+		"user_phone": userPhone,
+		"device":     device,
+		"ip":         ip,
+	}).Info("user signed in from the new device")
+	return nil
+}
@@ -22,7 +22,8 @@ const (
 
 const (
 	// ActionRegistrationConfirmationRequested requires service to send confirmation
-	// This actions requires "phone" and "code" to be specified in data map
+	// This actions requires "phone" and "code" to be specified in data map. All confirmation actions optionally
+	// accept "channel" and "email", code is sent by sms if requested channel isn't available
 	ActionRegistrationConfirmationRequested = "action_registration_confirmation_requested"
 
 	// ActionRegistrationCompleted notify user about successful registration and request confirmation
@@ -34,6 +35,31 @@ const (
 
 	// ActionPasswordRecoveryCompleted notifies user about successful password change
 	ActionPasswordRecoveryCompleted = "action_recovery_completed"
+
+	// ActionPhoneChangeConfirmationRequested requires service to send phone change confirmation, it's sent to both
+	// old and new phones. This actions requires "phone" and "code" to be specified in data map
+	ActionPhoneChangeConfirmationRequested = "action_phone_change_confirmation_requested"
+
+	// ActionAccountDeletionConfirmationRequested requires service to send account deletion confirmation. This actions
+	// requires "phone" and "code" to be specified in data map
+	ActionAccountDeletionConfirmationRequested = "action_account_deletion_confirmation_requested"
+
+	// ActionSigninConfirmationRequested requires service to send one-time signin code. This actions requires "phone"
+	// and "code" to be specified in data map
+	ActionSigninConfirmationRequested = "action_signin_confirmation_requested"
+
+	// ActionNewDeviceSignin notifies user about signin from the unknown device. This actions requires "phone", "device"
+	// and "ip" to be specified in data map
+	ActionNewDeviceSignin = "action_new_device_signin"
+)
+
+// Channel verification code delivery channel
+type Channel string
+
+const (
+	ChannelSMS   Channel = "sms"
+	ChannelCall  Channel = "call"
+	ChannelEmail Channel = "email"
 )
 
 // ISender intends to perform all notification actions depending on user settings
@@ -7,13 +7,15 @@ import (
 	"git.zam.io/wallet-backend/web-api/pkg/services/notifications"
 	"git.zam.io/wallet-backend/web-api/pkg/services/notifications/file"
 	"git.zam.io/wallet-backend/web-api/pkg/services/notifications/slack"
+	"git.zam.io/wallet-backend/web-api/pkg/services/notifications/smtp"
 	"git.zam.io/wallet-backend/web-api/pkg/services/notifications/twilio"
 	"net/url"
 	"strings"
 )
 
-// New creates backend guessing concrete type from URI, panic if guess is failed
-func New(uri string) (n old_notifications.ISender, err error) {
+// New creates backend guessing concrete type from URI, panic if guess is failed. Call and email URIs are optional,
+// code is sent by sms if channel isn't specified.
+func New(uri, callURI, emailURI string) (n old_notifications.ISender, err error) {
 	parsed, err := url.Parse(uri)
 	if err != nil {
 		return
@@ -32,12 +34,63 @@ func New(uri string) (n old_notifications.ISender, err error) {
 		transport = file.New(parsed.Path)
 	}
 
-	if transport == nil {
+	if transport == nil && err == nil {
 		err = fmt.Errorf("unsupported simple-text transport specified with %s", uri)
 	}
+	if err != nil {
+		return
+	}
+
+	channels := make(map[old_notifications.Channel]notifications.ITransport)
+	if callURI != "" {
+		channels[old_notifications.ChannelCall], err = callTransport(callURI)
+		if err != nil {
+			return
+		}
+	}
+	if emailURI != "" {
+		channels[old_notifications.ChannelEmail], err = emailTransport(emailURI)
+		if err != nil {
+			return
+		}
+	}
 
-	if err == nil {
-		n = stext.New(transport)
+	n = stext.New(transport, channels)
+	return
+}
+
+// callTransport creates voice call transport, only twilio is supported
+func callTransport(uri string) (transport notifications.ITransport, err error) {
+	parsed, err := url.Parse(uri)
+	if err != nil {
+		return
+	}
+
+	switch {
+	case parsed.Scheme == "https" && strings.Contains(parsed.Host, "api.twilio.com"):
+		transport, err = twilio.NewCall(uri)
+	case parsed.Scheme == "file":
+		transport = file.New(parsed.Path)
+	default:
+		err = fmt.Errorf("unsupported call transport specified with %s", uri)
+	}
+	return
+}
+
+// emailTransport creates email transport
+func emailTransport(uri string) (transport notifications.ITransport, err error) {
+	parsed, err := url.Parse(uri)
+	if err != nil {
+		return
+	}
+
+	switch parsed.Scheme {
+	case "smtp":
+		transport, err = smtp.New(uri)
+	case "file":
+		transport = file.New(parsed.Path)
+	default:
+		err = fmt.Errorf("unsupported email transport specified with %s", uri)
 	}
 	return
 }
@@ -6,15 +6,23 @@ import (
 	"git.zam.io/wallet-backend/web-api/pkg/services/notifications"
 	"github.com/chonla/format"
 	"github.com/pkg/errors"
+	"strings"
 )
 
 // sender renders notification in simple-text form and send it to a recipient
 type sender struct {
 	backend notifications.ITransport
+
+	// channels transports of the channels other than sms
+	channels map[old_notifications.Channel]notifications.ITransport
 }
 
-func New(backend notifications.ITransport) old_notifications.ISender {
-	return &sender{backend: backend}
+// New creates sender which uses backend to send sms, channels specifies transports of the other channels, it may be
+// nil
+func New(
+	backend notifications.ITransport, channels map[old_notifications.Channel]notifications.ITransport,
+) old_notifications.ISender {
+	return &sender{backend: backend, channels: channels}
 }
 
 // Send renders and sends notification using backend
@@ -35,20 +43,70 @@ func (s *sender) Send(action string, data interface{}, level old_notifications.I
 		return err
 	}
 
+	// choose delivery channel
+	backend, recipient, values := s.route(data.(map[string]interface{}), phone)
+
 	// render message
-	body := format.Sprintf(template, data.(map[string]interface{}))
+	body := format.Sprintf(template, values)
 
 	// send it using backend
-	return s.backend.Send(phone, body)
+	return backend.Send(recipient, body)
+}
+
+// route chooses transport and recipient by the requested channel, sms is used if channel isn't available
+func (s *sender) route(
+	data map[string]interface{}, phone string,
+) (backend notifications.ITransport, recipient string, values map[string]interface{}) {
+	backend, recipient, values = s.backend, phone, data
+
+	var channel old_notifications.Channel
+	switch c := data["channel"].(type) {
+	case old_notifications.Channel:
+		channel = c
+	case string:
+		channel = old_notifications.Channel(c)
+	}
+
+	transport, ok := s.channels[channel]
+	if !ok || transport == nil {
+		return
+	}
+
+	switch channel {
+	case old_notifications.ChannelCall:
+		code, ok := data["code"].(string)
+		if !ok {
+			return
+		}
+
+		// digits are read out one by one
+		values = make(map[string]interface{}, len(data))
+		for k, v := range data {
+			values[k] = v
+		}
+		values["code"] = strings.Join(strings.Split(code, ""), ", ")
+		backend = transport
+	case old_notifications.ChannelEmail:
+		email, _ := data["email"].(string)
+		if email == "" {
+			return
+		}
+		backend, recipient = transport, email
+	}
+	return
 }
 
 // templates
 var templates = map[string]string{
 	old_notifications.ActionRegistrationConfirmationRequested:     "Your ZamZam verification code - %<code>s",
 	old_notifications.ActionPasswordRecoveryConfirmationRequested: "Your password recovery code - %<code>s",
+	old_notifications.ActionPhoneChangeConfirmationRequested:      "Your phone number change code - %<code>s",
+	old_notifications.ActionAccountDeletionConfirmationRequested:  "Your account deletion code - %<code>s",
+	old_notifications.ActionSigninConfirmationRequested:           "Your ZamZam sign in code - %<code>s",
+	old_notifications.ActionNewDeviceSignin: "New sign in to your ZamZam account from %<device>s (%<ip>s). " +
+		"If it wasn't you, change your password",
 }
 
-//
 func confirmationDataParser(data interface{}) (string, error) {
 	m, ok := data.(map[string]interface{})
 	if !ok {
@@ -63,8 +121,28 @@ func confirmationDataParser(data interface{}) (string, error) {
 	return phone.(string), nil
 }
 
+// newDeviceDataParser validates new device signin data
+func newDeviceDataParser(data interface{}) (string, error) {
+	m, ok := data.(map[string]interface{})
+	if !ok {
+		return "", fmt.Errorf("expecting map[string]interface{} as data, not %T", data)
+	}
+
+	_, deviceOk := m["device"]
+	_, ipOk := m["ip"]
+	phone, phoneOk := m["phone"]
+	if !deviceOk || !ipOk || !phoneOk {
+		return "", errors.New(`expecting "device", "ip" and "phone" to be passed using data argument`)
+	}
+	return phone.(string), nil
+}
+
 // data parsers
 var parsers = map[string]func(data interface{}) (string, error){
 	old_notifications.ActionRegistrationConfirmationRequested:     confirmationDataParser,
 	old_notifications.ActionPasswordRecoveryConfirmationRequested: confirmationDataParser,
+	old_notifications.ActionPhoneChangeConfirmationRequested:      confirmationDataParser,
+	old_notifications.ActionAccountDeletionConfirmationRequested:  confirmationDataParser,
+	old_notifications.ActionSigninConfirmationRequested:           confirmationDataParser,
+	old_notifications.ActionNewDeviceSignin:                       newDeviceDataParser,
 }
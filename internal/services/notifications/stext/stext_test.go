@@ -2,9 +2,11 @@ package stext
 
 import (
 	"git.zam.io/wallet-backend/web-api/internal/services/notifications"
+	transport "git.zam.io/wallet-backend/web-api/pkg/services/notifications"
 	"git.zam.io/wallet-backend/web-api/pkg/services/notifications/mocks"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
+	"github.com/stretchr/testify/mock"
 	"testing"
 )
 
@@ -102,6 +104,199 @@ var _ = Describe("testing simple text notificator", func() {
 			})
 		})
 
+		Context("when sending phone change confirmation code notification", func() {
+			var notificator *sender
+
+			BeforeEach(func() {
+				backend := mocks.ITransport{}
+				notificator = &sender{backend: &backend}
+				backend.On("Send", testRecipient, "Your phone number change code - 556611").Return(nil)
+			})
+
+			It("should do without errors", func() {
+				err := notificator.Send(
+					notifications.ActionPhoneChangeConfirmationRequested,
+					map[string]interface{}{
+						"phone": testRecipient,
+						"code":  "556611",
+					},
+					notifications.Urgent,
+				)
+				Expect(err).NotTo(HaveOccurred())
+			})
+		})
+
+		Context("when sending account deletion confirmation code notification", func() {
+			var notificator *sender
+
+			BeforeEach(func() {
+				backend := mocks.ITransport{}
+				notificator = &sender{backend: &backend}
+				backend.On("Send", testRecipient, "Your account deletion code - 556611").Return(nil)
+			})
+
+			It("should do without errors", func() {
+				err := notificator.Send(
+					notifications.ActionAccountDeletionConfirmationRequested,
+					map[string]interface{}{
+						"phone": testRecipient,
+						"code":  "556611",
+					},
+					notifications.Urgent,
+				)
+				Expect(err).NotTo(HaveOccurred())
+			})
+		})
+
+		Context("when sending signin confirmation code notification", func() {
+			var notificator *sender
+
+			BeforeEach(func() {
+				backend := mocks.ITransport{}
+				notificator = &sender{backend: &backend}
+				backend.On("Send", testRecipient, "Your ZamZam sign in code - 556611").Return(nil)
+			})
+
+			It("should do without errors", func() {
+				err := notificator.Send(
+					notifications.ActionSigninConfirmationRequested,
+					map[string]interface{}{
+						"phone": testRecipient,
+						"code":  "556611",
+					},
+					notifications.Urgent,
+				)
+				Expect(err).NotTo(HaveOccurred())
+			})
+		})
+
+		Context("when sending confirmation code using delivery channel", func() {
+			var (
+				notificator         *sender
+				backend, call, mail *mocks.ITransport
+			)
+
+			BeforeEach(func() {
+				backend, call, mail = &mocks.ITransport{}, &mocks.ITransport{}, &mocks.ITransport{}
+				notificator = &sender{
+					backend: backend,
+					channels: map[notifications.Channel]transport.ITransport{
+						notifications.ChannelCall:  call,
+						notifications.ChannelEmail: mail,
+					},
+				}
+			})
+
+			It("should read out code digit by digit when call requested", func() {
+				call.On("Send", testRecipient, "Your ZamZam sign in code - 5, 5, 6, 6, 1, 1").Return(nil)
+
+				err := notificator.Send(
+					notifications.ActionSigninConfirmationRequested,
+					map[string]interface{}{
+						"phone":   testRecipient,
+						"code":    "556611",
+						"channel": notifications.ChannelCall,
+					},
+					notifications.Urgent,
+				)
+				Expect(err).NotTo(HaveOccurred())
+				call.AssertExpectations(GinkgoT())
+				backend.AssertNotCalled(GinkgoT(), "Send", mock.Anything, mock.Anything)
+			})
+
+			It("should send code to the email when email requested", func() {
+				mail.On("Send", "user@example.com", "Your ZamZam sign in code - 556611").Return(nil)
+
+				err := notificator.Send(
+					notifications.ActionSigninConfirmationRequested,
+					map[string]interface{}{
+						"phone":   testRecipient,
+						"code":    "556611",
+						"channel": notifications.ChannelEmail,
+						"email":   "user@example.com",
+					},
+					notifications.Urgent,
+				)
+				Expect(err).NotTo(HaveOccurred())
+				mail.AssertExpectations(GinkgoT())
+			})
+
+			It("should fall back to sms when user has no email", func() {
+				backend.On("Send", testRecipient, "Your ZamZam sign in code - 556611").Return(nil)
+
+				err := notificator.Send(
+					notifications.ActionSigninConfirmationRequested,
+					map[string]interface{}{
+						"phone":   testRecipient,
+						"code":    "556611",
+						"channel": notifications.ChannelEmail,
+						"email":   "",
+					},
+					notifications.Urgent,
+				)
+				Expect(err).NotTo(HaveOccurred())
+				backend.AssertExpectations(GinkgoT())
+				mail.AssertNotCalled(GinkgoT(), "Send", mock.Anything, mock.Anything)
+			})
+
+			It("should fall back to sms when channel transport isn't configured", func() {
+				notificator.channels = nil
+				backend.On("Send", testRecipient, "Your ZamZam sign in code - 556611").Return(nil)
+
+				err := notificator.Send(
+					notifications.ActionSigninConfirmationRequested,
+					map[string]interface{}{
+						"phone":   testRecipient,
+						"code":    "556611",
+						"channel": notifications.ChannelCall,
+					},
+					notifications.Urgent,
+				)
+				Expect(err).NotTo(HaveOccurred())
+				backend.AssertExpectations(GinkgoT())
+			})
+		})
+
+		Context("when sending new device signin notification", func() {
+			var notificator *sender
+
+			BeforeEach(func() {
+				backend := mocks.ITransport{}
+				notificator = &sender{backend: &backend}
+				backend.On(
+					"Send",
+					testRecipient,
+					"New sign in to your ZamZam account from iPhone (10.0.0.1). If it wasn't you, change your password",
+				).Return(nil)
+			})
+
+			It("should do without errors", func() {
+				err := notificator.Send(
+					notifications.ActionNewDeviceSignin,
+					map[string]interface{}{
+						"phone":  testRecipient,
+						"device": "iPhone",
+						"ip":     "10.0.0.1",
+					},
+					notifications.Urgent,
+				)
+				Expect(err).NotTo(HaveOccurred())
+			})
+
+			It("should return error when ip is missing", func() {
+				err := notificator.Send(
+					notifications.ActionNewDeviceSignin,
+					map[string]interface{}{
+						"phone":  testRecipient,
+						"device": "iPhone",
+					},
+					notifications.Urgent,
+				)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring(`expecting "device", "ip" and "phone" to be passed`))
+			})
+		})
+
 		Context("when sending recovery confirmation code notification", func() {
 			var notificator *sender
 
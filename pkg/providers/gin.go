@@ -2,6 +2,8 @@ package providers
 
 import (
 	"git.zam.io/wallet-backend/common/pkg/types"
+	serverconf "git.zam.io/wallet-backend/web-api/config/server"
+	"git.zam.io/wallet-backend/web-api/pkg/server/middlewares"
 	"git.zam.io/wallet-backend/web-api/pkg/services/sentry"
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
@@ -9,7 +11,14 @@ import (
 )
 
 // GinEngine
-func GinEngine(env types.Environment, logger logrus.FieldLogger, reporter sentry.IReporter) *gin.Engine {
+func GinEngine(
+	env types.Environment, conf serverconf.Scheme, logger logrus.FieldLogger, reporter sentry.IReporter,
+) (*gin.Engine, error) {
+	trustedProxies, err := middlewares.ParseTrustedProxies(conf.TrustedProxies)
+	if err != nil {
+		return nil, err
+	}
+
 	corsCfg := cors.DefaultConfig()
 	corsCfg.AllowMethods = append(corsCfg.AllowMethods, "DELETE", "PATCH")
 	corsCfg.AllowAllOrigins = true
@@ -31,8 +40,9 @@ func GinEngine(env types.Environment, logger logrus.FieldLogger, reporter sentry
 		gin.Recovery(),
 		gin.Logger(),
 		cors.New(corsCfg),
+		middlewares.ClientIPMiddlewareFactory(trustedProxies),
 	)
-	return engine
+	return engine, nil
 }
 
 // RootRouter
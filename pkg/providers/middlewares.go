@@ -4,10 +4,26 @@ import (
 	"git.zam.io/wallet-backend/web-api/config/server"
 	"git.zam.io/wallet-backend/web-api/pkg/server/middlewares"
 	"git.zam.io/wallet-backend/web-api/pkg/services/sessions"
+	"git.zam.io/wallet-backend/web-api/pkg/services/sessions/activity"
 	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
 )
 
 // Auth middleware
-func AuthMiddleware(sessStorage sessions.IStorage, conf server.Scheme) gin.HandlerFunc {
-	return middlewares.AuthMiddlewareFactory(sessStorage, conf.Auth.TokenName)
+func AuthMiddleware(
+	sessStorage sessions.IStorage,
+	tracker activity.ITracker,
+	apiKeys middlewares.IAPIKeyStorage,
+	conf server.Scheme,
+) gin.HandlerFunc {
+	return middlewares.AuthMiddlewareFactory(sessStorage, tracker, apiKeys, conf.Auth.TokenName)
+}
+
+// ServiceAuthMiddleware authorizes services by tokens from configuration
+func ServiceAuthMiddleware(conf server.Scheme, logger logrus.FieldLogger) gin.HandlerFunc {
+	tokens := make([]middlewares.ServiceToken, 0, len(conf.Internal.ServiceTokens))
+	for _, t := range conf.Internal.ServiceTokens {
+		tokens = append(tokens, middlewares.ServiceToken{Name: t.Name, Token: t.Token, Scopes: t.Scopes})
+	}
+	return middlewares.ServiceAuthMiddlewareFactory(tokens, conf.Auth.TokenName, logger.WithField("module", "internal_api"))
 }
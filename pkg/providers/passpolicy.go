@@ -0,0 +1,23 @@
+package providers
+
+import (
+	serverconf "git.zam.io/wallet-backend/web-api/config/server"
+	"git.zam.io/wallet-backend/web-api/pkg/services/passpolicy"
+)
+
+// PasswordPolicy creates password policy, breached passwords list is loaded into memory once
+func PasswordPolicy(conf serverconf.Scheme) (*passpolicy.Policy, error) {
+	params := passpolicy.Params{
+		MinLength:   conf.Auth.PasswordPolicy.MinLength,
+		MinClasses:  conf.Auth.PasswordPolicy.MinClasses,
+		ForbidPhone: conf.Auth.PasswordPolicy.ForbidPhone,
+	}
+	if conf.Auth.PasswordPolicy.BreachedList != "" {
+		breached, err := passpolicy.LoadBreachedSet(conf.Auth.PasswordPolicy.BreachedList)
+		if err != nil {
+			return nil, err
+		}
+		params.Breached = breached
+	}
+	return passpolicy.New(params), nil
+}
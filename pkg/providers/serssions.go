@@ -7,13 +7,19 @@ import (
 	"git.zam.io/wallet-backend/web-api/internal/services/notifications"
 	"git.zam.io/wallet-backend/web-api/pkg/services/nosql"
 	"git.zam.io/wallet-backend/web-api/pkg/services/sessions"
+	"git.zam.io/wallet-backend/web-api/pkg/services/sessions/activity"
 	"git.zam.io/wallet-backend/web-api/pkg/services/sessions/jwt"
 	"git.zam.io/wallet-backend/web-api/pkg/services/sessions/mem"
+	sessredis "git.zam.io/wallet-backend/web-api/pkg/services/sessions/redis"
+	"git.zam.io/wallet-backend/web-api/pkg/services/sessions/refresh"
+	"strings"
 	"time"
 )
 
 // SessionsStorage
-func SessionsStorage(conf serverconf.Scheme, persistentStorage nosql.IStorage) (res sessions.IStorage, err error) {
+func SessionsStorage(
+	conf serverconf.Scheme, persistentStorage nosql.IStorage, keys *jwt.KeySet,
+) (res sessions.IStorage, err error) {
 	// catch jwt storage panics
 	defer func() {
 		r := recover()
@@ -28,26 +34,87 @@ func SessionsStorage(conf serverconf.Scheme, persistentStorage nosql.IStorage) (
 
 	switch conf.Auth.TokenStorage {
 	case "mem", "":
-		return mem.New(), nil
+		return mem.New(userSessionsKey), nil
 	case "jwt", "jwtpersistent":
-		if conf.JWT == nil {
+		if keys == nil {
 			return nil, errors.New("jwt like token storage required, but jwt configuration not provided")
 		}
-		res = jwt.New(conf.JWT.Method, []byte(conf.JWT.Secret), func() time.Time { return time.Now().UTC() })
+		res = jwt.NewWithKeys(keys, func() time.Time { return time.Now().UTC() })
 
 		if conf.Auth.TokenStorage == "jwtpersistent" {
-			res = jwt.WithStorage(
-				res, persistentStorage, func(data map[string]interface{}, token string) string {
-					return fmt.Sprintf("user:%v:sessions", data["phone"])
-				},
-			)
+			res = jwt.WithStorage(res, persistentStorage, userSessionsKey)
+		} else {
+			// plain jwt tokens are validated without lookup, sessions are only tracked, so they may be listed and
+			// revoked one by one
+			res = jwt.WithTracking(res, persistentStorage, userSessionsKey, conf.Auth.RefreshTokenExpire)
 		}
+
+		// tokens can't outlive refresh token, so the revocation too
+		res = jwt.WithRevocation(res, persistentStorage, userSessionsKey, conf.Auth.RefreshTokenExpire)
 		return
+	case "redis":
+		// opaque tokens must be visible for all instances, so in-memory nosql storage makes no sense here
+		if !strings.HasPrefix(conf.Storage.URI, "redis") {
+			return nil, errors.New("redis token storage required, but nosql storage is not redis")
+		}
+		return sessredis.New(persistentStorage, userSessionsKey), nil
 	default:
 		return nil, fmt.Errorf("unsupported token storage type: %s", conf.Auth.TokenStorage)
 	}
 }
 
+// RefreshStorage
+func RefreshStorage(
+	conf serverconf.Scheme, sessStorage sessions.IStorage, persistentStorage nosql.IStorage,
+) refresh.IStorage {
+	return refresh.New(sessStorage, persistentStorage, conf.Auth.TokenExpire, conf.Auth.RefreshTokenExpire)
+}
+
+// ActivityTracker
+func ActivityTracker(conf serverconf.Scheme, persistentStorage nosql.IStorage) activity.ITracker {
+	// session can't live longer then refresh token
+	return activity.New(
+		persistentStorage, conf.Auth.LastSeenThrottle, conf.Auth.RefreshTokenExpire,
+		func() time.Time { return time.Now().UTC() },
+	)
+}
+
+// JWTKeys loads jwt signing and verification keys, returns nil set if jwt isn't configured
+func JWTKeys(conf serverconf.Scheme) (*jwt.KeySet, error) {
+	if conf.JWT == nil {
+		return nil, nil
+	}
+
+	var (
+		signing jwt.Key
+		err     error
+	)
+	if conf.JWT.PrivateKey != "" {
+		signing, err = jwt.LoadPrivateKey(conf.JWT.KeyID, conf.JWT.Method, conf.JWT.PrivateKey)
+	} else {
+		signing, err = jwt.NewSecretKey(conf.JWT.KeyID, conf.JWT.Method, []byte(conf.JWT.Secret))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	verification := make([]jwt.Key, 0, len(conf.JWT.VerificationKeys))
+	for _, keyConf := range conf.JWT.VerificationKeys {
+		key, err := jwt.LoadPublicKey(keyConf.ID, keyConf.Method, keyConf.PublicKey)
+		if err != nil {
+			return nil, err
+		}
+		verification = append(verification, key)
+	}
+
+	return jwt.NewKeySet(signing, verification...)
+}
+
+// userSessionsKey groups user sessions by the user phone
+func userSessionsKey(data map[string]interface{}) string {
+	return fmt.Sprintf("user:%v:sessions", data["phone"])
+}
+
 // Generator
 func Generator(conf serverconf.Scheme) notifications.IGenerator {
 	return notifications.NewWithCodeAlphabet(conf.Generator.CodeLen, conf.Generator.CodeAlphabet)
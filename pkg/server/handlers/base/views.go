@@ -41,6 +41,14 @@ func NewFieldErr(input, name, message string) FieldErrorView {
 	}
 }
 
+// NewFieldErrs creates field error for each message, all errors relate to the same field
+func NewFieldErrs(input, name string, messages ...string) (err error) {
+	for _, message := range messages {
+		err = merrors.Append(err, NewFieldErr(input, name, message))
+	}
+	return
+}
+
 // HaveFieldErr checks is given error is list of errs, in such case scans whole list to search FieldErrorView with
 // given field name.
 func HaveFieldErr(err error, fieldName string) bool {
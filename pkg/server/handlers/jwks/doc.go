@@ -0,0 +1,2 @@
+// Package jwks publishes public keys used to sign jwt tokens, so other services may validate them
+package jwks
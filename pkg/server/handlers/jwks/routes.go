@@ -0,0 +1,29 @@
+package jwks
+
+import (
+	"git.zam.io/wallet-backend/web-api/pkg/services/sessions/jwt"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/dig"
+	"net/http"
+)
+
+// Dependencies
+type Dependencies struct {
+	dig.In
+
+	Routes gin.IRouter `name:"root"`
+	Keys   *jwt.KeySet
+}
+
+// Register adds JSON Web Key Set endpoint, response isn't wrapped into the base response since it must follow
+// RFC 7517 format
+func Register(deps Dependencies) {
+	set := jwt.JWKSet{Keys: []jwt.JWK{}}
+	if deps.Keys != nil {
+		set = deps.Keys.JWKS()
+	}
+
+	deps.Routes.GET("/.well-known/jwks.json", func(c *gin.Context) {
+		c.JSON(http.StatusOK, set)
+	})
+}
@@ -3,18 +3,47 @@ package middlewares
 import (
 	"fmt"
 	"git.zam.io/wallet-backend/web-api/pkg/services/sessions"
+	"git.zam.io/wallet-backend/web-api/pkg/services/sessions/activity"
 	"github.com/gin-gonic/gin"
 	"github.com/pkg/errors"
 	"net/http"
 	"strings"
 )
 
-// AuthMiddlewareFactory creates auth middleware using session validation via given storage
+// APIKeyScheme authorization scheme of the personal api keys
+const APIKeyScheme = "ApiKey"
+
+// APIKeyScopesKey user data key which holds scopes of the api key request is authorized by, it's absent when request
+// is authorized by the session token
+const APIKeyScopesKey = "api_key_scopes"
+
+// apiKeyScopeKey context key which holds scope required from the api key by the endpoint
+const apiKeyScopeKey = "api_key_scope"
+
+// ErrAPIKeyInvalid returned by the api keys storage when key is unknown or revoked
+var ErrAPIKeyInvalid = errors.New("api key is invalid")
+
+// IAPIKeyStorage resolves personal api keys into the same user data session storage holds for the session token
+type IAPIKeyStorage interface {
+	// Get returns user data of the key, APIKeyScopesKey must be set to the key scopes
+	Get(key string) (data map[string]interface{}, err error)
+}
+
+// AuthMiddlewareFactory creates auth middleware using session validation via given storage, accepted sessions are
+// marked as used by the tracker if it's given. Personal api keys are accepted using ApiKey scheme if api keys storage
+// is given, see APIKeyScope.
 func AuthMiddlewareFactory(
 	sessStorage sessions.IStorage,
+	tracker activity.ITracker,
+	apiKeys IAPIKeyStorage,
 	tokenName string,
 ) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		if apiKey, ok := getAPIKeyFromContext(c); ok && apiKeys != nil {
+			authorizeAPIKey(c, apiKeys, apiKey)
+			return
+		}
+
 		authToken, err := GetAuthTokenFromContext(c, tokenName)
 		if err != nil {
 			abortUnauthorized(c, err.Error())
@@ -24,8 +53,9 @@ func AuthMiddlewareFactory(
 		// ensure storage have this token
 		data, err := sessStorage.Get(sessions.Token(authToken))
 		if err != nil {
-			switch err {
-			case sessions.ErrNotFound, sessions.ErrUnexpectedToken, sessions.ErrExpired:
+			// backends wrap token format errors
+			switch errors.Cause(err) {
+			case sessions.ErrNotFound, sessions.ErrUnexpectedToken, sessions.ErrExpired, sessions.ErrRevoked:
 				abortUnauthorized(c, err.Error())
 			default:
 				abortMiddlware(c, http.StatusInternalServerError, "token validation failed")
@@ -33,6 +63,19 @@ func AuthMiddlewareFactory(
 			return
 		}
 
+		// refresh tokens may be only exchanged for the new pair
+		if data[sessions.TokenTypeKey] == sessions.TokenTypeRefresh {
+			abortUnauthorized(c, "refresh token can't be used for authorization")
+			return
+		}
+
+		// activity tracking shouldn't break authorization, so error is only attached to the request log
+		if sessionID, ok := data[sessions.SessionIDKey].(string); ok && tracker != nil {
+			if err := tracker.Touch(sessionID); err != nil {
+				c.Error(err)
+			}
+		}
+
 		// attach user data to context
 		c.Set("user_data", data)
 
@@ -41,6 +84,56 @@ func AuthMiddlewareFactory(
 	}
 }
 
+// APIKeyScope creates middleware which makes endpoint available for api keys having given scope, endpoints without
+// scope reject api keys. It must be placed before the auth middleware. Api keys are read-only, so only GET and HEAD
+// requests are allowed anyway.
+func APIKeyScope(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set(apiKeyScopeKey, scope)
+		c.Next()
+	}
+}
+
+// authorizeAPIKey authorizes request by the api key, key must have scope required by the endpoint
+func authorizeAPIKey(c *gin.Context, apiKeys IAPIKeyStorage, apiKey string) {
+	data, err := apiKeys.Get(apiKey)
+	if err != nil {
+		if err == ErrAPIKeyInvalid {
+			abortUnauthorized(c, err.Error())
+		} else {
+			abortMiddlware(c, http.StatusInternalServerError, "api key validation failed")
+		}
+		return
+	}
+
+	if c.Request.Method != http.MethodGet && c.Request.Method != http.MethodHead {
+		abortMiddlware(c, http.StatusForbidden, "api keys are read-only")
+		return
+	}
+	scope := c.GetString(apiKeyScopeKey)
+	if scope == "" {
+		abortMiddlware(c, http.StatusForbidden, "endpoint isn't available for api keys")
+		return
+	}
+	scopes, _ := data[APIKeyScopesKey].([]string)
+	if !hasScope(scopes, scope) {
+		abortMiddlware(c, http.StatusForbidden, "scope "+scope+" required")
+		return
+	}
+
+	c.Set("user_data", data)
+	c.Next()
+}
+
+// getAPIKeyFromContext gets api key from request headers if ApiKey scheme is used
+func getAPIKeyFromContext(c *gin.Context) (string, bool) {
+	parts := strings.Split(c.GetHeader("Authorization"), " ")
+	if len(parts) != 2 || parts[0] != APIKeyScheme {
+		return "", false
+	}
+	return parts[1], true
+}
+
 // GetAuthTokenFromContext gets auth token from request headers or return error.
 // Temporary placed here.
 func GetAuthTokenFromContext(c *gin.Context, tokenName string) (string, error) {
@@ -61,7 +154,6 @@ func GetUserDataFromContext(c *gin.Context) map[string]interface{} {
 	return c.GetStringMap("user_data")
 }
 
-//
 func abortUnauthorized(c *gin.Context, message string) {
 	abortMiddlware(c, http.StatusUnauthorized, message)
 }
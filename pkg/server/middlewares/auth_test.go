@@ -0,0 +1,50 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"git.zam.io/wallet-backend/web-api/pkg/services/sessions/jwt"
+	"github.com/gin-gonic/gin"
+	. "github.com/onsi/ginkgo"
+	"github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("testing auth middleware", func() {
+	secret := []byte("secret")
+	storage := jwt.New("HS256", secret, time.Now)
+
+	authorize := func(token string) int {
+		recorder := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(recorder)
+		c.Request = httptest.NewRequest("GET", "/", nil)
+		c.Request.Header.Set("Authorization", "Bearer "+token)
+		AuthMiddlewareFactory(storage, nil, nil, "Bearer")(c)
+		return recorder.Code
+	}
+
+	issue := func(nowFunc func() time.Time) string {
+		data := map[string]interface{}{"phone": "+79871111111"}
+		token, err := jwt.New("HS256", secret, nowFunc).New(data, time.Minute)
+		Expect(err).NotTo(HaveOccurred())
+		return string(token)
+	}
+
+	It("should accept valid token", func() {
+		Expect(authorize(issue(time.Now))).To(Equal(http.StatusOK))
+	})
+
+	table.DescribeTable(
+		"should reject invalid token as unauthorized",
+		func(token func() string) {
+			Expect(authorize(token())).To(Equal(http.StatusUnauthorized))
+		},
+		table.Entry("expired token", func() string {
+			return issue(func() time.Time { return time.Now().Add(-time.Hour) })
+		}),
+		table.Entry("malformed token", func() string { return "garbage" }),
+		table.Entry("token with malformed segments", func() string { return "a.b.c" }),
+	)
+})
@@ -0,0 +1,122 @@
+package middlewares
+
+import (
+	"fmt"
+	"git.zam.io/wallet-backend/web-api/pkg/services/sessions"
+	"github.com/gin-gonic/gin"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ClientIPKey context key which holds client address resolved by the client ip middleware
+const ClientIPKey = "client_ip"
+
+// SessionMetadata returns session data which describes the client performing request, device name is optional and
+// given by the client itself
+func SessionMetadata(c *gin.Context, device string) map[string]interface{} {
+	return map[string]interface{}{
+		sessions.IPKey:        ClientIP(c),
+		sessions.UserAgentKey: c.Request.UserAgent(),
+		sessions.DeviceKey:    device,
+		sessions.CreatedAtKey: time.Now().UTC().Unix(),
+	}
+}
+
+// ClientIP returns client address resolved by the client ip middleware, remote address is used if middleware isn't
+// installed
+func ClientIP(c *gin.Context) string {
+	if ip := c.GetString(ClientIPKey); ip != "" {
+		return ip
+	}
+	return remoteIP(c.Request)
+}
+
+// ClientIPMiddlewareFactory creates middleware which resolves client address. Proxy headers are respected only when
+// request comes from one of the trusted proxies, in such case the rightmost X-Forwarded-For address which isn't
+// trusted proxy is taken, since the leftmost values are passed by the client as is.
+func ClientIPMiddlewareFactory(trustedProxies []*net.IPNet) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set(ClientIPKey, resolveClientIP(c.Request, trustedProxies))
+		c.Next()
+	}
+}
+
+// ParseTrustedProxies parses proxies given either as ip addresses or as CIDR networks
+func ParseTrustedProxies(proxies []string) ([]*net.IPNet, error) {
+	networks := make([]*net.IPNet, 0, len(proxies))
+	for _, proxy := range proxies {
+		if !strings.Contains(proxy, "/") {
+			ip := net.ParseIP(proxy)
+			if ip == nil {
+				return nil, fmt.Errorf("trusted proxy %q is neither ip address nor network", proxy)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			networks = append(networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+
+		_, network, err := net.ParseCIDR(proxy)
+		if err != nil {
+			return nil, fmt.Errorf("trusted proxy %q is neither ip address nor network", proxy)
+		}
+		networks = append(networks, network)
+	}
+	return networks, nil
+}
+
+// resolveClientIP walks proxies chain from the right while hops are trusted
+func resolveClientIP(r *http.Request, trustedProxies []*net.IPNet) string {
+	remote := remoteIP(r)
+	if !isTrustedProxy(remote, trustedProxies) {
+		return remote
+	}
+
+	forwarded := r.Header.Get("X-Forwarded-For")
+	if forwarded == "" {
+		if realIP := strings.TrimSpace(r.Header.Get("X-Real-Ip")); net.ParseIP(realIP) != nil {
+			return realIP
+		}
+		return remote
+	}
+
+	hops := strings.Split(forwarded, ",")
+	client := remote
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		if net.ParseIP(hop) == nil {
+			// malformed chain, the last valid hop is the best known client address
+			break
+		}
+		client = hop
+		if !isTrustedProxy(hop, trustedProxies) {
+			break
+		}
+	}
+	return client
+}
+
+func isTrustedProxy(ip string, trustedProxies []*net.IPNet) bool {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false
+	}
+	for _, network := range trustedProxies {
+		if network.Contains(parsed) {
+			return true
+		}
+	}
+	return false
+}
+
+func remoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(strings.TrimSpace(r.RemoteAddr))
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
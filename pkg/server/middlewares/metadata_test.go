@@ -0,0 +1,62 @@
+package middlewares
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	. "github.com/onsi/ginkgo"
+	"github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+func TestMiddlewares(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Middlewares Suite")
+}
+
+var _ = Describe("testing client ip resolving", func() {
+	trustedProxies, err := ParseTrustedProxies([]string{"10.0.0.0/8", "192.168.1.1"})
+	if err != nil {
+		panic(err)
+	}
+
+	resolve := func(remoteAddr, forwarded string) string {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest("GET", "/", nil)
+		c.Request.RemoteAddr = remoteAddr
+		if forwarded != "" {
+			c.Request.Header.Set("X-Forwarded-For", forwarded)
+		}
+		ClientIPMiddlewareFactory(trustedProxies)(c)
+		return ClientIP(c)
+	}
+
+	table.DescribeTable(
+		"should resolve client ip",
+		func(remoteAddr, forwarded, expected string) {
+			Expect(resolve(remoteAddr, forwarded)).To(Equal(expected))
+		},
+		table.Entry("direct request", "1.1.1.1:1234", "", "1.1.1.1"),
+		table.Entry("untrusted proxy headers", "1.1.1.1:1234", "2.2.2.2", "1.1.1.1"),
+		table.Entry("trusted proxy", "10.0.0.1:1234", "2.2.2.2", "2.2.2.2"),
+		table.Entry("trusted proxies chain", "10.0.0.1:1234", "2.2.2.2, 192.168.1.1", "2.2.2.2"),
+		table.Entry("spoofed leftmost hop", "10.0.0.1:1234", "6.6.6.6, 2.2.2.2", "2.2.2.2"),
+		table.Entry("malformed hop", "10.0.0.1:1234", "2.2.2.2, garbage", "10.0.0.1"),
+	)
+
+	It("should fall back to remote address without middleware", func() {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest("GET", "/", nil)
+		c.Request.RemoteAddr = "1.1.1.1:1234"
+		c.Request.Header.Set("X-Forwarded-For", "2.2.2.2")
+		Expect(ClientIP(c)).To(Equal("1.1.1.1"))
+	})
+
+	It("should reject malformed proxies", func() {
+		_, err := ParseTrustedProxies([]string{"10.0.0.0/33"})
+		Expect(err).To(HaveOccurred())
+		_, err = ParseTrustedProxies([]string{"localhost"})
+		Expect(err).To(HaveOccurred())
+	})
+})
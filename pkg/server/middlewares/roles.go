@@ -0,0 +1,45 @@
+package middlewares
+
+import (
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+// RolesKey session data key which holds user role names
+const RolesKey = "roles"
+
+// RequireRole creates middleware which allows request only if user has at least one of given roles, it must be placed
+// after the auth middleware
+func RequireRole(roles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userRoles := GetUserRolesFromContext(c)
+		for _, required := range roles {
+			for _, role := range userRoles {
+				if role == required {
+					c.Next()
+					return
+				}
+			}
+		}
+		abortMiddlware(c, http.StatusForbidden, "access denied")
+	}
+}
+
+// GetUserRolesFromContext gets user roles from the session data, roles may be decoded from JSON by the sessions
+// storage, so both string and generic slices are accepted
+func GetUserRolesFromContext(c *gin.Context) []string {
+	switch roles := GetUserDataFromContext(c)[RolesKey].(type) {
+	case []string:
+		return roles
+	case []interface{}:
+		res := make([]string, 0, len(roles))
+		for _, role := range roles {
+			if name, ok := role.(string); ok {
+				res = append(res, name)
+			}
+		}
+		return res
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,117 @@
+package middlewares
+
+import (
+	"crypto/subtle"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"net/http"
+	"time"
+)
+
+// ServiceKey context key which holds identity of the service authorized by the service auth middleware
+const ServiceKey = "service"
+
+// ServiceToken static token which identifies service calling internal api
+type ServiceToken struct {
+	// Name of the service, it's used to identify caller in logs
+	Name string
+
+	// Token secret value passed by the service in the Authorization header
+	Token string
+
+	// Scopes allowed to the service
+	Scopes []string
+}
+
+// ServiceIdentity describes authorized service, it doesn't hold the token
+type ServiceIdentity struct {
+	Name   string
+	Scopes []string
+}
+
+// HasScope
+func (s ServiceIdentity) HasScope(scope string) bool {
+	return hasScope(s.Scopes, scope)
+}
+
+// ServiceAuthMiddlewareFactory creates middleware which authorizes services by one of given static tokens, every call
+// is logged along with the caller identity
+func ServiceAuthMiddlewareFactory(tokens []ServiceToken, tokenName string, logger logrus.FieldLogger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		authToken, err := GetAuthTokenFromContext(c, tokenName)
+		if err != nil {
+			abortUnauthorized(c, err.Error())
+			return
+		}
+
+		identity, ok := matchServiceToken(tokens, authToken)
+		if !ok {
+			logger.WithFields(logrus.Fields{
+				"ip":   ClientIP(c),
+				"path": c.Request.URL.Path,
+			}).Warn("internal api call with unknown service token")
+			abortUnauthorized(c, "service token is invalid")
+			return
+		}
+		c.Set(ServiceKey, identity)
+
+		start := time.Now()
+		c.Next()
+
+		logger.WithFields(logrus.Fields{
+			"service":  identity.Name,
+			"ip":       ClientIP(c),
+			"method":   c.Request.Method,
+			"path":     c.Request.URL.Path,
+			"status":   c.Writer.Status(),
+			"duration": time.Since(start),
+		}).Info("internal api call")
+	}
+}
+
+// RequireScope creates middleware which allows request only if authorized service has given scope, it must be placed
+// after the service auth middleware
+func RequireScope(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		identity, ok := GetServiceFromContext(c)
+		if !ok || !identity.HasScope(scope) {
+			abortMiddlware(c, http.StatusForbidden, "scope "+scope+" required")
+			return
+		}
+		c.Next()
+	}
+}
+
+// GetServiceFromContext gets service identity attached by the service auth middleware
+func GetServiceFromContext(c *gin.Context) (identity ServiceIdentity, ok bool) {
+	value, exists := c.Get(ServiceKey)
+	if !exists {
+		return
+	}
+	identity, ok = value.(ServiceIdentity)
+	return
+}
+
+func hasScope(scopes []string, scope string) bool {
+	for _, allowed := range scopes {
+		if allowed == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// matchServiceToken compares given token with every configured one in constant time, so neither token value nor
+// it's position leaks through timing
+func matchServiceToken(tokens []ServiceToken, token string) (identity ServiceIdentity, ok bool) {
+	for _, t := range tokens {
+		if t.Token == "" {
+			continue
+		}
+		if subtle.ConstantTimeCompare([]byte(t.Token), []byte(token)) == 1 && !ok {
+			identity = ServiceIdentity{Name: t.Name, Scopes: t.Scopes}
+			ok = true
+		}
+	}
+	return
+}
@@ -2,7 +2,7 @@ package mem
 
 import (
 	"git.zam.io/wallet-backend/web-api/pkg/services/nosql"
-	"git.zam.io/wallet-backend/web-api/pkg/services/sessions"
+	"reflect"
 	"sync"
 	"time"
 )
@@ -78,27 +78,55 @@ func (s *memStorage) SetWithExpire(key string, data interface{}, ttl time.Durati
 	return nil
 }
 
-func (s *memStorage) Delete(key string) (err error) {
-	s.guard.RLock()
-	defer s.guard.RUnlock()
+func (s *memStorage) CompareAndSwap(key string, old, new interface{}, ttl time.Duration) (bool, error) {
+	s.guard.Lock()
+	defer s.guard.Unlock()
 
-	val, ok := s.values[key]
-	if !ok {
-		err = sessions.ErrNotFound
-		return
-	}
-	if val.expireAt.Before(time.Now()) {
-		err = sessions.ErrExpired
-		return
+	val, present := s.values[key]
+	present = present && (val.expireAt.IsZero() || val.expireAt.After(time.Now()))
+	switch {
+	case old == nil && present, old != nil && !present:
+		return false, nil
+	case old != nil && !reflect.DeepEqual(val.val, old):
+		return false, nil
 	}
-	s.values[key] = valWithExpire{
-		val:       val.val,
-		expireAt:  time.Now().Add(val.expireAt.Sub(val.createdAt)),
+
+	newVal := valWithExpire{
+		val:       new,
 		createdAt: time.Now(),
 	}
+	if ttl > 0 {
+		newVal.expireAt = time.Now().Add(ttl)
+	}
+	s.values[key] = newVal
+	return true, nil
+}
+
+func (s *memStorage) Delete(key string) (err error) {
+	s.guard.Lock()
+	defer s.guard.Unlock()
+
+	val, ok := s.values[key]
+	if !ok || (!val.expireAt.IsZero() && !val.expireAt.After(time.Now())) {
+		err = nosql.ErrNoSuchKeyFound
+	}
+	delete(s.values, key)
 	return
 }
 
+func (s *memStorage) Rename(key, newKey string) error {
+	s.guard.Lock()
+	defer s.guard.Unlock()
+
+	val, ok := s.values[key]
+	if !ok || (!val.expireAt.IsZero() && !val.expireAt.After(time.Now())) {
+		return nosql.ErrNoSuchKeyFound
+	}
+	delete(s.values, key)
+	s.values[newKey] = val
+	return nil
+}
+
 func (s *memStorage) StrSet(key string) nosql.IStrSet {
 	var set *memSet
 	setRaw, err := s.Get(key)
@@ -149,11 +177,7 @@ func (set *memSet) Check(val string) (bool, error) {
 		return false, nil
 	}
 
-	if elem.Before(time.Now().UTC()) {
-		return false, nil
-	}
-
-	return true, nil
+	return !isExpired(elem), nil
 }
 
 func (set *memSet) List() ([]string, error) {
@@ -161,12 +185,20 @@ func (set *memSet) List() ([]string, error) {
 	defer set.guard.RUnlock()
 
 	elements := make([]string, 0, len(set.set))
-	for e := range set.set {
+	for e, expireAt := range set.set {
+		if isExpired(expireAt) {
+			continue
+		}
 		elements = append(elements, e)
 	}
 	return elements, nil
 }
 
+// isExpired checks set element expiration, zero time means that element never expires
+func isExpired(expireAt time.Time) bool {
+	return !expireAt.IsZero() && expireAt.Before(time.Now().UTC())
+}
+
 func (notASet) Add(val string) error {
 	return nosql.ErrNotStrSet
 }
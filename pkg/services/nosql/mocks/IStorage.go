@@ -10,6 +10,27 @@ type IStorage struct {
 	mock.Mock
 }
 
+// CompareAndSwap provides a mock function with given fields: key, old, new, ttl
+func (_m *IStorage) CompareAndSwap(key string, old interface{}, new interface{}, ttl time.Duration) (bool, error) {
+	ret := _m.Called(key, old, new, ttl)
+
+	var r0 bool
+	if rf, ok := ret.Get(0).(func(string, interface{}, interface{}, time.Duration) bool); ok {
+		r0 = rf(key, old, new, ttl)
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(string, interface{}, interface{}, time.Duration) error); ok {
+		r1 = rf(key, old, new, ttl)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // Delete provides a mock function with given fields: key
 func (_m *IStorage) Delete(key string) error {
 	ret := _m.Called(key)
@@ -47,6 +68,20 @@ func (_m *IStorage) Get(key string) (interface{}, error) {
 	return r0, r1
 }
 
+// Rename provides a mock function with given fields: key, newKey
+func (_m *IStorage) Rename(key string, newKey string) error {
+	ret := _m.Called(key, newKey)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(string, string) error); ok {
+		r0 = rf(key, newKey)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // Set provides a mock function with given fields: key, data
 func (_m *IStorage) Set(key string, data interface{}) error {
 	ret := _m.Called(key, data)
@@ -30,7 +30,8 @@ type clientSetWrapper struct {
 	setKey string
 }
 
-// Get gets redis key using GET cmd, trying to unmarshal json into interface{}
+// Get gets redis key using GET cmd, trying to unmarshal json into interface{}. Json objects are returned as
+// map[string]interface{} (see nosql.NormalizeValue)
 func (c clientWrapper) Get(key string) (data interface{}, err error) {
 	cmd := c.client.Get(key)
 	if cmd.Err() != nil {
@@ -50,7 +51,10 @@ func (c clientWrapper) Get(key string) (data interface{}, err error) {
 	}
 
 	err = json.Unmarshal(bytes, &data)
-	return
+	if err != nil {
+		return
+	}
+	return nosql.NormalizeValue(data), nil
 }
 
 // Set sets redis key value marshaling it's value using json
@@ -68,6 +72,47 @@ func (c clientWrapper) SetWithExpire(key string, data interface{}, ttl time.Dura
 	return res.Err()
 }
 
+// casScript replaces value only if it's equal to ARGV[1], empty ARGV[1] means that key must be absent. ARGV[3] is
+// the ttl in milliseconds, non-positive means no expiration
+var casScript = redis.NewScript(`
+local current = redis.call('GET', KEYS[1])
+if ARGV[1] == '' then
+	if current then
+		return 0
+	end
+elseif current ~= ARGV[1] then
+	return 0
+end
+if tonumber(ARGV[3]) > 0 then
+	redis.call('SET', KEYS[1], ARGV[2], 'PX', ARGV[3])
+else
+	redis.call('SET', KEYS[1], ARGV[2])
+end
+return 1
+`)
+
+// CompareAndSwap compares and sets json-marshaled values within lua script, so it's performed atomically
+func (c clientWrapper) CompareAndSwap(key string, old, new interface{}, ttl time.Duration) (bool, error) {
+	var oldBytes []byte
+	if old != nil {
+		var err error
+		oldBytes, err = json.Marshal(old)
+		if err != nil {
+			return false, err
+		}
+	}
+	newBytes, err := json.Marshal(new)
+	if err != nil {
+		return false, err
+	}
+
+	res, err := casScript.Run(c.client, []string{key}, oldBytes, newBytes, int64(ttl/time.Millisecond)).Int64()
+	if err != nil {
+		return false, err
+	}
+	return res == 1, nil
+}
+
 // Delete deletes key from redis
 func (c clientWrapper) Delete(key string) error {
 	res := c.client.Del(key)
@@ -80,6 +125,40 @@ func (c clientWrapper) Delete(key string) error {
 	return nil
 }
 
+// Rename moves key using DUMP and RESTORE instead of RENAME cmd, since in cluster mode keys may belong to the
+// different slots
+func (c clientWrapper) Rename(key, newKey string) error {
+	dump, err := c.client.Dump(key).Result()
+	if err != nil {
+		return coerceRedisErr(err)
+	}
+
+	ttl, err := c.client.PTTL(key).Result()
+	if err != nil {
+		return err
+	}
+	switch {
+	case ttl == -2*time.Millisecond:
+		// key expired in the meantime
+		return nosql.ErrNoSuchKeyFound
+	case ttl < 0:
+		// key has no expiration
+		ttl = 0
+	}
+
+	err = c.client.RestoreReplace(newKey, ttl, dump).Err()
+	if err != nil {
+		return err
+	}
+
+	err = c.Delete(key)
+	if err == nosql.ErrNoSuchKeyFound {
+		// value is already moved, so it's not an error
+		err = nil
+	}
+	return err
+}
+
 // SrtSet
 func (c clientWrapper) StrSet(key string) nosql.IStrSet {
 	return clientSetWrapper{clientWrapper: c, setKey: key}
@@ -109,10 +188,32 @@ func (c clientSetWrapper) AddExpire(val string, ttl time.Duration) error {
 		score = float64(time.Now().Add(ttl).UTC().Unix())
 	}
 
-	return coerceRedisErr(c.client.ZAdd(c.setKey, redis.Z{
+	cmd = c.client.ZAdd(c.setKey, redis.Z{
 		Score:  score,
 		Member: val,
-	}).Err())
+	})
+	if cmd.Err() != nil {
+		return coerceRedisErr(cmd.Err())
+	}
+	return c.expireWithLastElem()
+}
+
+// expireWithLastElem sets set key expiration to the expiration of the longest living element, so set which is
+// abandoned doesn't stay in redis forever
+func (c clientSetWrapper) expireWithLastElem() error {
+	last, err := c.client.ZRevRangeWithScores(c.setKey, 0, 0).Result()
+	if err != nil {
+		return coerceRedisErr(err)
+	}
+	if len(last) == 0 {
+		return nil
+	}
+	if math.IsInf(last[0].Score, 1) {
+		return coerceRedisErr(c.client.Persist(c.setKey).Err())
+	}
+	// element is alive during the second of it's expiration timestamp
+	expireAt := time.Unix(int64(last[0].Score)+1, 0)
+	return coerceRedisErr(c.client.ExpireAt(c.setKey, expireAt).Err())
 }
 
 func (c clientSetWrapper) Remove(val string) error {
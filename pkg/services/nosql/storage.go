@@ -25,9 +25,18 @@ type IStorage interface {
 	// SetWithExpire associates given key with given data for specified time
 	SetWithExpire(key string, data interface{}, ttl time.Duration) error
 
+	// CompareAndSwap atomically associates given key with the new data if the current value is equal to the old one,
+	// nil old means that key must be absent. Values are compared in serialized form, so only plain values such as
+	// strings are reliable. Returns false if value has been changed in the meantime
+	CompareAndSwap(key string, old, new interface{}, ttl time.Duration) (swapped bool, err error)
+
 	// Delete delete value associated with given key from storage, should return ErrNoSuchKey if nothing deleted
 	Delete(key string) error
 
+	// Rename moves value associated with given key under the new key keeping it's expiration, value previously
+	// associated with the new key is replaced. Should return ErrNoSuchKeyFound if nothing moved
+	Rename(key, newKey string) error
+
 	// StrSet returns strings set associated with given key.
 	//
 	// This method doesn't checks either key presence nor that value associated with key is set
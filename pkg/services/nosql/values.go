@@ -0,0 +1,51 @@
+package nosql
+
+import "fmt"
+
+// NormalizeValue converts decoded maps with non-string keys (e.g. map[interface{}]interface{} produced by objconv)
+// into map[string]interface{} recursively, so values read from any backend have the same shape
+func NormalizeValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for key, val := range v {
+			m[fmt.Sprint(key)] = NormalizeValue(val)
+		}
+		return m
+	case map[string]interface{}:
+		for key, val := range v {
+			v[key] = NormalizeValue(val)
+		}
+		return v
+	case []interface{}:
+		for i, val := range v {
+			v[i] = NormalizeValue(val)
+		}
+		return v
+	}
+	return v
+}
+
+// Int64 converts decoded number into int64, depending on the backend serialization number may be decoded as any
+// numeric type
+func Int64(raw interface{}) (int64, error) {
+	switch v := raw.(type) {
+	case int64:
+		return v, nil
+	case int:
+		return int64(v), nil
+	case int32:
+		return int64(v), nil
+	case uint64:
+		return int64(v), nil
+	case uint:
+		return int64(v), nil
+	case uint32:
+		return int64(v), nil
+	case float64:
+		return int64(v), nil
+	case float32:
+		return int64(v), nil
+	}
+	return 0, fmt.Errorf("unexpected number type %T", raw)
+}
@@ -0,0 +1,71 @@
+package smtp
+
+import (
+	"bytes"
+	"fmt"
+	"git.zam.io/wallet-backend/web-api/pkg/services/notifications"
+	"mime"
+	"net"
+	"net/smtp"
+	"net/url"
+)
+
+const (
+	defaultSubject = "ZamZam"
+	defaultPort    = "25"
+)
+
+// transport sends notifications by email
+type transport struct {
+	addr, from, subject string
+	auth                smtp.Auth
+}
+
+// New creates smtp transport form uri in format:
+// 'smtp://{user}:{password}@{host}:{port}/?from={sender_email}&subject={subject}', where credentials, port and subject
+// are optional. Server must support STARTTLS if credentials are given.
+func New(uri string) (t notifications.ITransport, err error) {
+	parsed, err := url.Parse(uri)
+	if err != nil {
+		return
+	}
+
+	from := parsed.Query().Get("from")
+	if parsed.Scheme != "smtp" || parsed.Host == "" || from == "" {
+		err = fmt.Errorf("smtp: uri must match pattern: smtp://{user}:{password}@{host}:{port}/?from={sender_email}")
+		return
+	}
+
+	subject := parsed.Query().Get("subject")
+	if subject == "" {
+		subject = defaultSubject
+	}
+
+	host, port := parsed.Hostname(), parsed.Port()
+	if port == "" {
+		port = defaultPort
+	}
+
+	var auth smtp.Auth
+	if user := parsed.User.Username(); user != "" {
+		password, _ := parsed.User.Password()
+		auth = smtp.PlainAuth("", user, password, host)
+	}
+
+	t = &transport{addr: net.JoinHostPort(host, port), from: from, subject: subject, auth: auth}
+	return
+}
+
+// Send sends plain text email to the recipient
+func (t *transport) Send(recipient string, body string) error {
+	buf := bytes.Buffer{}
+	fmt.Fprintf(&buf, "From: %s\r\n", t.from)
+	fmt.Fprintf(&buf, "To: %s\r\n", recipient)
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", t.subject))
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	buf.WriteString("\r\n")
+	buf.WriteString(body)
+	buf.WriteString("\r\n")
+	return smtp.SendMail(t.addr, t.auth, t.from, []string{recipient}, buf.Bytes())
+}
@@ -1,7 +1,9 @@
 package twilio
 
 import (
+	"bytes"
 	"encoding/json"
+	"encoding/xml"
 	"fmt"
 	"regexp"
 
@@ -69,6 +71,22 @@ var ignoreQueryKeysSet = map[string]struct{}{
 // (both numeric and alphanumeric) from which messages will be sent, 'fallback_send_from_phone' - optional which will
 // be used in case when recipient live in country where alphanumeric phone numbers are restricted
 func New(uri string) (t notifications.ITransport, err error) {
+	return newBackend(uri, "/Messages.json")
+}
+
+// NewCall creates twilio transport which delivers messages by the voice call, message is read out using TwiML. Uri
+// format is the same as for New, but 'send_from_phone' must be numeric since calls can't be placed from alphanumeric
+// phones, so fallback phone isn't used
+func NewCall(uri string) (t notifications.ITransport, err error) {
+	b, err := newBackend(uri, "/Calls.json")
+	if err != nil {
+		return
+	}
+	t = &callBackend{b}
+	return
+}
+
+func newBackend(uri, resource string) (b *backend, err error) {
 	parsed, err := url.Parse(uri)
 	if err != nil {
 		return
@@ -102,8 +120,8 @@ func New(uri string) (t notifications.ITransport, err error) {
 		return
 	}
 
-	t = &backend{
-		url:         tUrl.String() + "/2010-04-01/Accounts/" + sid + "/Messages.json",
+	b = &backend{
+		url:         tUrl.String() + "/2010-04-01/Accounts/" + sid + resource,
 		sid:         sid,
 		token:       token,
 		fromPhone:   fromPhone,
@@ -127,17 +145,11 @@ func (e *respErr) Error() string {
 
 // Send
 func (b *backend) Send(recipient, body string) error {
-	p, err := libphonenumber.Parse(recipient, "")
+	fromPhone, err := b.from(recipient)
 	if err != nil {
 		return err
 	}
 
-	fromPhone := b.fromPhone
-	region := libphonenumber.GetRegionCodeForNumber(p)
-	if regionFrom, ok := b.regionFrom[region]; ok {
-		fromPhone = regionFrom
-	}
-
 	err = b.send(fromPhone, recipient, body)
 	if err == nil {
 		// reverse err condition because it will simplify fallback flow
@@ -158,12 +170,32 @@ func (b *backend) Send(recipient, body string) error {
 	return err
 }
 
+// from chooses phone messages are sent from, region-specific phone is preferred
+func (b *backend) from(recipient string) (string, error) {
+	p, err := libphonenumber.Parse(recipient, "")
+	if err != nil {
+		return "", err
+	}
+
+	fromPhone := b.fromPhone
+	region := libphonenumber.GetRegionCodeForNumber(p)
+	if regionFrom, ok := b.regionFrom[region]; ok {
+		fromPhone = regionFrom
+	}
+	return fromPhone, nil
+}
+
 func (b *backend) send(from, recipient, body string) error {
 	// Build out the data for our message
 	v := url.Values{}
 	v.Set("To", recipient)
 	v.Set("From", from)
 	v.Set("Body", body)
+	return b.post(v)
+}
+
+// post performs twilio api request, twilio error is returned as *respErr
+func (b *backend) post(v url.Values) error {
 	rb := *strings.NewReader(v.Encode())
 
 	req, _ := http.NewRequest("POST", b.url, &rb)
@@ -195,3 +227,31 @@ func (b *backend) send(from, recipient, body string) error {
 	}
 	return e
 }
+
+// callBackend delivers messages by the voice call
+type callBackend struct {
+	*backend
+}
+
+// Send calls recipient and reads out the message
+func (b *callBackend) Send(recipient, body string) error {
+	fromPhone, err := b.from(recipient)
+	if err != nil {
+		return err
+	}
+
+	v := url.Values{}
+	v.Set("To", recipient)
+	v.Set("From", fromPhone)
+	v.Set("Twiml", sayTwiML(body))
+	return b.post(v)
+}
+
+// sayTwiML creates TwiML which reads out given text twice, so recipient may catch up
+func sayTwiML(text string) string {
+	buf := bytes.Buffer{}
+	buf.WriteString(`<Response><Say loop="2">`)
+	xml.EscapeText(&buf, []byte(text))
+	buf.WriteString(`</Say></Response>`)
+	return buf.String()
+}
@@ -0,0 +1,61 @@
+package passpolicy
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// BreachedSet set of breached passwords SHA1 hashes
+type BreachedSet map[[sha1.Size]byte]struct{}
+
+// Contains checks whether password hash is in the set
+func (s BreachedSet) Contains(password string) bool {
+	if len(s) == 0 {
+		return false
+	}
+	_, ok := s[sha1.Sum([]byte(password))]
+	return ok
+}
+
+// LoadBreachedSet reads breached passwords hashes from the file, see ReadBreachedSet for the format
+func LoadBreachedSet(path string) (BreachedSet, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ReadBreachedSet(f)
+}
+
+// ReadBreachedSet reads hex-encoded SHA1 hashes one per line, optional ":count" suffix is ignored, so files in the
+// "Have I Been Pwned" format may be used as-is. Empty lines and lines starting with "#" are skipped.
+func ReadBreachedSet(r io.Reader) (BreachedSet, error) {
+	set := BreachedSet{}
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		if i := strings.IndexByte(text, ':'); i >= 0 {
+			text = text[:i]
+		}
+
+		raw, err := hex.DecodeString(text)
+		if err != nil || len(raw) != sha1.Size {
+			return nil, fmt.Errorf("passpolicy: line %d: invalid SHA1 hash", line)
+		}
+		var hash [sha1.Size]byte
+		copy(hash[:], raw)
+		set[hash] = struct{}{}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return set, nil
+}
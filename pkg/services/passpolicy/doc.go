@@ -0,0 +1,3 @@
+// Package passpolicy checks new user passwords against configurable rules: minimal length, character classes, phone
+// digits and local list of breached passwords SHA1 hashes
+package passpolicy
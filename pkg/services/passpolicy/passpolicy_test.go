@@ -0,0 +1,69 @@
+package passpolicy
+
+import (
+	"strings"
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	"github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+func TestPasswordPolicy(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Password Policy Suite")
+}
+
+const (
+	phone = "+79871234567"
+
+	// sha1("123456") and sha1("password") in the "Have I Been Pwned" format
+	breachedList = `
+# top breached passwords
+7C4A8D09CA3762AF61E59520943DC26494F8941B:37359195
+5baa61e4c9b93f3f0682250b6cf8331b7ee68fd8
+`
+)
+
+var _ = Describe("testing password policy", func() {
+	breached, err := ReadBreachedSet(strings.NewReader(breachedList))
+	if err != nil {
+		panic(err)
+	}
+
+	policy := New(Params{MinLength: 8, MinClasses: 3, ForbidPhone: true, Breached: breached})
+
+	table.DescribeTable(
+		"should check password",
+		func(password string, expected Violations) {
+			Expect(policy.Check(password, phone)).To(Equal(expected))
+		},
+		table.Entry("strong", "Corr3ct-horse", nil),
+		table.Entry("too short", "Ab1-", Violations{"password must be at least 8 characters long"}),
+		table.Entry(
+			"not enough classes", "correcthorse!",
+			Violations{"password must contain at least 3 of lowercase letters, uppercase letters, digits and symbols"},
+		),
+		table.Entry("with phone", "Horse1234567", Violations{"password mustn't contain phone number"}),
+		table.Entry("with phone with country code", "Horse+79871234567", Violations{"password mustn't contain phone number"}),
+		table.Entry("breached", "123456", Violations{
+			"password must be at least 8 characters long",
+			"password must contain at least 3 of lowercase letters, uppercase letters, digits and symbols",
+			"password is found in breached passwords list",
+		}),
+	)
+
+	It("should accept any password with zero params", func() {
+		Expect(New(Params{}).Check("1", phone)).To(BeNil())
+	})
+
+	It("should read both uppercase and lowercase hashes", func() {
+		Expect(breached.Contains("password")).To(BeTrue())
+		Expect(breached.Contains("Password")).To(BeFalse())
+	})
+
+	It("should fail to read malformed list", func() {
+		_, err := ReadBreachedSet(strings.NewReader("7C4A8D09CA37\n"))
+		Expect(err).To(HaveOccurred())
+	})
+})
@@ -0,0 +1,106 @@
+package passpolicy
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// phoneTailLen length of the phone number tail which password mustn't contain, tail is shared by the phone written
+// with and without country code
+const phoneTailLen = 7
+
+// Params policy parameters, zero values disable corresponding rules
+type Params struct {
+	// MinLength minimal password length in characters
+	MinLength int
+
+	// MinClasses minimal number of distinct character classes (lowercase, uppercase, digits and other symbols)
+	MinClasses int
+
+	// ForbidPhone forbids passwords which contain the user phone digits
+	ForbidPhone bool
+
+	// Breached set of breached passwords hashes, nil disables the check
+	Breached BreachedSet
+}
+
+// Violations lists rules violated by the password, messages are suitable to be shown to the user
+type Violations []string
+
+// Error implements error interface
+func (v Violations) Error() string {
+	return strings.Join(v, ", ")
+}
+
+// Policy checks passwords against rules specified by params
+type Policy struct {
+	params Params
+}
+
+// New creates policy
+func New(params Params) *Policy {
+	return &Policy{params: params}
+}
+
+// Check returns violations of the password which belongs to the user with given phone, nil if password satisfies
+// the policy
+func (p *Policy) Check(password, phone string) (violations Violations) {
+	if p.params.MinLength > 0 && len([]rune(password)) < p.params.MinLength {
+		violations = append(violations, fmt.Sprintf("password must be at least %d characters long", p.params.MinLength))
+	}
+	if p.params.MinClasses > 0 && charClasses(password) < p.params.MinClasses {
+		violations = append(violations, fmt.Sprintf(
+			"password must contain at least %d of lowercase letters, uppercase letters, digits and symbols",
+			p.params.MinClasses,
+		))
+	}
+	if p.params.ForbidPhone && containsPhone(password, phone) {
+		violations = append(violations, "password mustn't contain phone number")
+	}
+	if p.params.Breached.Contains(password) {
+		violations = append(violations, "password is found in breached passwords list")
+	}
+	return
+}
+
+// utils
+func charClasses(password string) int {
+	var lower, upper, digit, other bool
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsDigit(r):
+			digit = true
+		default:
+			other = true
+		}
+	}
+
+	classes := 0
+	for _, present := range []bool{lower, upper, digit, other} {
+		if present {
+			classes++
+		}
+	}
+	return classes
+}
+
+func containsPhone(password, phone string) bool {
+	digits := strings.Map(func(r rune) rune {
+		if r >= '0' && r <= '9' {
+			return r
+		}
+		return -1
+	}, phone)
+	if digits == "" {
+		return false
+	}
+	if len(digits) > phoneTailLen {
+		digits = digits[len(digits)-phoneTailLen:]
+	}
+	return strings.Contains(password, digits)
+}
@@ -0,0 +1,2 @@
+// Package activity tracks the last time user sessions have been used
+package activity
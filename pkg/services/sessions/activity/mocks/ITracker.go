@@ -0,0 +1,45 @@
+// Code generated by mockery v1.0.0
+package mocks
+
+import mock "github.com/stretchr/testify/mock"
+import time "time"
+
+// ITracker is an autogenerated mock type for the ITracker type
+type ITracker struct {
+	mock.Mock
+}
+
+// LastSeen provides a mock function with given fields: sessionID
+func (_m *ITracker) LastSeen(sessionID string) (time.Time, error) {
+	ret := _m.Called(sessionID)
+
+	var r0 time.Time
+	if rf, ok := ret.Get(0).(func(string) time.Time); ok {
+		r0 = rf(sessionID)
+	} else {
+		r0 = ret.Get(0).(time.Time)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(string) error); ok {
+		r1 = rf(sessionID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Touch provides a mock function with given fields: sessionID
+func (_m *ITracker) Touch(sessionID string) error {
+	ret := _m.Called(sessionID)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(string) error); ok {
+		r0 = rf(sessionID)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
@@ -0,0 +1,98 @@
+package activity
+
+import (
+	"fmt"
+	"git.zam.io/wallet-backend/web-api/pkg/services/nosql"
+	"sync"
+	"time"
+)
+
+const (
+	lastSeenKeyPattern = "session:%s:last_seen"
+
+	// sweepThreshold number of throttled sessions after which outdated records are dropped
+	sweepThreshold = 1024
+)
+
+// ITracker tracks sessions activity
+type ITracker interface {
+	// Touch marks session as used now
+	Touch(sessionID string) error
+
+	// LastSeen returns the last time session has been used, zero time returned if there is no such record
+	LastSeen(sessionID string) (time.Time, error)
+}
+
+// tracker keeps last-seen time in the nosql storage. To prevent write on each request, session activity is written at
+// most once per throttle interval, throttling state is kept per process.
+type tracker struct {
+	storage  nosql.IStorage
+	throttle time.Duration
+	ttl      time.Duration
+	nowFunc  func() time.Time
+
+	mu         sync.Mutex
+	lastWrites map[string]time.Time
+}
+
+// New creates tracker which writes session activity at most once per throttle interval, records expire after ttl
+func New(storage nosql.IStorage, throttle, ttl time.Duration, nowFunc func() time.Time) ITracker {
+	return &tracker{
+		storage:    storage,
+		throttle:   throttle,
+		ttl:        ttl,
+		nowFunc:    nowFunc,
+		lastWrites: make(map[string]time.Time),
+	}
+}
+
+// Touch implements ITracker interface
+func (t *tracker) Touch(sessionID string) error {
+	now := t.nowFunc()
+	if !t.shouldWrite(sessionID, now) {
+		return nil
+	}
+	return t.storage.SetWithExpire(lastSeenKey(sessionID), now.Unix(), t.ttl)
+}
+
+// LastSeen implements ITracker interface
+func (t *tracker) LastSeen(sessionID string) (time.Time, error) {
+	raw, err := t.storage.Get(lastSeenKey(sessionID))
+	if err == nosql.ErrNoSuchKeyFound {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	ts, err := nosql.Int64(raw)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Unix(ts, 0).UTC(), nil
+}
+
+func (t *tracker) shouldWrite(sessionID string, now time.Time) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if last, ok := t.lastWrites[sessionID]; ok && now.Sub(last) < t.throttle {
+		return false
+	}
+	t.lastWrites[sessionID] = now
+
+	// drop records which don't throttle anything anymore
+	if len(t.lastWrites) > sweepThreshold {
+		for id, last := range t.lastWrites {
+			if now.Sub(last) >= t.throttle {
+				delete(t.lastWrites, id)
+			}
+		}
+	}
+	return true
+}
+
+// utils
+func lastSeenKey(sessionID string) string {
+	return fmt.Sprintf(lastSeenKeyPattern, sessionID)
+}
@@ -0,0 +1,81 @@
+package jwt
+
+import (
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"encoding/base64"
+	"math/big"
+	"sort"
+)
+
+// JWK represents public key in the JSON Web Key form (RFC 7517)
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid,omitempty"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+
+	// RSA public key params
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+
+	// ECDSA public key params
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+// JWKSet represents JSON Web Key Set document
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS returns public keys of the set, HMAC keys are secrets and never published
+func (set *KeySet) JWKS() JWKSet {
+	res := JWKSet{Keys: make([]JWK, 0, len(set.keys))}
+	for _, key := range set.keys {
+		var jwk JWK
+		switch public := key.VerifyKey.(type) {
+		case *rsa.PublicKey:
+			jwk = JWK{
+				Kty: "RSA",
+				N:   encodeBytes(public.N.Bytes()),
+				E:   encodeBytes(big.NewInt(int64(public.E)).Bytes()),
+			}
+		case *ecdsa.PublicKey:
+			size := (public.Curve.Params().BitSize + 7) / 8
+			jwk = JWK{
+				Kty: "EC",
+				Crv: public.Curve.Params().Name,
+				X:   encodeBytes(padBytes(public.X.Bytes(), size)),
+				Y:   encodeBytes(padBytes(public.Y.Bytes(), size)),
+			}
+		default:
+			continue
+		}
+		jwk.Kid = key.ID
+		jwk.Use = "sig"
+		jwk.Alg = key.Method.Alg()
+		res.Keys = append(res.Keys, jwk)
+	}
+
+	// keep output stable
+	sort.Slice(res.Keys, func(i, j int) bool {
+		return res.Keys[i].Kid < res.Keys[j].Kid
+	})
+	return res
+}
+
+// utils
+func encodeBytes(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func padBytes(b []byte, size int) []byte {
+	if len(b) >= size {
+		return b
+	}
+	padded := make([]byte, size)
+	copy(padded[size-len(b):], b)
+	return padded
+}
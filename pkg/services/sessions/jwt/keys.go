@@ -0,0 +1,117 @@
+package jwt
+
+import (
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"fmt"
+	"github.com/dgrijalva/jwt-go"
+	"io/ioutil"
+)
+
+// Key describes single key which is used either to sign and validate tokens or only to validate them
+type Key struct {
+	// ID key identifier which is placed into the "kid" token header, may be empty for the single-key setups
+	ID string
+
+	// Method signing method
+	Method jwt.SigningMethod
+
+	// SignKey used to sign new tokens, nil for keys which only validates previously issued tokens
+	SignKey interface{}
+
+	// VerifyKey used to validate tokens
+	VerifyKey interface{}
+}
+
+// KeySet holds signing key and keys which are only accepted to validate tokens (e.g. previous keys during rotation)
+type KeySet struct {
+	signing *Key
+	keys    map[string]*Key
+}
+
+// NewKeySet creates key set from the signing key and keys which are still accepted for tokens validation
+func NewKeySet(signing Key, verification ...Key) (*KeySet, error) {
+	if signing.SignKey == nil {
+		return nil, fmt.Errorf("jwt key %q can't be used for signing", signing.ID)
+	}
+
+	set := &KeySet{
+		signing: &signing,
+		keys:    map[string]*Key{signing.ID: &signing},
+	}
+	for i := range verification {
+		key := verification[i]
+		if _, ok := set.keys[key.ID]; ok {
+			return nil, fmt.Errorf("jwt key %q specified twice", key.ID)
+		}
+		set.keys[key.ID] = &key
+	}
+	return set, nil
+}
+
+// NewSecretKey creates HMAC key from the shared secret
+func NewSecretKey(id, method string, secret []byte) (Key, error) {
+	signingMethod, ok := jwt.GetSigningMethod(method).(*jwt.SigningMethodHMAC)
+	if !ok {
+		return Key{}, fmt.Errorf("jwt key %q: %s is not a HMAC signing method", id, method)
+	}
+	return Key{ID: id, Method: signingMethod, SignKey: secret, VerifyKey: secret}, nil
+}
+
+// LoadPrivateKey loads RSA or ECDSA signing key from the PEM-encoded file, method must match the key type
+func LoadPrivateKey(id, method, path string) (key Key, err error) {
+	pemBytes, err := ioutil.ReadFile(path)
+	if err != nil {
+		return
+	}
+
+	key = Key{ID: id, Method: jwt.GetSigningMethod(method)}
+	switch key.Method.(type) {
+	case *jwt.SigningMethodRSA, *jwt.SigningMethodRSAPSS:
+		var private *rsa.PrivateKey
+		private, err = jwt.ParseRSAPrivateKeyFromPEM(pemBytes)
+		if err == nil {
+			key.SignKey, key.VerifyKey = private, &private.PublicKey
+		}
+	case *jwt.SigningMethodECDSA:
+		var private *ecdsa.PrivateKey
+		private, err = jwt.ParseECPrivateKeyFromPEM(pemBytes)
+		if err == nil {
+			key.SignKey, key.VerifyKey = private, &private.PublicKey
+		}
+	default:
+		err = fmt.Errorf("jwt key %q: %s is not a public-key signing method", id, method)
+	}
+	if err != nil {
+		err = fmt.Errorf("jwt key %q: failed to load private key from %s: %s", id, path, err)
+	}
+	return
+}
+
+// LoadPublicKey loads RSA or ECDSA key from the PEM-encoded file, such key is used only to validate tokens
+func LoadPublicKey(id, method, path string) (key Key, err error) {
+	pemBytes, err := ioutil.ReadFile(path)
+	if err != nil {
+		return
+	}
+
+	key = Key{ID: id, Method: jwt.GetSigningMethod(method)}
+	switch key.Method.(type) {
+	case *jwt.SigningMethodRSA, *jwt.SigningMethodRSAPSS:
+		key.VerifyKey, err = jwt.ParseRSAPublicKeyFromPEM(pemBytes)
+	case *jwt.SigningMethodECDSA:
+		key.VerifyKey, err = jwt.ParseECPublicKeyFromPEM(pemBytes)
+	default:
+		err = fmt.Errorf("jwt key %q: %s is not a public-key signing method", id, method)
+	}
+	if err != nil {
+		err = fmt.Errorf("jwt key %q: failed to load public key from %s: %s", id, path, err)
+	}
+	return
+}
+
+// lookup finds key by the token "kid" header, tokens without header are validated using key with empty id
+func (set *KeySet) lookup(kid string) (*Key, bool) {
+	key, ok := set.keys[kid]
+	return key, ok
+}
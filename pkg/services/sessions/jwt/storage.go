@@ -13,41 +13,60 @@ const (
 	tokenPersisIDKey = "persistKey"
 	expireAtKey      = "exp"
 	issuedAtKey      = "iat"
+	keyIDHeader      = "kid"
+
+	sessionDataKeyPattern    = "session:%s:data"
+	sessionRevokedKeyPattern = "session:%s:revoked"
+	revokedAtKeyPattern      = "%s:revoked_at"
 )
 
 // jwtStorage implements storage interface using jwt-token mechanism where all optional data stored on the user side.
-// If persistent storage are passed, it also allow to track deleted sessions.
+// If persistent storage are passed, it also allow to track deleted sessions. Tracking only storage accepts any valid
+// token except explicitly revoked ones. If revocation storage are passed, all user tokens issued before revocation
+// moment are rejected.
 type jwtStorage struct {
 	nowFunc func() time.Time
 
-	signingMethod jwt.SigningMethod
-	secretKey     []byte
+	keys *KeySet
 
-	storageKeyFunc    func(data map[string]interface{}, token string) string
+	storageKeyFunc    sessions.UserKeyFunc
 	persistentStorage nosql.IStorage
+	trackOnly         bool
+	trackTTL          time.Duration
+
+	revocationStorage nosql.IStorage
+	revocationTTL     time.Duration
 }
 
-// New creates new jwt storage without ability to delete token, panic on wrong signing method
+// New creates new jwt storage signed by the HMAC secret without ability to delete token, panic on wrong signing
+// method, use NewWithKeys for the public-key signing methods
 func New(signingMethod string, secret []byte, nowFunc func() time.Time) sessions.IStorage {
-	switch signingMethod {
-	case "RS256", "RS512", "RS384":
-		panic(fmt.Errorf(
-			`public-key signing alghoritms not supported ("RS256", "RS512", "RS384"): given value is %s`, signingMethod,
-		))
+	key, err := NewSecretKey("", signingMethod, secret)
+	if err != nil {
+		panic(err)
+	}
+	keys, err := NewKeySet(key)
+	if err != nil {
+		panic(err)
 	}
+	return NewWithKeys(keys, nowFunc)
+}
 
+// NewWithKeys creates new jwt storage which signs tokens using signing key of the set and accepts tokens signed by
+// any key of the set
+func NewWithKeys(keys *KeySet, nowFunc func() time.Time) sessions.IStorage {
 	return &jwtStorage{
-		nowFunc:       nowFunc,
-		secretKey:     secret,
-		signingMethod: jwt.GetSigningMethod(signingMethod),
+		nowFunc: nowFunc,
+		keys:    keys,
 	}
 }
 
-// WithStorage jwt storage which uses persistent storage
+// WithStorage jwt storage which uses persistent storage, sessions ids are tracked in the user sessions set which key
+// is given by storageKeyFunc
 func WithStorage(
 	storage sessions.IStorage,
 	persistentStorage nosql.IStorage,
-	storageKeyFunc func(data map[string]interface{}, token string) string,
+	storageKeyFunc sessions.UserKeyFunc,
 ) sessions.IStorage {
 	jwtSt, ok := storage.(*jwtStorage)
 	if !ok {
@@ -59,34 +78,79 @@ func WithStorage(
 	return jwtSt
 }
 
+// WithTracking jwt storage which tracks sessions in the persistent storage the same way as WithStorage, so they may be
+// listed and revoked one by one, but tokens aren't looked up on validation, only revoked sessions are rejected.
+// Revoked sessions ids are kept for ttl, so it should be not less then the longest token expiration.
+func WithTracking(
+	storage sessions.IStorage,
+	persistentStorage nosql.IStorage,
+	storageKeyFunc sessions.UserKeyFunc,
+	ttl time.Duration,
+) sessions.IStorage {
+	jwtSt := WithStorage(storage, persistentStorage, storageKeyFunc).(*jwtStorage)
+	jwtSt.trackOnly = true
+	jwtSt.trackTTL = ttl
+	return jwtSt
+}
+
+// WithRevocation jwt storage which keeps user revocation moment in the given storage, so DeleteAll may be performed
+// even without tracking tokens. Revocation record must outlive any token, so ttl should be not less then the longest
+// token expiration.
+func WithRevocation(
+	storage sessions.IStorage,
+	revocationStorage nosql.IStorage,
+	storageKeyFunc sessions.UserKeyFunc,
+	ttl time.Duration,
+) sessions.IStorage {
+	jwtSt, ok := storage.(*jwtStorage)
+	if !ok {
+		panic(fmt.Sprintf("expect %T, but %T received", &jwtStorage{}, jwtSt))
+	}
+
+	jwtSt.storageKeyFunc = storageKeyFunc
+	jwtSt.revocationStorage = revocationStorage
+	jwtSt.revocationTTL = ttl
+	return jwtSt
+}
+
 // New generates new jwt token and track it if persistent storage is present
 func (s *jwtStorage) New(data map[string]interface{}, expireAfter time.Duration) (sessions.Token, error) {
-	token := jwt.New(s.signingMethod)
+	signingKey := s.keys.signing
+	token := jwt.New(signingKey.Method)
+	if signingKey.ID != "" {
+		token.Header[keyIDHeader] = signingKey.ID
+	}
 	claims := token.Claims.(jwt.MapClaims)
 
 	// copy data
+	data = sessions.CopyData(data)
 	for key, val := range data {
 		claims[key] = val
 	}
 
 	// populate
+	tokenID := uuid.New().String()
+	claims[tokenPersisIDKey] = tokenID
 	claims[issuedAtKey] = s.nowFunc().Unix()
 	claims[expireAtKey] = s.nowFunc().Add(expireAfter).Unix()
 
 	// if token storage is defined, use it to store token session
 	// it's allow us to track deleted sessions
 	if s.persistentStorage != nil {
-		tokenID := uuid.New().String()
-		claims[tokenPersisIDKey] = tokenID
+		err := s.persistentStorage.StrSet(s.storageKeyFunc(data)).AddExpire(tokenID, expireAfter)
+		if err != nil {
+			return sessions.Token{}, err
+		}
 
-		err := s.persistentStorage.StrSet(s.storageKeyFunc(data, tokenID)).AddExpire(tokenID, expireAfter)
+		// also keep session data, so user sessions may be listed
+		err = s.persistentStorage.SetWithExpire(sessionDataKey(tokenID), data, expireAfter)
 		if err != nil {
 			return sessions.Token{}, err
 		}
 	}
 
 	// generate token string
-	tokenString, err := token.SignedString(s.secretKey)
+	tokenString, err := token.SignedString(signingKey.SignKey)
 	return sessions.Token(tokenString), err
 }
 
@@ -107,6 +171,14 @@ func (s *jwtStorage) Get(token sessions.Token) (data map[string]interface{}, err
 		return
 	}
 
+	// check token issued after user sessions revocation
+	if s.revocationStorage != nil {
+		err = s.checkRevoked(claims)
+		if err != nil {
+			return
+		}
+	}
+
 	// if token storage defined, check token
 	if s.persistentStorage != nil {
 		tokenID, err := extractTokenIDFromClaims(claims)
@@ -115,9 +187,10 @@ func (s *jwtStorage) Get(token sessions.Token) (data map[string]interface{}, err
 		}
 
 		// lookup token
-		exists, err := s.persistentStorage.StrSet(s.storageKeyFunc(claims, tokenID)).Check(tokenID)
-		if !exists || err == nosql.ErrNoSuchKeyFound {
-			return nil, sessions.ErrNotFound
+		if s.trackOnly {
+			err = s.checkSessionRevoked(tokenID)
+		} else {
+			err = s.checkSessionTracked(claims, tokenID)
 		}
 		if err != nil {
 			return nil, err
@@ -132,6 +205,13 @@ func (s *jwtStorage) Get(token sessions.Token) (data map[string]interface{}, err
 		}
 		data[key] = val
 	}
+	if tokenID, ok := claims[tokenPersisIDKey]; ok {
+		data[sessions.SessionIDKey] = tokenID
+	}
+	data[sessions.ExpiresAtKey] = expireAtTs
+	if issuedAt, err := extractTimestampFromClaims(claims, issuedAtKey); err == nil {
+		data[sessions.IssuedAtKey] = issuedAt
+	}
 
 	return
 }
@@ -169,29 +249,212 @@ func (s *jwtStorage) Delete(token sessions.Token) error {
 		return err
 	}
 
+	return s.deleteSession(s.storageKeyFunc(claims), tokenID)
+}
+
+// List returns sessions tracked by the user sessions set, requires persistent storage
+func (s *jwtStorage) List(data map[string]interface{}) (res []sessions.Session, err error) {
+	if s.persistentStorage == nil {
+		return nil, sessions.ErrNotSupported
+	}
+
+	ids, err := s.persistentStorage.StrSet(s.storageKeyFunc(data)).List()
+	if err == nosql.ErrNoSuchKeyFound {
+		return nil, nil
+	}
+	if err != nil {
+		return
+	}
+
+	for _, id := range ids {
+		session := sessions.Session{ID: id}
+
+		raw, err := s.persistentStorage.Get(sessionDataKey(id))
+		switch err {
+		case nil:
+			data, ok := raw.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("unexpected session data type %T stored for the session id", raw)
+			}
+			session.Data = data
+		case nosql.ErrNoSuchKeyFound:
+		default:
+			return nil, err
+		}
+
+		res = append(res, session)
+	}
+	return
+}
+
+// DeleteByID removes session id from the user sessions set, requires persistent storage
+func (s *jwtStorage) DeleteByID(data map[string]interface{}, id string) error {
+	if s.persistentStorage == nil {
+		return sessions.ErrNotSupported
+	}
+
+	userKey := s.storageKeyFunc(data)
+	exists, err := s.persistentStorage.StrSet(userKey).Check(id)
+	if !exists || err == nosql.ErrNoSuchKeyFound {
+		return sessions.ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+	return s.deleteSession(userKey, id)
+}
+
+// DeleteAll revokes all user tokens, requires either persistent or revocation storage
+func (s *jwtStorage) DeleteAll(data map[string]interface{}) error {
+	if s.persistentStorage == nil && s.revocationStorage == nil {
+		return sessions.ErrNotSupported
+	}
+
+	userKey := s.storageKeyFunc(data)
+	if s.revocationStorage != nil {
+		err := s.revocationStorage.SetWithExpire(revokedAtKey(userKey), s.nowFunc().Unix(), s.revocationTTL)
+		if err != nil {
+			return err
+		}
+	}
+	if s.persistentStorage == nil {
+		return nil
+	}
+
+	ids, err := s.persistentStorage.StrSet(userKey).List()
+	if err == nosql.ErrNoSuchKeyFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, id := range ids {
+		err = s.deleteSession(userKey, id)
+		if err != nil && err != sessions.ErrNotFound {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *jwtStorage) deleteSession(userKey, tokenID string) error {
+	// tracking only storage doesn't lookup tokens, so session must be revoked explicitly
+	if s.trackOnly {
+		err := s.persistentStorage.SetWithExpire(sessionRevokedKey(tokenID), true, s.trackTTL)
+		if err != nil {
+			return err
+		}
+	}
+
 	// remove it from tokens storage
-	err = s.persistentStorage.StrSet(s.storageKeyFunc(claims, tokenID)).Remove(tokenID)
+	err := s.persistentStorage.StrSet(userKey).Remove(tokenID)
 	if err == nosql.ErrNoSuchKeyFound {
 		return sessions.ErrNotFound
 	}
+	if err != nil {
+		return err
+	}
+
+	err = s.persistentStorage.Delete(sessionDataKey(tokenID))
+	if err == nosql.ErrNoSuchKeyFound {
+		err = nil
+	}
 	return err
 }
 
+// checkSessionTracked returns ErrNotFound if session isn't tracked by the user sessions set
+func (s *jwtStorage) checkSessionTracked(claims jwt.MapClaims, tokenID string) error {
+	exists, err := s.persistentStorage.StrSet(s.storageKeyFunc(claims)).Check(tokenID)
+	if !exists || err == nosql.ErrNoSuchKeyFound {
+		return sessions.ErrNotFound
+	}
+	return err
+}
+
+// checkSessionRevoked returns ErrNotFound if session has been revoked explicitly
+func (s *jwtStorage) checkSessionRevoked(tokenID string) error {
+	_, err := s.persistentStorage.Get(sessionRevokedKey(tokenID))
+	switch err {
+	case nil:
+		return sessions.ErrNotFound
+	case nosql.ErrNoSuchKeyFound:
+		return nil
+	}
+	return err
+}
+
+// checkRevoked returns ErrRevoked if token issued before user sessions revocation. Token issued at the same second is
+// accepted, otherwise user wouldn't be able to signin right after revocation.
+func (s *jwtStorage) checkRevoked(claims jwt.MapClaims) error {
+	raw, err := s.revocationStorage.Get(revokedAtKey(s.storageKeyFunc(claims)))
+	if err == nosql.ErrNoSuchKeyFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	revokedAt, err := nosql.Int64(raw)
+	if err != nil {
+		return err
+	}
+
+	issuedAt, err := extractTimestampFromClaims(claims, issuedAtKey)
+	if err != nil {
+		return err
+	}
+	if issuedAt < revokedAt {
+		return sessions.ErrRevoked
+	}
+	return nil
+}
+
 func (s *jwtStorage) extractClaimsFromToken(token sessions.Token) (jwt.MapClaims, error) {
 	decodedToken, err := jwt.Parse(string(token), func(token *jwt.Token) (interface{}, error) {
-		if token.Method != s.signingMethod {
+		// tokens without key id are validated by the key with empty id
+		kid, _ := token.Header[keyIDHeader].(string)
+		key, ok := s.keys.lookup(kid)
+		if !ok {
+			return nil, fmt.Errorf("%s: unknown key id: %s", sessions.ErrUnexpectedToken.Error(), kid)
+		}
+		if token.Method != key.Method {
 			return nil, fmt.Errorf("%s: invalid signing method: %s", sessions.ErrUnexpectedToken.Error(), token.Method)
 		}
-		return s.secretKey, nil
+		return key.VerifyKey, nil
 	})
 	if err != nil {
-		return nil, err
+		return nil, coerceValidationErr(err)
 	}
 
 	return decodedToken.Claims.(jwt.MapClaims), err
 }
 
+// coerceValidationErr maps jwt validation errors onto the sessions storage ones, so callers may distinguish them
+func coerceValidationErr(err error) error {
+	validationErr, ok := err.(*jwt.ValidationError)
+	if !ok {
+		return err
+	}
+	if validationErr.Errors&jwt.ValidationErrorExpired != 0 {
+		return sessions.ErrExpired
+	}
+	return sessions.ErrUnexpectedToken
+}
+
 // utils
+func revokedAtKey(userKey string) string {
+	return fmt.Sprintf(revokedAtKeyPattern, userKey)
+}
+
+func sessionDataKey(tokenID string) string {
+	return fmt.Sprintf(sessionDataKeyPattern, tokenID)
+}
+
+func sessionRevokedKey(tokenID string) string {
+	return fmt.Sprintf(sessionRevokedKeyPattern, tokenID)
+}
+
 func extractTimestampFromClaims(claims jwt.MapClaims, key string) (int64, error) {
 	if expireAt, ok := claims[key]; ok {
 		if expireAt, ok := expireAt.(float64); ok {
@@ -0,0 +1,163 @@
+package jwt
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"git.zam.io/wallet-backend/web-api/pkg/services/nosql"
+	"git.zam.io/wallet-backend/web-api/pkg/services/nosql/mem"
+	nosqlredis "git.zam.io/wallet-backend/web-api/pkg/services/nosql/redis"
+	"git.zam.io/wallet-backend/web-api/pkg/services/sessions"
+	"github.com/alicebob/miniredis"
+	"github.com/go-redis/redis"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestJWTSessions(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "JWT Sessions Suite")
+}
+
+var secret = []byte("secret")
+
+func userKey(data map[string]interface{}) string {
+	return fmt.Sprintf("user:%v:sessions", data["phone"])
+}
+
+var _ = Describe("testing jwt sessions storage", func() {
+	userData := map[string]interface{}{"phone": "+79871111111", "device": "phone"}
+
+	Context("when validating token", func() {
+		storage := New("HS256", secret, time.Now)
+
+		It("should report expired token", func() {
+			token, err := New("HS256", secret, func() time.Time { return time.Now().Add(-time.Hour) }).New(
+				userData, time.Minute,
+			)
+			Expect(err).NotTo(HaveOccurred())
+
+			_, err = storage.Get(token)
+			Expect(err).To(Equal(sessions.ErrExpired))
+		})
+
+		It("should report malformed token", func() {
+			_, err := storage.Get(sessions.Token("garbage"))
+			Expect(err).To(Equal(sessions.ErrUnexpectedToken))
+		})
+
+		It("should report token signed by the unknown key", func() {
+			token, err := New("HS256", []byte("other secret"), time.Now).New(userData, time.Minute)
+			Expect(err).NotTo(HaveOccurred())
+
+			_, err = storage.Get(token)
+			Expect(err).To(Equal(sessions.ErrUnexpectedToken))
+		})
+	})
+
+	Context("when sessions are only tracked", func() {
+		var (
+			persistent nosql.IStorage
+			storage    sessions.IStorage
+		)
+		BeforeEach(func() {
+			persistent = mem.New()
+			storage = WithTracking(New("HS256", secret, time.Now), persistent, userKey, time.Hour)
+		})
+
+		It("should list and revoke session by id", func() {
+			token1, err := storage.New(userData, time.Minute)
+			Expect(err).NotTo(HaveOccurred())
+			token2, err := storage.New(userData, time.Minute)
+			Expect(err).NotTo(HaveOccurred())
+
+			list, err := storage.List(userData)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(list).To(HaveLen(2))
+			for _, session := range list {
+				Expect(session.Data).To(HaveKeyWithValue("device", "phone"))
+			}
+
+			data1, err := storage.Get(token1)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(storage.DeleteByID(userData, data1[sessions.SessionIDKey].(string))).To(Succeed())
+
+			_, err = storage.Get(token1)
+			Expect(err).To(Equal(sessions.ErrNotFound))
+			_, err = storage.Get(token2)
+			Expect(err).NotTo(HaveOccurred())
+
+			list, err = storage.List(userData)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(list).To(HaveLen(1))
+		})
+
+		It("should accept untracked token", func() {
+			token, err := New("HS256", secret, time.Now).New(userData, time.Minute)
+			Expect(err).NotTo(HaveOccurred())
+
+			data, err := storage.Get(token)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(data).To(HaveKeyWithValue("phone", "+79871111111"))
+		})
+
+		It("should revoke deleted token", func() {
+			token, err := storage.New(userData, time.Minute)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(storage.Delete(token)).To(Succeed())
+			_, err = storage.Get(token)
+			Expect(err).To(Equal(sessions.ErrNotFound))
+		})
+
+		It("should revoke all user sessions", func() {
+			storage = WithRevocation(storage, persistent, userKey, time.Hour)
+			token, err := storage.New(userData, time.Minute)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(storage.DeleteAll(userData)).To(Succeed())
+			_, err = storage.Get(token)
+			Expect(err).To(HaveOccurred())
+
+			list, err := storage.List(userData)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(list).To(BeEmpty())
+		})
+	})
+
+	Context("when persistent storage is redis", func() {
+		var (
+			server  *miniredis.Miniredis
+			closer  func() error
+			storage sessions.IStorage
+		)
+		BeforeEach(func() {
+			var err error
+			server, err = miniredis.Run()
+			Expect(err).NotTo(HaveOccurred())
+
+			persistent, c := nosqlredis.New(&redis.UniversalOptions{Addrs: []string{server.Addr()}})
+			closer = c.Close
+			storage = WithStorage(New("HS256", secret, time.Now), persistent, userKey)
+		})
+		AfterEach(func() {
+			Expect(closer()).To(Succeed())
+			server.Close()
+		})
+
+		It("should list sessions with their data", func() {
+			data := map[string]interface{}{
+				"phone": "+79871111111", "device": "phone", sessions.TokenFamilyKey: "family1",
+			}
+			_, err := storage.New(data, time.Minute)
+			Expect(err).NotTo(HaveOccurred())
+
+			list, err := storage.List(data)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(list).To(HaveLen(1))
+			Expect(list[0].Data).To(HaveKeyWithValue("device", "phone"))
+			Expect(list[0].Data).To(HaveKeyWithValue(sessions.TokenFamilyKey, "family1"))
+		})
+	})
+})
@@ -9,32 +9,41 @@ import (
 )
 
 type valWithExpire struct {
+	id        string
+	userKey   string
 	val       map[string]interface{}
 	expireAt  time.Time
 	createdAt time.Time
+	revoked   bool
 }
 
 // memStorage implements simple in-memory thread-safe storage
 type memStorage struct {
 	guard  sync.RWMutex
 	values map[string]valWithExpire
+
+	userKeyFunc sessions.UserKeyFunc
 }
 
-// New returns new in-memory storage
-func New() sessions.IStorage {
+// New returns new in-memory storage, sessions are grouped by the user using given key func
+func New(userKeyFunc sessions.UserKeyFunc) sessions.IStorage {
 	return &memStorage{
-		values: make(map[string]valWithExpire, 10),
+		values:      make(map[string]valWithExpire, 10),
+		userKeyFunc: userKeyFunc,
 	}
 }
 
 // New trivial IStorage implementation
 func (s *memStorage) New(data map[string]interface{}, expireAfter time.Duration) (sessions.Token, error) {
 	token := sessions.Token(uuid.New().String())
+	data = sessions.CopyData(data)
 
 	s.guard.Lock()
 	defer s.guard.Unlock()
 
 	s.values[string(token)] = valWithExpire{
+		id:        uuid.New().String(),
+		userKey:   s.userKeyFunc(data),
 		val:       data,
 		expireAt:  time.Now().Add(expireAfter),
 		createdAt: time.Now(),
@@ -43,29 +52,32 @@ func (s *memStorage) New(data map[string]interface{}, expireAfter time.Duration)
 	return token, nil
 }
 
-// RefreshToken
+// RefreshToken prolongs token expiration, token itself remains unchanged
 func (s *memStorage) RefreshToken(oldToken sessions.Token, expireAfter time.Duration) (newToken sessions.Token, err error) {
 	if err = validateToken(oldToken); err != nil {
 		return
 	}
 
-	s.guard.RLock()
-	defer s.guard.RUnlock()
+	s.guard.Lock()
+	defer s.guard.Unlock()
 
 	val, ok := s.values[string(oldToken)]
 	if !ok {
 		err = sessions.ErrNotFound
 		return
 	}
+	if val.revoked {
+		err = sessions.ErrRevoked
+		return
+	}
 	if val.expireAt.Before(time.Now()) {
 		err = sessions.ErrExpired
 		return
 	}
-	s.values[string(oldToken)] = valWithExpire{
-		val:       val.val,
-		expireAt:  time.Now().Add(expireAfter),
-		createdAt: time.Now(),
-	}
+	val.expireAt = time.Now().Add(expireAfter)
+	s.values[string(oldToken)] = val
+
+	newToken = oldToken
 	return
 }
 
@@ -83,11 +95,18 @@ func (s *memStorage) Get(token sessions.Token) (data map[string]interface{}, err
 		err = sessions.ErrNotFound
 		return
 	}
+	if val.revoked {
+		err = sessions.ErrRevoked
+		return
+	}
 	if !val.expireAt.After(time.Now()) {
 		err = sessions.ErrExpired
 		return
 	}
-	data = val.val
+	data = sessions.CopyData(val.val)
+	data[sessions.SessionIDKey] = val.id
+	data[sessions.ExpiresAtKey] = val.expireAt.Unix()
+	data[sessions.IssuedAtKey] = val.createdAt.Unix()
 	return
 }
 
@@ -109,6 +128,58 @@ func (s *memStorage) Delete(token sessions.Token) (err error) {
 	return
 }
 
+// List scans all sessions looking for ones which belongs to the same user
+func (s *memStorage) List(data map[string]interface{}) (res []sessions.Session, err error) {
+	userKey := s.userKeyFunc(data)
+
+	s.guard.RLock()
+	defer s.guard.RUnlock()
+
+	now := time.Now()
+	for _, val := range s.values {
+		if val.userKey != userKey || val.revoked || !val.expireAt.After(now) {
+			continue
+		}
+		res = append(res, sessions.Session{
+			ID:   val.id,
+			Data: sessions.CopyData(val.val),
+		})
+	}
+	return
+}
+
+// DeleteByID deletes user session by it's id
+func (s *memStorage) DeleteByID(data map[string]interface{}, id string) (err error) {
+	userKey := s.userKeyFunc(data)
+
+	s.guard.Lock()
+	defer s.guard.Unlock()
+
+	for token, val := range s.values {
+		if val.userKey == userKey && val.id == id && !val.revoked {
+			delete(s.values, token)
+			return
+		}
+	}
+	return sessions.ErrNotFound
+}
+
+// DeleteAll marks all user sessions as revoked, they are kept until expiration so revocation may be reported
+func (s *memStorage) DeleteAll(data map[string]interface{}) (err error) {
+	userKey := s.userKeyFunc(data)
+
+	s.guard.Lock()
+	defer s.guard.Unlock()
+
+	for token, val := range s.values {
+		if val.userKey == userKey {
+			val.revoked = true
+			s.values[token] = val
+		}
+	}
+	return
+}
+
 // validateToken validates token
 func validateToken(token sessions.Token) (err error) {
 	_, err = uuid.ParseBytes(token)
@@ -24,6 +24,34 @@ func (_m *IStorage) Delete(toke sessions.Token) error {
 	return r0
 }
 
+// DeleteAll provides a mock function with given fields: data
+func (_m *IStorage) DeleteAll(data map[string]interface{}) error {
+	ret := _m.Called(data)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(map[string]interface{}) error); ok {
+		r0 = rf(data)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// DeleteByID provides a mock function with given fields: data, id
+func (_m *IStorage) DeleteByID(data map[string]interface{}, id string) error {
+	ret := _m.Called(data, id)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(map[string]interface{}, string) error); ok {
+		r0 = rf(data, id)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // Get provides a mock function with given fields: token
 func (_m *IStorage) Get(token sessions.Token) (map[string]interface{}, error) {
 	ret := _m.Called(token)
@@ -47,6 +75,29 @@ func (_m *IStorage) Get(token sessions.Token) (map[string]interface{}, error) {
 	return r0, r1
 }
 
+// List provides a mock function with given fields: data
+func (_m *IStorage) List(data map[string]interface{}) ([]sessions.Session, error) {
+	ret := _m.Called(data)
+
+	var r0 []sessions.Session
+	if rf, ok := ret.Get(0).(func(map[string]interface{}) []sessions.Session); ok {
+		r0 = rf(data)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]sessions.Session)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(map[string]interface{}) error); ok {
+		r1 = rf(data)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // New provides a mock function with given fields: data, expireAfter
 func (_m *IStorage) New(data map[string]interface{}, expireAfter time.Duration) (sessions.Token, error) {
 	ret := _m.Called(data, expireAfter)
@@ -0,0 +1,2 @@
+// Package redis implements opaque-token sessions storage on top of redis nosql storage
+package redis
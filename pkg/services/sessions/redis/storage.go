@@ -0,0 +1,337 @@
+package redis
+
+import (
+	"fmt"
+	"git.zam.io/wallet-backend/web-api/pkg/services/nosql"
+	"git.zam.io/wallet-backend/web-api/pkg/services/sessions"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"time"
+)
+
+const (
+	sessionKeyPattern   = "session:%s"
+	sessionIDKeyPattern = "session:id:%s"
+
+	recordIDKey       = "id"
+	recordDataKey     = "data"
+	recordExpireAtKey = "expire_at"
+	recordIssuedAtKey = "issued_at"
+	recordRevokedKey  = "revoked"
+)
+
+// redisStorage implements sessions storage which keeps session data under random token, expiration is delegated to
+// the redis native keys TTL, so storage may be safely shared between several web-api instances.
+//
+// Each session also indexed by it's id and tracked in the user sessions set, so user sessions may be listed and
+// revoked without knowing their tokens. Revoked sessions records are kept as tombstones until their expiration.
+type redisStorage struct {
+	storage     nosql.IStorage
+	userKeyFunc sessions.UserKeyFunc
+}
+
+// New creates sessions storage using given nosql storage, it's expected to be redis-backed. Sessions are grouped by
+// the user using given key func.
+func New(storage nosql.IStorage, userKeyFunc sessions.UserKeyFunc) sessions.IStorage {
+	return &redisStorage{storage: storage, userKeyFunc: userKeyFunc}
+}
+
+// New generates random token and stores session data under it
+func (s *redisStorage) New(data map[string]interface{}, expireAfter time.Duration) (sessions.Token, error) {
+	token := sessions.Token(uuid.New().String())
+	id := uuid.New().String()
+	data = sessions.CopyData(data)
+	now := time.Now()
+
+	err := s.storage.SetWithExpire(sessionKey(token), map[string]interface{}{
+		recordIDKey:       id,
+		recordDataKey:     data,
+		recordExpireAtKey: now.Add(expireAfter).Unix(),
+		recordIssuedAtKey: now.Unix(),
+	}, expireAfter)
+	if err != nil {
+		return sessions.Token{}, err
+	}
+
+	err = s.storage.SetWithExpire(sessionIDKey(id), string(token), expireAfter)
+	if err != nil {
+		return sessions.Token{}, err
+	}
+
+	err = s.storage.StrSet(s.userKeyFunc(data)).AddExpire(id, expireAfter)
+	if err != nil {
+		return sessions.Token{}, err
+	}
+	return token, nil
+}
+
+// Get returns data stored under given token
+func (s *redisStorage) Get(token sessions.Token) (data map[string]interface{}, err error) {
+	if err = validateToken(token); err != nil {
+		return
+	}
+
+	record, err := s.getRawRecord(token)
+	if err != nil {
+		return
+	}
+	id, data, err := parseRecord(record)
+	if err != nil {
+		return
+	}
+	data[sessions.SessionIDKey] = id
+	data[sessions.ExpiresAtKey] = extractTimestamp(record[recordExpireAtKey])
+	// records created before issue time tracking don't have it
+	if issuedAt := extractTimestamp(record[recordIssuedAtKey]); issuedAt != 0 {
+		data[sessions.IssuedAtKey] = issuedAt
+	}
+	return
+}
+
+// RefreshToken issues new token with the same data and removes old one
+func (s *redisStorage) RefreshToken(oldToken sessions.Token, expireAfter time.Duration) (sessions.Token, error) {
+	data, err := s.Get(oldToken)
+	if err != nil {
+		return sessions.Token{}, err
+	}
+	err = s.Delete(oldToken)
+	if err != nil {
+		return sessions.Token{}, err
+	}
+	return s.New(data, expireAfter)
+}
+
+// Delete removes token from storage
+func (s *redisStorage) Delete(token sessions.Token) (err error) {
+	if err = validateToken(token); err != nil {
+		return
+	}
+
+	id, data, err := s.getRecord(token)
+	if err != nil {
+		return
+	}
+	return s.deleteSession(s.userKeyFunc(data), id, token)
+}
+
+// List returns user sessions tracked by the user sessions set
+func (s *redisStorage) List(data map[string]interface{}) (res []sessions.Session, err error) {
+	userKey := s.userKeyFunc(data)
+
+	ids, err := s.storage.StrSet(userKey).List()
+	if err == nosql.ErrNoSuchKeyFound {
+		return nil, nil
+	}
+	if err != nil {
+		return
+	}
+
+	for _, id := range ids {
+		token, err := s.getToken(id)
+		if err == sessions.ErrNotFound {
+			// session expired but user set still tracks it, so just skip it
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		_, sessData, err := s.getRecord(token)
+		if err == sessions.ErrNotFound || err == sessions.ErrRevoked {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		res = append(res, sessions.Session{ID: id, Data: sessData})
+	}
+	return
+}
+
+// DeleteByID removes user session by it's id
+func (s *redisStorage) DeleteByID(data map[string]interface{}, id string) error {
+	userKey := s.userKeyFunc(data)
+
+	// check session ownership first
+	owned, err := s.storage.StrSet(userKey).Check(id)
+	if err != nil && err != nosql.ErrNoSuchKeyFound {
+		return err
+	}
+	if !owned {
+		return sessions.ErrNotFound
+	}
+
+	token, err := s.getToken(id)
+	if err != nil {
+		return err
+	}
+	return s.deleteSession(userKey, id, token)
+}
+
+// DeleteAll revokes all user sessions
+func (s *redisStorage) DeleteAll(data map[string]interface{}) error {
+	userKey := s.userKeyFunc(data)
+
+	ids, err := s.storage.StrSet(userKey).List()
+	if err == nosql.ErrNoSuchKeyFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, id := range ids {
+		token, err := s.getToken(id)
+		if err == sessions.ErrNotFound {
+			err = s.storage.StrSet(userKey).Remove(id)
+			if err != nil && err != nosql.ErrNoSuchKeyFound {
+				return err
+			}
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		err = s.revokeSession(userKey, id, token)
+		if err != nil && err != sessions.ErrNotFound && err != sessions.ErrRevoked {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *redisStorage) getRecord(token sessions.Token) (id string, data map[string]interface{}, err error) {
+	record, err := s.getRawRecord(token)
+	if err != nil {
+		return
+	}
+	return parseRecord(record)
+}
+
+func (s *redisStorage) getRawRecord(token sessions.Token) (record map[string]interface{}, err error) {
+	raw, err := s.storage.Get(sessionKey(token))
+	if err != nil {
+		if err == nosql.ErrNoSuchKeyFound {
+			err = sessions.ErrNotFound
+		}
+		return
+	}
+
+	record, ok := raw.(map[string]interface{})
+	if !ok {
+		err = fmt.Errorf("unexpected session record type %T stored for the token", raw)
+	}
+	return
+}
+
+func parseRecord(record map[string]interface{}) (id string, data map[string]interface{}, err error) {
+	if revoked, _ := record[recordRevokedKey].(bool); revoked {
+		err = sessions.ErrRevoked
+		return
+	}
+
+	id, _ = record[recordIDKey].(string)
+	data, ok := record[recordDataKey].(map[string]interface{})
+	if !ok {
+		err = fmt.Errorf("unexpected session data type %T stored for the token", record[recordDataKey])
+	}
+	return
+}
+
+func (s *redisStorage) getToken(id string) (token sessions.Token, err error) {
+	raw, err := s.storage.Get(sessionIDKey(id))
+	if err != nil {
+		if err == nosql.ErrNoSuchKeyFound {
+			err = sessions.ErrNotFound
+		}
+		return
+	}
+
+	strToken, ok := raw.(string)
+	if !ok {
+		err = fmt.Errorf("unexpected session token type %T stored for the session id", raw)
+		return
+	}
+	return sessions.Token(strToken), nil
+}
+
+func (s *redisStorage) deleteSession(userKey, id string, token sessions.Token) error {
+	err := s.storage.Delete(sessionKey(token))
+	if err == nosql.ErrNoSuchKeyFound {
+		return sessions.ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	err = s.storage.Delete(sessionIDKey(id))
+	if err != nil && err != nosql.ErrNoSuchKeyFound {
+		return err
+	}
+
+	err = s.storage.StrSet(userKey).Remove(id)
+	if err != nil && err != nosql.ErrNoSuchKeyFound {
+		return err
+	}
+	return nil
+}
+
+// revokeSession replaces session record with tombstone which lives until session expiration, session is no more
+// tracked by the user set and id index
+func (s *redisStorage) revokeSession(userKey, id string, token sessions.Token) error {
+	record, err := s.getRawRecord(token)
+	if err != nil {
+		return err
+	}
+
+	ttl := time.Until(time.Unix(extractTimestamp(record[recordExpireAtKey]), 0))
+	if ttl > 0 {
+		record[recordRevokedKey] = true
+		err = s.storage.SetWithExpire(sessionKey(token), record, ttl)
+		if err != nil {
+			return err
+		}
+	} else {
+		// expiration unknown, so session can be only deleted
+		err = s.storage.Delete(sessionKey(token))
+		if err != nil && err != nosql.ErrNoSuchKeyFound {
+			return err
+		}
+	}
+
+	err = s.storage.Delete(sessionIDKey(id))
+	if err != nil && err != nosql.ErrNoSuchKeyFound {
+		return err
+	}
+
+	err = s.storage.StrSet(userKey).Remove(id)
+	if err != nil && err != nosql.ErrNoSuchKeyFound {
+		return err
+	}
+	return nil
+}
+
+// utils
+// extractTimestamp returns zero if timestamp is absent
+func extractTimestamp(raw interface{}) int64 {
+	ts, _ := nosql.Int64(raw)
+	return ts
+}
+
+func sessionKey(token sessions.Token) string {
+	return fmt.Sprintf(sessionKeyPattern, token)
+}
+
+func sessionIDKey(id string) string {
+	return fmt.Sprintf(sessionIDKeyPattern, id)
+}
+
+func validateToken(token sessions.Token) (err error) {
+	_, err = uuid.ParseBytes(token)
+	if err != nil {
+		err = errors.Wrap(sessions.ErrUnexpectedToken, err.Error())
+	}
+	return
+}
@@ -0,0 +1,103 @@
+package redis
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	nosqlredis "git.zam.io/wallet-backend/web-api/pkg/services/nosql/redis"
+	"git.zam.io/wallet-backend/web-api/pkg/services/sessions"
+	"github.com/alicebob/miniredis"
+	"github.com/go-redis/redis"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestRedisSessions(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Redis Sessions Suite")
+}
+
+func userKey(data map[string]interface{}) string {
+	return fmt.Sprintf("user:%v:sessions", data["phone"])
+}
+
+var _ = Describe("testing redis sessions storage on top of redis nosql storage", func() {
+	var (
+		server  *miniredis.Miniredis
+		closer  func() error
+		storage sessions.IStorage
+	)
+	userData := map[string]interface{}{"phone": "+79871111111", "device": "phone"}
+
+	BeforeEach(func() {
+		var err error
+		server, err = miniredis.Run()
+		Expect(err).NotTo(HaveOccurred())
+
+		persistent, c := nosqlredis.New(&redis.UniversalOptions{Addrs: []string{server.Addr()}})
+		closer = c.Close
+		storage = New(persistent, userKey)
+	})
+	AfterEach(func() {
+		Expect(closer()).To(Succeed())
+		server.Close()
+	})
+
+	It("should get session data", func() {
+		token, err := storage.New(userData, time.Minute)
+		Expect(err).NotTo(HaveOccurred())
+
+		data, err := storage.Get(token)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(data).To(HaveKeyWithValue("phone", "+79871111111"))
+		Expect(data).To(HaveKeyWithValue("device", "phone"))
+		Expect(data).To(HaveKey(sessions.SessionIDKey))
+		Expect(data[sessions.ExpiresAtKey]).To(BeNumerically(">", time.Now().Unix()))
+	})
+
+	It("should list and revoke sessions one by one", func() {
+		token1, err := storage.New(userData, time.Minute)
+		Expect(err).NotTo(HaveOccurred())
+		token2, err := storage.New(userData, time.Minute)
+		Expect(err).NotTo(HaveOccurred())
+
+		list, err := storage.List(userData)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(list).To(HaveLen(2))
+		for _, session := range list {
+			Expect(session.Data).To(HaveKeyWithValue("device", "phone"))
+		}
+
+		data1, err := storage.Get(token1)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(storage.DeleteByID(userData, data1[sessions.SessionIDKey].(string))).To(Succeed())
+
+		_, err = storage.Get(token1)
+		Expect(err).To(Equal(sessions.ErrNotFound))
+		_, err = storage.Get(token2)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("should delete session", func() {
+		token, err := storage.New(userData, time.Minute)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(storage.Delete(token)).To(Succeed())
+		_, err = storage.Get(token)
+		Expect(err).To(Equal(sessions.ErrNotFound))
+	})
+
+	It("should revoke all user sessions", func() {
+		token, err := storage.New(userData, time.Minute)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(storage.DeleteAll(userData)).To(Succeed())
+		_, err = storage.Get(token)
+		Expect(err).To(Equal(sessions.ErrRevoked))
+
+		list, err := storage.List(userData)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(list).To(BeEmpty())
+	})
+})
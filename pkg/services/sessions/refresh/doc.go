@@ -0,0 +1,4 @@
+// Package refresh issues access/refresh tokens pairs on top of the sessions storage. Refresh token may be used only
+// once, each use rotates whole pair. Tokens issued by the same signin form a family, if already rotated refresh token
+// is presented again, the family is considered compromised and all it's tokens are revoked.
+package refresh
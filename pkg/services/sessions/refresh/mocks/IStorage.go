@@ -0,0 +1,67 @@
+// Code generated by mockery v1.0.0
+package mocks
+
+import mock "github.com/stretchr/testify/mock"
+import refresh "git.zam.io/wallet-backend/web-api/pkg/services/sessions/refresh"
+import sessions "git.zam.io/wallet-backend/web-api/pkg/services/sessions"
+
+// IStorage is an autogenerated mock type for the IStorage type
+type IStorage struct {
+	mock.Mock
+}
+
+// New provides a mock function with given fields: data
+func (_m *IStorage) New(data map[string]interface{}) (refresh.Pair, error) {
+	ret := _m.Called(data)
+
+	var r0 refresh.Pair
+	if rf, ok := ret.Get(0).(func(map[string]interface{}) refresh.Pair); ok {
+		r0 = rf(data)
+	} else {
+		r0 = ret.Get(0).(refresh.Pair)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(map[string]interface{}) error); ok {
+		r1 = rf(data)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Refresh provides a mock function with given fields: token
+func (_m *IStorage) Refresh(token sessions.Token) (refresh.Pair, error) {
+	ret := _m.Called(token)
+
+	var r0 refresh.Pair
+	if rf, ok := ret.Get(0).(func(sessions.Token) refresh.Pair); ok {
+		r0 = rf(token)
+	} else {
+		r0 = ret.Get(0).(refresh.Pair)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(sessions.Token) error); ok {
+		r1 = rf(token)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Revoke provides a mock function with given fields: familyID
+func (_m *IStorage) Revoke(familyID string) error {
+	ret := _m.Called(familyID)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(string) error); ok {
+		r0 = rf(familyID)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
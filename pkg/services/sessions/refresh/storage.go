@@ -0,0 +1,297 @@
+package refresh
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"git.zam.io/wallet-backend/web-api/pkg/services/nosql"
+	"git.zam.io/wallet-backend/web-api/pkg/services/sessions"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"time"
+)
+
+// Storage errors
+var (
+	// ErrReused returned when already rotated refresh token is presented again, token family is revoked in such case
+	ErrReused = errors.New("refresh token already used, all tokens issued with it are revoked")
+
+	// ErrNotRefreshToken returned when given token isn't a refresh token
+	ErrNotRefreshToken = errors.New("given token isn't a refresh token")
+)
+
+const (
+	familyKeyPattern       = "session:family:%s"
+	refreshTokenKeyPattern = "session:refresh:%s"
+)
+
+// Pair holds short-lived access token and long-lived refresh token
+type Pair struct {
+	Access  sessions.Token
+	Refresh sessions.Token
+}
+
+// IStorage issues and rotates access/refresh tokens pairs
+type IStorage interface {
+	// New creates new tokens family and issues it's first pair
+	New(data map[string]interface{}) (Pair, error)
+
+	// Refresh exchanges refresh token for the new pair, previous pair becomes invalid. Returns ErrReused if token
+	// has been already exchanged.
+	Refresh(token sessions.Token) (Pair, error)
+
+	// Revoke revokes tokens family, so neither it's access nor refresh token may be used anymore. Returns
+	// sessions.ErrNotFound if family already revoked or expired.
+	Revoke(familyID string) error
+}
+
+// storage keeps tokens in the sessions storage, while families are tracked using nosql storage. Family record holds
+// hash of the only refresh token which may be exchanged and session ids of the current pair along with the data of
+// their owner, so tokens themselves are never stored. Record is replaced using compare-and-swap, so the same refresh
+// token can't be exchanged twice concurrently. Refresh tokens are indexed by
+// their hashes until expiration, so reuse of the rotated token is detected even if sessions storage already forgot it.
+type storage struct {
+	sessStorage       sessions.IStorage
+	persistentStorage nosql.IStorage
+
+	accessExpire  time.Duration
+	refreshExpire time.Duration
+}
+
+// New creates tokens pairs storage
+func New(
+	sessStorage sessions.IStorage,
+	persistentStorage nosql.IStorage,
+	accessExpire time.Duration,
+	refreshExpire time.Duration,
+) IStorage {
+	return &storage{
+		sessStorage:       sessStorage,
+		persistentStorage: persistentStorage,
+		accessExpire:      accessExpire,
+		refreshExpire:     refreshExpire,
+	}
+}
+
+// family tokens family record, stored as json-encoded string so it may be compared and swapped
+type family struct {
+	Current  string                 `json:"current"`
+	Sessions []string               `json:"sessions"`
+	Owner    map[string]interface{} `json:"owner"`
+}
+
+// New implements IStorage interface
+func (s *storage) New(data map[string]interface{}) (pair Pair, err error) {
+	familyID := uuid.New().String()
+	pair, ids, err := s.issue(data, familyID)
+	if err != nil {
+		return
+	}
+
+	record := newFamily(pair, ids, data)
+	swapped, err := s.persistentStorage.CompareAndSwap(familyKey(familyID), nil, record, s.refreshExpire)
+	if err == nil && !swapped {
+		err = fmt.Errorf("tokens family %s already exists", familyID)
+	}
+	return
+}
+
+// Refresh implements IStorage interface
+func (s *storage) Refresh(token sessions.Token) (pair Pair, err error) {
+	raw, err := s.persistentStorage.Get(refreshTokenKey(token))
+	if err != nil {
+		if err == nosql.ErrNoSuchKeyFound {
+			err = sessions.ErrNotFound
+		}
+		return
+	}
+	familyID, ok := raw.(string)
+	if !ok {
+		err = fmt.Errorf("unexpected token family type %T stored for the refresh token", raw)
+		return
+	}
+
+	raw, current, err := s.getFamily(familyID)
+	if err != nil {
+		return
+	}
+
+	// only the latest refresh token of the family may be exchanged, any other one means that it has been stolen
+	if current.Current != hashToken(token) {
+		return Pair{}, s.reused(familyID)
+	}
+
+	// sessions storage validates expiration and revocation
+	data, err := s.sessStorage.Get(token)
+	if err != nil {
+		return
+	}
+	if data[sessions.TokenTypeKey] != sessions.TokenTypeRefresh {
+		err = ErrNotRefreshToken
+		return
+	}
+
+	pair, ids, err := s.issue(data, familyID)
+	if err != nil {
+		return
+	}
+
+	// family is swapped only if it's unchanged since it's read, otherwise the same token has been exchanged
+	// concurrently, which is treated as reuse
+	record := newFamily(pair, ids, data)
+	swapped, err := s.persistentStorage.CompareAndSwap(familyKey(familyID), raw, record, s.refreshExpire)
+	if err != nil {
+		return
+	}
+	if !swapped {
+		err = s.deleteTokens([]string{string(pair.Access), string(pair.Refresh)})
+		if err == nil {
+			err = s.reused(familyID)
+		}
+		return Pair{}, err
+	}
+
+	// previous pair is replaced, rotated refresh token index is kept to detect it's reuse
+	err = s.deleteSessions(current)
+	return
+}
+
+// Revoke implements IStorage interface
+func (s *storage) Revoke(familyID string) error {
+	return s.revokeFamily(familyID)
+}
+
+// issue creates tokens of the new family pair and returns their session ids, family record itself isn't changed
+func (s *storage) issue(data map[string]interface{}, familyID string) (pair Pair, ids []string, err error) {
+	pair.Access, err = s.sessStorage.New(tokenData(data, sessions.TokenTypeAccess, familyID), s.accessExpire)
+	if err != nil {
+		return
+	}
+	pair.Refresh, err = s.sessStorage.New(tokenData(data, sessions.TokenTypeRefresh, familyID), s.refreshExpire)
+	if err != nil {
+		return
+	}
+
+	for _, token := range []sessions.Token{pair.Access, pair.Refresh} {
+		id, err := s.sessionID(token)
+		if err != nil {
+			return Pair{}, nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	err = s.persistentStorage.SetWithExpire(refreshTokenKey(pair.Refresh), familyID, s.refreshExpire)
+	return
+}
+
+// sessionID returns id of the session just issued
+func (s *storage) sessionID(token sessions.Token) (string, error) {
+	data, err := s.sessStorage.Get(token)
+	if err != nil {
+		return "", err
+	}
+	id, ok := data[sessions.SessionIDKey].(string)
+	if !ok || id == "" {
+		return "", errors.New("sessions storage doesn't expose session id")
+	}
+	return id, nil
+}
+
+func (s *storage) getFamily(familyID string) (raw interface{}, record family, err error) {
+	raw, err = s.persistentStorage.Get(familyKey(familyID))
+	if err != nil {
+		if err == nosql.ErrNoSuchKeyFound {
+			// family revoked or expired
+			err = sessions.ErrNotFound
+		}
+		return
+	}
+
+	str, ok := raw.(string)
+	if !ok {
+		err = fmt.Errorf("unexpected token family record type %T", raw)
+		return
+	}
+	err = json.Unmarshal([]byte(str), &record)
+	return
+}
+
+// reused revokes family which token has been reused and returns ErrReused
+func (s *storage) reused(familyID string) error {
+	err := s.revokeFamily(familyID)
+	if err == nil || err == sessions.ErrNotFound {
+		err = ErrReused
+	}
+	return err
+}
+
+func (s *storage) revokeFamily(familyID string) error {
+	_, record, err := s.getFamily(familyID)
+	if err != nil {
+		return err
+	}
+
+	err = s.deleteSessions(record)
+	if err != nil {
+		return err
+	}
+
+	err = s.persistentStorage.Delete(familyKey(familyID))
+	if err == nosql.ErrNoSuchKeyFound {
+		err = nil
+	}
+	return err
+}
+
+// deleteSessions deletes sessions of the family current pair
+func (s *storage) deleteSessions(record family) error {
+	for _, id := range record.Sessions {
+		err := s.sessStorage.DeleteByID(record.Owner, id)
+		if err != nil && err != sessions.ErrNotFound && err != sessions.ErrExpired {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *storage) deleteTokens(tokens []string) error {
+	for _, token := range tokens {
+		err := s.sessStorage.Delete(sessions.Token(token))
+		if err != nil && err != sessions.ErrNotFound && err != sessions.ErrExpired {
+			return err
+		}
+	}
+	return nil
+}
+
+// utils
+func newFamily(pair Pair, ids []string, owner map[string]interface{}) string {
+	// owner is the session data, which consists of plain values, so marshaling never fails
+	bytes, _ := json.Marshal(family{
+		Current:  hashToken(pair.Refresh),
+		Sessions: ids,
+		Owner:    sessions.CopyData(owner),
+	})
+	return string(bytes)
+}
+
+func tokenData(data map[string]interface{}, tokenType, familyID string) map[string]interface{} {
+	data = sessions.CopyData(data)
+	data[sessions.TokenTypeKey] = tokenType
+	data[sessions.TokenFamilyKey] = familyID
+	return data
+}
+
+func hashToken(token sessions.Token) string {
+	sum := sha256.Sum256(token)
+	return hex.EncodeToString(sum[:])
+}
+
+func familyKey(familyID string) string {
+	return fmt.Sprintf(familyKeyPattern, familyID)
+}
+
+func refreshTokenKey(token sessions.Token) string {
+	return fmt.Sprintf(refreshTokenKeyPattern, hashToken(token))
+}
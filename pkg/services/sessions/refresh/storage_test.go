@@ -0,0 +1,84 @@
+package refresh
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"git.zam.io/wallet-backend/web-api/pkg/services/nosql"
+	nosqlmem "git.zam.io/wallet-backend/web-api/pkg/services/nosql/mem"
+	"git.zam.io/wallet-backend/web-api/pkg/services/sessions"
+	"git.zam.io/wallet-backend/web-api/pkg/services/sessions/mem"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestRefresh(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Refresh Suite")
+}
+
+func userKey(data map[string]interface{}) string {
+	return fmt.Sprintf("user:%v:sessions", data["phone"])
+}
+
+var _ = Describe("testing tokens pairs storage", func() {
+	var (
+		sessStorage sessions.IStorage
+		persistent  nosql.IStorage
+		storage     IStorage
+	)
+	userData := map[string]interface{}{"phone": "+79871111111"}
+
+	BeforeEach(func() {
+		sessStorage = mem.New(userKey)
+		persistent = nosqlmem.New()
+		storage = New(sessStorage, persistent, time.Minute, time.Hour)
+	})
+
+	familyOf := func(pair Pair) string {
+		data, err := sessStorage.Get(pair.Access)
+		Expect(err).NotTo(HaveOccurred())
+		return data[sessions.TokenFamilyKey].(string)
+	}
+
+	It("should not keep tokens in the family record", func() {
+		pair, err := storage.New(userData)
+		Expect(err).NotTo(HaveOccurred())
+
+		raw, err := persistent.Get(familyKey(familyOf(pair)))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(raw).NotTo(ContainSubstring(string(pair.Access)))
+		Expect(raw).NotTo(ContainSubstring(string(pair.Refresh)))
+	})
+
+	It("should revoke current pair of the family", func() {
+		pair, err := storage.New(userData)
+		Expect(err).NotTo(HaveOccurred())
+		pair, err = storage.Refresh(pair.Refresh)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(storage.Revoke(familyOf(pair))).To(Succeed())
+
+		_, err = sessStorage.Get(pair.Access)
+		Expect(err).To(Equal(sessions.ErrNotFound))
+		_, err = storage.Refresh(pair.Refresh)
+		Expect(err).To(Equal(sessions.ErrNotFound))
+	})
+
+	It("should delete rotated pair", func() {
+		old, err := storage.New(userData)
+		Expect(err).NotTo(HaveOccurred())
+		pair, err := storage.Refresh(old.Refresh)
+		Expect(err).NotTo(HaveOccurred())
+
+		_, err = sessStorage.Get(old.Access)
+		Expect(err).To(Equal(sessions.ErrNotFound))
+
+		By("rotated refresh token reuse revokes the family")
+		_, err = storage.Refresh(old.Refresh)
+		Expect(err).To(Equal(ErrReused))
+		_, err = sessStorage.Get(pair.Access)
+		Expect(err).To(Equal(sessions.ErrNotFound))
+	})
+})
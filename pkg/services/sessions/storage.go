@@ -15,17 +15,68 @@ var (
 
 	// ErrExpired returned when requested token already expires
 	ErrExpired = errors.New("token expired")
+
+	// ErrRevoked returned when session has been revoked along with all other user sessions, e.g. after password
+	// change, so user must signin again
+	ErrRevoked = errors.New("session revoked, signin required")
+
+	// ErrNotSupported returned when storage backend can't perform requested operation by design
+	ErrNotSupported = errors.New("operation not supported by the sessions storage")
+)
+
+// SessionIDKey is the reserved data key under which Get exposes the session identifier. Unlike the token, session
+// identifier isn't a secret, so it may be shown to the user.
+const SessionIDKey = "session_id"
+
+// Reserved data keys under which Get exposes the session token expiration and issue unix timestamps, backends which
+// don't track the issue time omit IssuedAtKey
+const (
+	ExpiresAtKey = "expires_at"
+	IssuedAtKey  = "issued_at"
+)
+
+// Token types, sessions without TokenTypeKey are treated as access ones
+const (
+	// TokenTypeKey session data key which holds token type
+	TokenTypeKey = "token_type"
+
+	// TokenFamilyKey session data key which holds identifier of the access/refresh tokens family
+	TokenFamilyKey = "token_family"
+
+	TokenTypeAccess  = "access"
+	TokenTypeRefresh = "refresh"
+)
+
+// Session metadata keys, describe the client which has created session
+const (
+	IPKey        = "ip"
+	UserAgentKey = "user_agent"
+	DeviceKey    = "device"
+	CreatedAtKey = "created_at"
 )
 
 // Token represents user session token
 type Token []byte
 
+// Session describes user session without exposing it's token
+type Session struct {
+	// ID session identifier
+	ID string
+
+	// Data associated with the session, may be nil if backend doesn't keep it
+	Data map[string]interface{}
+}
+
+// UserKeyFunc returns key which groups sessions of the same user, key is derived from the session data
+type UserKeyFunc func(data map[string]interface{}) string
+
 // IStorage collects, persist and manages user auth sessions via tokens and associated-optional data.
 type IStorage interface {
 	// New creates new session
 	New(data map[string]interface{}, expireAfter time.Duration) (Token, error)
 
-	// Get returns data associated with this token
+	// Get returns data associated with this token, session identifier is placed under SessionIDKey, token expiration
+	// and issue time are placed under ExpiresAtKey and IssuedAtKey
 	Get(token Token) (data map[string]interface{}, err error)
 
 	// RefreshToken
@@ -33,4 +84,27 @@ type IStorage interface {
 
 	// Delete makes token invalid so sequential Get call will returns ErrNotFound
 	Delete(toke Token) error
+
+	// List returns active sessions of the user which owns given session data
+	List(data map[string]interface{}) ([]Session, error)
+
+	// DeleteByID makes session with given identifier invalid, returns ErrNotFound if such session doesn't belong to
+	// the user which owns given session data
+	DeleteByID(data map[string]interface{}, id string) error
+
+	// DeleteAll makes all sessions of the user which owns given session data invalid, sequential Get call will
+	// returns ErrRevoked
+	DeleteAll(data map[string]interface{}) error
+}
+
+// CopyData copies session data skipping reserved keys, used by backends to prevent reserved values from being stored
+func CopyData(data map[string]interface{}) map[string]interface{} {
+	res := make(map[string]interface{}, len(data))
+	for key, val := range data {
+		if key == SessionIDKey || key == ExpiresAtKey || key == IssuedAtKey {
+			continue
+		}
+		res[key] = val
+	}
+	return res
 }
@@ -0,0 +1,2 @@
+// Package throttle limits failed attempts of some action (e.g. signin) per subject using nosql storage
+package throttle
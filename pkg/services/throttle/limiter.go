@@ -0,0 +1,137 @@
+package throttle
+
+import (
+	"fmt"
+	"git.zam.io/wallet-backend/web-api/pkg/services/nosql"
+	"github.com/google/uuid"
+	"time"
+)
+
+const (
+	failuresKeyPattern  = "%s:%s:failures"
+	lockKeyPattern      = "%s:%s:lock"
+	lockLevelKeyPattern = "%s:%s:lock_level"
+)
+
+// Params limiter parameters
+type Params struct {
+	// Window sliding window in which failed attempts are counted
+	Window time.Duration
+
+	// MaxFailures failed attempts within window after which subject is locked
+	MaxFailures int
+
+	// Lockout duration of the first lock, each sequential lock doubles it
+	Lockout time.Duration
+
+	// MaxLockout limits lock duration, lock level is reset if subject stays unlocked during this time
+	MaxLockout time.Duration
+}
+
+// Limiter counts failed attempts in the sliding window, each failure is an element of the strings set which expires
+// after window. Once failures count exceeds limit, subject is locked with growing backoff.
+type Limiter struct {
+	storage nosql.IStorage
+	prefix  string
+	params  Params
+	nowFunc func() time.Time
+}
+
+// New creates limiter, prefix separates keys of different limiters
+func New(storage nosql.IStorage, prefix string, params Params, nowFunc func() time.Time) *Limiter {
+	return &Limiter{storage: storage, prefix: prefix, params: params, nowFunc: nowFunc}
+}
+
+// LockedUntil returns lock expiration time if subject is locked
+func (l *Limiter) LockedUntil(subject string) (until time.Time, locked bool, err error) {
+	raw, err := l.storage.Get(l.key(lockKeyPattern, subject))
+	if err == nosql.ErrNoSuchKeyFound {
+		return time.Time{}, false, nil
+	}
+	if err != nil {
+		return
+	}
+
+	ts, err := nosql.Int64(raw)
+	if err != nil {
+		return
+	}
+	until = time.Unix(ts, 0).UTC()
+	return until, until.After(l.nowFunc()), nil
+}
+
+// Fail registers failed attempt, if it exceeds limit subject becomes locked and lock expiration time is returned
+func (l *Limiter) Fail(subject string) (until time.Time, locked bool, err error) {
+	failures := l.storage.StrSet(l.key(failuresKeyPattern, subject))
+	err = failures.AddExpire(uuid.New().String(), l.params.Window)
+	if err != nil {
+		return
+	}
+
+	attempts, err := failures.List()
+	if err != nil {
+		return
+	}
+	if len(attempts) < l.params.MaxFailures {
+		return
+	}
+
+	// lock subject, each sequential lock is twice longer
+	level, err := l.lockLevel(subject)
+	if err != nil {
+		return
+	}
+	lockout := l.params.Lockout << uint(level)
+	if lockout > l.params.MaxLockout || lockout <= 0 {
+		lockout = l.params.MaxLockout
+	}
+	until = l.nowFunc().Add(lockout).UTC()
+
+	err = l.storage.SetWithExpire(l.key(lockKeyPattern, subject), until.Unix(), lockout)
+	if err != nil {
+		return
+	}
+	err = l.storage.SetWithExpire(l.key(lockLevelKeyPattern, subject), level+1, lockout+l.params.MaxLockout)
+	if err != nil {
+		return
+	}
+
+	// start new window after lock
+	err = l.Reset(subject)
+	return until, true, err
+}
+
+// Reset clears failed attempts of the subject, lock level remains until expiration
+func (l *Limiter) Reset(subject string) error {
+	err := l.storage.Delete(l.key(failuresKeyPattern, subject))
+	if err == nosql.ErrNoSuchKeyFound {
+		err = nil
+	}
+	return err
+}
+
+// Move transfers failed attempts and lock of the subject to the new one, e.g. when user phone is changed
+func (l *Limiter) Move(subject, newSubject string) error {
+	for _, pattern := range []string{failuresKeyPattern, lockKeyPattern, lockLevelKeyPattern} {
+		err := l.storage.Rename(l.key(pattern, subject), l.key(pattern, newSubject))
+		if err != nil && err != nosql.ErrNoSuchKeyFound {
+			return err
+		}
+	}
+	return nil
+}
+
+func (l *Limiter) lockLevel(subject string) (int64, error) {
+	raw, err := l.storage.Get(l.key(lockLevelKeyPattern, subject))
+	if err == nosql.ErrNoSuchKeyFound {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return nosql.Int64(raw)
+}
+
+func (l *Limiter) key(pattern, subject string) string {
+	return fmt.Sprintf(pattern, l.prefix, subject)
+}
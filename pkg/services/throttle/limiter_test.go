@@ -0,0 +1,66 @@
+package throttle
+
+import (
+	"testing"
+	"time"
+
+	nosqlredis "git.zam.io/wallet-backend/web-api/pkg/services/nosql/redis"
+	"github.com/alicebob/miniredis"
+	"github.com/go-redis/redis"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestThrottle(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Throttle Suite")
+}
+
+var _ = Describe("testing limiter on top of redis nosql storage", func() {
+	var (
+		server  *miniredis.Miniredis
+		closer  func() error
+		limiter *Limiter
+	)
+	params := Params{Window: time.Minute, MaxFailures: 3, Lockout: time.Minute, MaxLockout: time.Hour}
+
+	BeforeEach(func() {
+		var err error
+		server, err = miniredis.Run()
+		Expect(err).NotTo(HaveOccurred())
+
+		storage, c := nosqlredis.New(&redis.UniversalOptions{Addrs: []string{server.Addr()}})
+		closer = c.Close
+		limiter = New(storage, "signin", params, time.Now)
+	})
+	AfterEach(func() {
+		Expect(closer()).To(Succeed())
+		server.Close()
+	})
+
+	It("should expire failures set after window", func() {
+		_, locked, err := limiter.Fail("+79871111111")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(locked).To(BeFalse())
+
+		key := "signin:+79871111111:failures"
+		Expect(server.Exists(key)).To(BeTrue())
+		Expect(server.TTL(key)).To(BeNumerically(">", 0))
+		Expect(server.TTL(key)).To(BeNumerically("<=", params.Window+time.Second))
+	})
+
+	It("should lock subject once failures exceed limit", func() {
+		for i := 0; i < params.MaxFailures-1; i++ {
+			_, locked, err := limiter.Fail("+79871111111")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(locked).To(BeFalse())
+		}
+		_, locked, err := limiter.Fail("+79871111111")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(locked).To(BeTrue())
+
+		_, locked, err = limiter.LockedUntil("+79871111111")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(locked).To(BeTrue())
+	})
+})
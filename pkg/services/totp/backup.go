@@ -0,0 +1,41 @@
+package totp
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+)
+
+const (
+	// alphabet excludes ambiguous i, l, o and 1, it's exactly 32 letters long, so random byte maps to it without bias
+	backupCodeAlphabet = "abcdefghjkmnpqrstuvwxyz023456789"
+	backupCodeLen      = 10
+)
+
+// GenerateBackupCodes generates given count of random one-time codes formatted as "xxxxx-xxxxx"
+func GenerateBackupCodes(count int) ([]string, error) {
+	codes := make([]string, count)
+	raw := make([]byte, backupCodeLen)
+	for i := range codes {
+		_, err := rand.Read(raw)
+		if err != nil {
+			return nil, err
+		}
+
+		code := make([]byte, backupCodeLen)
+		for j, b := range raw {
+			code[j] = backupCodeAlphabet[int(b)%len(backupCodeAlphabet)]
+		}
+		codes[i] = string(code[:backupCodeLen/2]) + "-" + string(code[backupCodeLen/2:])
+	}
+	return codes, nil
+}
+
+// HashBackupCode returns hash of the backup code under which it should be stored, code is normalized before, so
+// case and separators typed by the user doesn't matter
+func HashBackupCode(code string) string {
+	code = strings.ToLower(strings.Replace(strings.Replace(code, "-", "", -1), " ", "", -1))
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
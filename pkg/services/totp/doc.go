@@ -0,0 +1,3 @@
+// Package totp implements time-based one-time passwords (RFC 6238) compatible with common authenticator apps, also
+// provides one-time backup codes used when authenticator isn't available
+package totp
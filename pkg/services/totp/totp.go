@@ -0,0 +1,128 @@
+package totp
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Parameters are fixed to the defaults of authenticator apps, some of them ignore parameters passed in the
+// provisioning URI
+const (
+	// Digits length of the generated code
+	Digits = 6
+
+	// Period time step of the code
+	Period = 30 * time.Second
+
+	// Skew allowed clock drift in steps
+	Skew = 1
+
+	// SecretSize size of the generated secret in bytes
+	SecretSize = 20
+)
+
+// ErrInvalidSecret returned when secret isn't valid base32 string
+var ErrInvalidSecret = errors.New("totp: invalid secret")
+
+var encoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// GenerateSecret generates random base32-encoded secret
+func GenerateSecret() (string, error) {
+	secret := make([]byte, SecretSize)
+	_, err := rand.Read(secret)
+	if err != nil {
+		return "", err
+	}
+	return encoding.EncodeToString(secret), nil
+}
+
+// Step returns time step which given time belongs to
+func Step(t time.Time) int64 {
+	return t.Unix() / int64(Period/time.Second)
+}
+
+// Code generates code for the given time step
+func Code(secret string, step int64) (string, error) {
+	key, err := decodeSecret(secret)
+	if err != nil {
+		return "", err
+	}
+	return code(key, step), nil
+}
+
+// Validate checks code against steps around given time, steps which aren't after the given one are rejected, so
+// accepted code can't be replayed if matched step is saved and passed as after on the next validation
+func Validate(secret, userCode string, t time.Time, after int64) (step int64, ok bool, err error) {
+	key, err := decodeSecret(secret)
+	if err != nil {
+		return
+	}
+	if len(userCode) != Digits {
+		return
+	}
+
+	current := Step(t)
+	for s := current - Skew; s <= current+Skew; s++ {
+		if s <= after {
+			continue
+		}
+		if subtle.ConstantTimeCompare([]byte(code(key, s)), []byte(userCode)) == 1 {
+			return s, true, nil
+		}
+	}
+	return
+}
+
+// ProvisioningURI returns otpauth URI which is usually shown as QR code to enroll the secret into authenticator app
+func ProvisioningURI(issuer, account, secret string) string {
+	// plus sign in the path is escaped explicitly since some apps decode it as space
+	label := strings.Replace(url.PathEscape(issuer+":"+account), "+", "%2B", -1)
+
+	query := url.Values{}
+	query.Set("secret", secret)
+	query.Set("issuer", issuer)
+	query.Set("algorithm", "SHA1")
+	query.Set("digits", strconv.Itoa(Digits))
+	query.Set("period", strconv.Itoa(int(Period/time.Second)))
+
+	return "otpauth://totp/" + label + "?" + query.Encode()
+}
+
+// utils
+func decodeSecret(secret string) ([]byte, error) {
+	key, err := encoding.DecodeString(strings.ToUpper(strings.TrimRight(secret, "=")))
+	if err != nil || len(key) == 0 {
+		return nil, ErrInvalidSecret
+	}
+	return key, nil
+}
+
+func code(key []byte, step int64) string {
+	msg := make([]byte, 8)
+	binary.BigEndian.PutUint64(msg, uint64(step))
+
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg)
+	sum := mac.Sum(nil)
+
+	// dynamic truncation described in RFC 4226
+	offset := sum[len(sum)-1] & 0xf
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	mod := uint32(1)
+	for i := 0; i < Digits; i++ {
+		mod *= 10
+	}
+	return fmt.Sprintf("%0*d", Digits, value%mod)
+}
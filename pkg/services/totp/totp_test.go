@@ -0,0 +1,115 @@
+package totp
+
+import (
+	"encoding/base32"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	"github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+func TestTOTP(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "TOTP Suite")
+}
+
+// secret used by RFC 6238 test vectors for SHA1
+var rfcSecret = base32.StdEncoding.EncodeToString([]byte("12345678901234567890"))
+
+var _ = Describe("testing totp", func() {
+	table.DescribeTable(
+		"should generate RFC 6238 codes",
+		func(unix int64, expected string) {
+			code, err := Code(rfcSecret, Step(time.Unix(unix, 0)))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(code).To(Equal(expected))
+		},
+		table.Entry("59", int64(59), "287082"),
+		table.Entry("1111111109", int64(1111111109), "081804"),
+		table.Entry("1111111111", int64(1111111111), "050471"),
+		table.Entry("1234567890", int64(1234567890), "005924"),
+		table.Entry("2000000000", int64(2000000000), "279037"),
+		table.Entry("20000000000", int64(20000000000), "353130"),
+	)
+
+	Context("when validating code", func() {
+		now := time.Unix(1234567890, 0)
+
+		It("should accept current code", func() {
+			step, ok, err := Validate(rfcSecret, "005924", now, 0)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ok).To(BeTrue())
+			Expect(step).To(Equal(Step(now)))
+		})
+
+		It("should accept code of the previous step", func() {
+			_, ok, err := Validate(rfcSecret, "005924", now.Add(Period), 0)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ok).To(BeTrue())
+		})
+
+		It("should reject code out of skew", func() {
+			_, ok, err := Validate(rfcSecret, "005924", now.Add(2*Period), 0)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ok).To(BeFalse())
+		})
+
+		It("should reject already used step", func() {
+			_, ok, err := Validate(rfcSecret, "005924", now, Step(now))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ok).To(BeFalse())
+		})
+
+		It("should reject malformed code", func() {
+			_, ok, err := Validate(rfcSecret, "5924", now, 0)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ok).To(BeFalse())
+		})
+
+		It("should fail due to invalid secret", func() {
+			_, _, err := Validate("not a base32!", "005924", now, 0)
+			Expect(err).To(Equal(ErrInvalidSecret))
+		})
+	})
+
+	It("should generate valid secret", func() {
+		secret, err := GenerateSecret()
+		Expect(err).NotTo(HaveOccurred())
+
+		code, err := Code(secret, Step(time.Now()))
+		Expect(err).NotTo(HaveOccurred())
+		_, ok, err := Validate(secret, code, time.Now(), 0)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ok).To(BeTrue())
+	})
+
+	It("should build provisioning uri", func() {
+		uri := ProvisioningURI("ZAM Wallet", "+79871111111", "JBSWY3DPEHPK3PXP")
+		Expect(uri).To(HavePrefix("otpauth://totp/ZAM%20Wallet:%2B79871111111?"))
+		Expect(uri).To(ContainSubstring("secret=JBSWY3DPEHPK3PXP"))
+		Expect(uri).To(ContainSubstring("issuer=ZAM+Wallet"))
+	})
+
+	Context("when using backup codes", func() {
+		It("should generate unique codes", func() {
+			codes, err := GenerateBackupCodes(10)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(codes).To(HaveLen(10))
+
+			hashes := make(map[string]struct{})
+			for _, code := range codes {
+				Expect(code).To(MatchRegexp(`^[a-z0-9]{5}-[a-z0-9]{5}$`))
+				hashes[HashBackupCode(code)] = struct{}{}
+			}
+			Expect(hashes).To(HaveLen(10))
+		})
+
+		It("should ignore case and separators", func() {
+			Expect(HashBackupCode("ABCDE-FGHJK")).To(Equal(HashBackupCode("abcdefghjk")))
+			Expect(HashBackupCode(strings.ToUpper("abcde fghjk"))).To(Equal(HashBackupCode("abcde-fghjk")))
+		})
+	})
+})